@@ -27,6 +27,10 @@ const (
 	DepositsV1Path          = "/api/v1/deposits"
 	WithdrawalsV1Path       = "/api/v1/withdrawals"
 	SubmitWithdrawalsV1Path = "/api/v1/submit/withdrawals"
+	DiscrepanciesV1Path     = "/api/v1/balance/discrepancies"
+	TokensV1Path            = "/api/v1/tokens"
+	DeadLettersV1Path       = "/api/v1/notify/dead-letters"
+	RedeliverV1Path         = "/api/v1/notify/dead-letters/redeliver"
 )
 
 type APIConfig struct {
@@ -63,7 +67,7 @@ func (a *API) initFromConfig(ctx context.Context, cfg *config.Config) error {
 func (a *API) initRouter(conf config.ServerConfig, cfg *config.Config) {
 	v := new(service.Validator)
 
-	svc := service.New(v, a.db.Deposits, a.db.Withdraws)
+	svc := service.New(v, a.db.Deposits, a.db.Withdraws, a.db.Discrepancies, a.db.Tokens, a.db.DeadLetters)
 	apiRouter := chi.NewRouter()
 	h := routes.NewRoutes(apiRouter, svc)
 
@@ -71,10 +75,16 @@ func (a *API) initRouter(conf config.ServerConfig, cfg *config.Config) {
 	apiRouter.Use(middleware.Recoverer)
 
 	apiRouter.Use(middleware.Heartbeat(HealthPath))
+	apiRouter.Use(consumerAuth(a.db))
 
 	apiRouter.Get(fmt.Sprintf(DepositsV1Path), h.DepositListHandler)
 	apiRouter.Get(fmt.Sprintf(WithdrawalsV1Path), h.WithdrawListHandler)
 	apiRouter.Post(fmt.Sprintf(SubmitWithdrawalsV1Path), h.SubmitWithdrawHandler)
+	apiRouter.Get(fmt.Sprintf(DiscrepanciesV1Path), h.DiscrepancyListHandler)
+	apiRouter.Get(fmt.Sprintf(TokensV1Path), h.TokenListHandler)
+	apiRouter.Post(fmt.Sprintf(TokensV1Path), h.SubmitTokenHandler)
+	apiRouter.Get(fmt.Sprintf(DeadLettersV1Path), h.DeadLetterListHandler)
+	apiRouter.Post(fmt.Sprintf(RedeliverV1Path), h.RedeliverDeadLetterHandler)
 
 	a.router = apiRouter
 }
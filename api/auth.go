@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/api/common/httputil"
+	"github.com/the-web3/sol-wallet/common/auth"
+	"github.com/the-web3/sol-wallet/database"
+)
+
+const ConsumerTokenHeader = "X-Consumer-Token"
+
+// consumerAuth 用 X-Consumer-Token 认证业务方，scope 为 "请求方法 路径"(如 POST /api/v1/submit/withdrawals)，
+// 非 GET 请求记录调用审计
+func consumerAuth(db *database.DB) func(http.Handler) http.Handler {
+	authenticator := auth.NewAuthenticator(db.Consumers)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method := r.Method + " " + r.URL.Path
+			consumer, err := authenticator.Authenticate(r.Header.Get(ConsumerTokenHeader), method)
+			if err != nil {
+				log.Warn("api consumer auth fail", "method", method, "err", err)
+				switch {
+				case errors.Is(err, auth.ErrPermissionDenied):
+					http.Error(w, err.Error(), http.StatusForbidden)
+				case errors.Is(err, auth.ErrMissingToken), errors.Is(err, auth.ErrInvalidToken):
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+				default:
+					http.Error(w, "authenticate consumer fail", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if r.Method == http.MethodGet {
+				next.ServeHTTP(w, r.WithContext(auth.WithConsumer(r.Context(), consumer)))
+				return
+			}
+			ww := httputil.NewWrappedResponseWriter(w)
+			next.ServeHTTP(ww, r.WithContext(auth.WithConsumer(r.Context(), consumer)))
+			auditLog := &database.ConsumerAuditLogs{
+				Consumer:  consumer.Name,
+				Method:    method,
+				RequestId: r.URL.Query().Get("requestId"),
+				Result:    strconv.Itoa(ww.StatusCode),
+			}
+			if err := db.Consumers.StoreAuditLog(auditLog); err != nil {
+				log.Error("store consumer audit log fail", "consumer", consumer.Name, "method", method, "err", err)
+			}
+		})
+	}
+}
@@ -10,6 +10,17 @@ type SubmitDWParams struct {
 	ToAddress    string
 	TokenAddress string
 	Amount       *big.Int
+	RequestId    string
+	Consumer     string
+}
+
+type SubmitTokenParams struct {
+	TokenAddress     string
+	TokenName        string
+	Unit             uint8
+	CollectAmount    *big.Int
+	HotHighWatermark *big.Int
+	HotLowWatermark  *big.Int
 }
 
 type QueryDWParams struct {
@@ -47,7 +58,38 @@ type WithdrawsResponse struct {
 	Records []database.Withdraws `json:"Records"`
 }
 
+type DiscrepanciesResponse struct {
+	Current int                             `json:"Current"`
+	Size    int                             `json:"Size"`
+	Total   int64                           `json:"Total"`
+	Records []database.BalanceDiscrepancies `json:"Records"`
+}
+
+type DeadLettersResponse struct {
+	Current int                          `json:"Current"`
+	Size    int                          `json:"Size"`
+	Total   int64                        `json:"Total"`
+	Records []database.NotifyDeadLetters `json:"Records"`
+}
+
+type RedeliverResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
 type SubmitWithdrawsResponse struct {
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Guid   string `json:"guid,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Status uint8  `json:"status"`
+}
+
+type TokensResponse struct {
+	Records []database.Tokens `json:"Records"`
+}
+
+type SubmitTokenResponse struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 }
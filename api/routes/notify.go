@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func (h Routes) DeadLetterListHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	pageQuery := r.URL.Query().Get("page")
+	pageSizeQuery := r.URL.Query().Get("pageSize")
+	order := r.URL.Query().Get("order")
+	params, err := h.svc.QueryPageListParams(pageQuery, pageSizeQuery, order)
+	if err != nil {
+		http.Error(w, "invalid query params", http.StatusBadRequest)
+		log.Error("error reading request params", "err", err.Error())
+		return
+	}
+
+	deadLetterPage, err := h.svc.GetDeadLetterList(status, params)
+	if err != nil {
+		http.Error(w, "invalid status param", http.StatusBadRequest)
+		log.Error("error reading status param", "err", err.Error())
+		return
+	}
+
+	err = jsonResponse(w, deadLetterPage, http.StatusOK)
+	if err != nil {
+		log.Error("Error writing response", "err", err.Error())
+	}
+}
+
+func (h Routes) RedeliverDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	guid := r.URL.Query().Get("guid")
+	redeliverRet, err := h.svc.RedeliverDeadLetter(guid)
+	if err != nil {
+		http.Error(w, "invalid guid param", http.StatusBadRequest)
+		log.Error("error reading guid param", "err", err.Error())
+		return
+	}
+	err = jsonResponse(w, redeliverRet, http.StatusOK)
+	if err != nil {
+		log.Error("Error writing response", "err", err.Error())
+	}
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func (h Routes) TokenListHandler(w http.ResponseWriter, r *http.Request) {
+	tokenList, err := h.svc.GetTokenList()
+	if err != nil {
+		http.Error(w, "Internal server error reading token list", http.StatusInternalServerError)
+		log.Error("Unable to read token list from DB", "err", err.Error())
+		return
+	}
+	err = jsonResponse(w, tokenList, http.StatusOK)
+	if err != nil {
+		log.Error("Error writing response", "err", err.Error())
+	}
+}
+
+func (h Routes) SubmitTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenAddress := r.URL.Query().Get("tokenAddress")
+	tokenName := r.URL.Query().Get("tokenName")
+	unit := r.URL.Query().Get("unit")
+	collectAmount := r.URL.Query().Get("collectAmount")
+	hotHighWatermark := r.URL.Query().Get("hotHighWatermark")
+	hotLowWatermark := r.URL.Query().Get("hotLowWatermark")
+
+	params, err := h.svc.SubmitTokenParams(tokenAddress, tokenName, unit, collectAmount, hotHighWatermark, hotLowWatermark)
+	if err != nil {
+		http.Error(w, "invalid query params", http.StatusBadRequest)
+		log.Error("error reading request params", "err", err.Error())
+		return
+	}
+	tokenRet, err := h.svc.SubmitToken(params)
+	if err != nil {
+		http.Error(w, "Internal server error submitting token", http.StatusInternalServerError)
+		log.Error("Unable to submit token", "err", err.Error())
+		return
+	}
+	err = jsonResponse(w, tokenRet, http.StatusOK)
+	if err != nil {
+		log.Error("Error writing response", "err", err.Error())
+	}
+}
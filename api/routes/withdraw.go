@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/common/auth"
 )
 
 func (h Routes) WithdrawListHandler(w http.ResponseWriter, r *http.Request) {
@@ -36,13 +38,15 @@ func (h Routes) SubmitWithdrawHandler(w http.ResponseWriter, r *http.Request) {
 	toaAdress := r.URL.Query().Get("toAddress")
 	tokenAddress := r.URL.Query().Get("tokenAddress")
 	amount := r.URL.Query().Get("amount")
+	requestId := r.URL.Query().Get("requestId")
 
-	params, err := h.svc.SubmitDWParams(fromAddress, toaAdress, tokenAddress, amount)
+	params, err := h.svc.SubmitDWParams(fromAddress, toaAdress, tokenAddress, amount, requestId)
 	if err != nil {
 		http.Error(w, "invalid query params", http.StatusBadRequest)
 		log.Error("error reading request params", "err", err.Error())
 		return
 	}
+	params.Consumer = auth.ConsumerFromContext(r.Context())
 	withdrawRet, err := h.svc.SubmitWithdrawFromBusiness(params)
 	if err != nil {
 		http.Error(w, "Internal server error reading state root list", http.StatusInternalServerError)
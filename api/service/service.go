@@ -1,11 +1,13 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"strconv"
-	"strings"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
 
 	"github.com/the-web3/sol-wallet/api/models"
 	"github.com/the-web3/sol-wallet/database"
@@ -15,29 +17,40 @@ type Service interface {
 	GetDepositList(*models.QueryDWParams) (*models.DepositsResponse, error)
 	GetWithdrawalList(params *models.QueryDWParams) (*models.WithdrawsResponse, error)
 	SubmitWithdrawFromBusiness(params *models.SubmitDWParams) (*models.SubmitWithdrawsResponse, error)
+	GetDiscrepancyList(status string, params *models.QueryPageParams) (*models.DiscrepanciesResponse, error)
+	GetTokenList() (*models.TokensResponse, error)
+	SubmitToken(params *models.SubmitTokenParams) (*models.SubmitTokenResponse, error)
+	GetDeadLetterList(status string, params *models.QueryPageParams) (*models.DeadLettersResponse, error)
+	RedeliverDeadLetter(guid string) (*models.RedeliverResponse, error)
 
-	SubmitDWParams(fromAddress string, toAddress string, tokenAddress string, amount string) (*models.SubmitDWParams, error)
+	SubmitDWParams(fromAddress string, toAddress string, tokenAddress string, amount string, requestId string) (*models.SubmitDWParams, error)
 	QueryDWListParams(address string, page string, pageSize string, order string) (*models.QueryDWParams, error)
 	QueryPageListParams(page string, pageSize string, order string) (*models.QueryPageParams, error)
+	SubmitTokenParams(tokenAddress, tokenName, unit, collectAmount, hotHighWatermark, hotLowWatermark string) (*models.SubmitTokenParams, error)
 }
 
 type HandlerSvc struct {
 	v             *Validator
 	depositsView  database.DepositsView
 	withdrawsView database.WithdrawsView
+	discrepancies database.BalanceDiscrepanciesView
+	tokensDB      database.TokensDB
+	deadLetters   database.NotifyDeadLettersDB
 }
 
-func New(v *Validator, dsv database.DepositsView, wdv database.WithdrawsView) Service {
+func New(v *Validator, dsv database.DepositsView, wdv database.WithdrawsView, bdv database.BalanceDiscrepanciesView, tkdb database.TokensDB, dldb database.NotifyDeadLettersDB) Service {
 	return &HandlerSvc{
 		v:             v,
 		depositsView:  dsv,
 		withdrawsView: wdv,
+		discrepancies: bdv,
+		tokensDB:      tkdb,
+		deadLetters:   dldb,
 	}
 }
 
 func (h HandlerSvc) GetDepositList(params *models.QueryDWParams) (*models.DepositsResponse, error) {
-	addressToLower := strings.ToLower(params.Address)
-	depositList, total := h.depositsView.ApiDepositList(addressToLower, params.Page, params.PageSize, params.Order)
+	depositList, total := h.depositsView.ApiDepositList(params.Address, params.Page, params.PageSize, params.Order)
 	return &models.DepositsResponse{
 		Current: params.Page,
 		Size:    params.PageSize,
@@ -47,8 +60,7 @@ func (h HandlerSvc) GetDepositList(params *models.QueryDWParams) (*models.Deposi
 }
 
 func (h HandlerSvc) GetWithdrawalList(params *models.QueryDWParams) (*models.WithdrawsResponse, error) {
-	addressToLower := strings.ToLower(params.Address)
-	withdrawList, total := h.withdrawsView.ApiWithdrawList(addressToLower, params.Page, params.PageSize, params.Order)
+	withdrawList, total := h.withdrawsView.ApiWithdrawList(params.Address, params.Page, params.PageSize, params.Order)
 	return &models.WithdrawsResponse{
 		Current: params.Page,
 		Size:    params.PageSize,
@@ -57,42 +69,150 @@ func (h HandlerSvc) GetWithdrawalList(params *models.QueryDWParams) (*models.Wit
 	}, nil
 }
 
+// GetDiscrepancyList status 为空时返回所有状态的对账差异
+func (h HandlerSvc) GetDiscrepancyList(status string, params *models.QueryPageParams) (*models.DiscrepanciesResponse, error) {
+	statusInt := -1
+	if status != "" {
+		value, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, err
+		}
+		statusInt = value
+	}
+	discrepancyList, total := h.discrepancies.ApiDiscrepancyList(statusInt, params.Page, params.PageSize, params.Order)
+	return &models.DiscrepanciesResponse{
+		Current: params.Page,
+		Size:    params.PageSize,
+		Total:   total,
+		Records: discrepancyList,
+	}, nil
+}
+
+// GetDeadLetterList status 为空时返回所有状态的通知死信
+func (h HandlerSvc) GetDeadLetterList(status string, params *models.QueryPageParams) (*models.DeadLettersResponse, error) {
+	statusInt := -1
+	if status != "" {
+		value, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, err
+		}
+		statusInt = value
+	}
+	deadLetterList, total := h.deadLetters.ApiDeadLetterList(statusInt, params.Page, params.PageSize, params.Order)
+	return &models.DeadLettersResponse{
+		Current: params.Page,
+		Size:    params.PageSize,
+		Total:   total,
+		Records: deadLetterList,
+	}, nil
+}
+
+// RedeliverDeadLetter 把死信改为重新投递，通知服务下一轮推送对应的充值或提现
+func (h HandlerSvc) RedeliverDeadLetter(guid string) (*models.RedeliverResponse, error) {
+	deadLetterGuid, err := uuid.Parse(guid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead letter guid %q: %w", guid, err)
+	}
+	if err := h.deadLetters.RequeueDeadLetter(deadLetterGuid); err != nil {
+		log.Error("requeue dead letter fail", "guid", guid, "err", err)
+		return &models.RedeliverResponse{
+			Code: 4000,
+			Msg:  err.Error(),
+		}, nil
+	}
+	return &models.RedeliverResponse{
+		Code: 2000,
+		Msg:  "requeue dead letter success",
+	}, nil
+}
+
+func (h HandlerSvc) GetTokenList() (*models.TokensResponse, error) {
+	tokenList, err := h.tokensDB.QueryTokenList()
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokensResponse{Records: tokenList}, nil
+}
+
+// SubmitToken 新增或更新币种的归集门槛和热钱包水位
+func (h HandlerSvc) SubmitToken(params *models.SubmitTokenParams) (*models.SubmitTokenResponse, error) {
+	err := h.tokensDB.UpsertToken(&database.Tokens{
+		TokenAddress:     params.TokenAddress,
+		Unit:             params.Unit,
+		TokenName:        params.TokenName,
+		CollectAmount:    params.CollectAmount,
+		HotHighWatermark: params.HotHighWatermark,
+		HotLowWatermark:  params.HotLowWatermark,
+	})
+	if err != nil {
+		log.Error("submit token fail", "err", err)
+		return &models.SubmitTokenResponse{
+			Code: 4000,
+			Msg:  "submit token fail",
+		}, nil
+	}
+	return &models.SubmitTokenResponse{
+		Code: 2000,
+		Msg:  "submit token success",
+	}, nil
+}
+
 func (h HandlerSvc) SubmitWithdrawFromBusiness(params *models.SubmitDWParams) (*models.SubmitWithdrawsResponse, error) {
-	err := h.withdrawsView.SubmitWithdrawFromBusiness(params.FromAddress, params.ToAddress, params.ToAddress, params.Amount)
+	withdraw, duplicate, err := h.withdrawsView.SubmitWithdraw(params.Consumer, params.RequestId, params.FromAddress, params.ToAddress, params.TokenAddress, params.Amount)
 	if err != nil {
+		log.Error("submit withdraw fail", "requestId", params.RequestId, "err", err)
+		msg := "submit transaction fail"
+		if errors.Is(err, database.ErrWithdrawRequestConflict) {
+			msg = err.Error()
+		}
 		return &models.SubmitWithdrawsResponse{
 			Code: 4000,
-			Msg:  "submit transaction fail",
+			Msg:  msg,
 		}, nil
 	}
+	msg := "submit transaction success"
+	if duplicate {
+		msg = "duplicate withdraw request"
+	}
 	return &models.SubmitWithdrawsResponse{
-		Code: 2000,
-		Msg:  "submit transaction success",
+		Code:   2000,
+		Msg:    msg,
+		Guid:   withdraw.GUID.String(),
+		Hash:   withdraw.Hash,
+		Status: withdraw.Status,
 	}, nil
 }
 
-func (h HandlerSvc) SubmitDWParams(fromAddress string, toAddress string, tokenAddress string, amount string) (*models.SubmitDWParams, error) {
-	var amountBig *big.Int
-	amountBig.SetString(amount, 10)
+func (h HandlerSvc) SubmitDWParams(fromAddress string, toAddress string, tokenAddress string, amount string, requestId string) (*models.SubmitDWParams, error) {
+	if _, err := h.v.ParseValidateWalletAddress(fromAddress); err != nil {
+		return nil, err
+	}
+	if _, err := h.v.ParseValidateAddress(toAddress); err != nil {
+		return nil, err
+	}
+	if err := h.v.ValidateTokenAddress(tokenAddress); err != nil {
+		return nil, err
+	}
+	amountBig, ok := new(big.Int).SetString(amount, 10)
+	if !ok || amountBig.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
 	return &models.SubmitDWParams{
 		FromAddress:  fromAddress,
 		ToAddress:    toAddress,
 		TokenAddress: tokenAddress,
 		Amount:       amountBig,
+		RequestId:    requestId,
 	}, nil
 }
 
 func (h HandlerSvc) QueryDWListParams(address string, page string, pageSize string, order string) (*models.QueryDWParams, error) {
-	var paraAddress string
-	if address == "0x00" {
-		paraAddress = "0x00"
-	} else {
-		addr, err := h.v.ParseValidateAddress(address)
-		if err != nil {
+	// 地址为空时查询所有地址
+	if address != "" {
+		if _, err := h.v.ParseValidateAddress(address); err != nil {
 			log.Error("invalid address param", "address", address, "err", err)
 			return nil, err
 		}
-		paraAddress = addr.String()
 	}
 
 	pageInt, err := strconv.Atoi(page)
@@ -109,7 +229,7 @@ func (h HandlerSvc) QueryDWListParams(address string, page string, pageSize stri
 	orderBy := h.v.ValidateOrder(order)
 
 	return &models.QueryDWParams{
-		Address:  paraAddress,
+		Address:  address,
 		Page:     pageVal,
 		PageSize: pageSizeVal,
 		Order:    orderBy,
@@ -134,3 +254,35 @@ func (h HandlerSvc) QueryPageListParams(page string, pageSize string, order stri
 		Order:    orderBy,
 	}, nil
 }
+
+func (h HandlerSvc) SubmitTokenParams(tokenAddress, tokenName, unit, collectAmount, hotHighWatermark, hotLowWatermark string) (*models.SubmitTokenParams, error) {
+	if tokenName == "" {
+		return nil, errors.New("token name is required")
+	}
+	if err := h.v.ValidateTokenAddress(tokenAddress); err != nil {
+		return nil, err
+	}
+	unitInt, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make([]*big.Int, 0, 3)
+	for _, value := range []string{collectAmount, hotHighWatermark, hotLowWatermark} {
+		if value == "" {
+			value = "0"
+		}
+		amount, ok := new(big.Int).SetString(value, 10)
+		if !ok || amount.Sign() < 0 {
+			return nil, fmt.Errorf("invalid amount %q", value)
+		}
+		amounts = append(amounts, amount)
+	}
+	return &models.SubmitTokenParams{
+		TokenAddress:     tokenAddress,
+		TokenName:        tokenName,
+		Unit:             uint8(unitInt),
+		CollectAmount:    amounts[0],
+		HotHighWatermark: amounts[1],
+		HotLowWatermark:  amounts[2],
+	}, nil
+}
@@ -3,23 +3,26 @@ package service
 import (
 	"errors"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/blocto/solana-go-sdk/common"
+
+	"github.com/the-web3/sol-wallet/common/address"
 )
 
 type Validator struct{}
 
-func (v *Validator) ParseValidateAddress(addr string) (common.Address, error) {
-	var parsedAddr common.Address
-	if addr != "0x00" {
-		if !common.IsHexAddress(addr) {
-			return common.Address{}, errors.New("address must be represented as a valid hexadecimal string")
-		}
-		parsedAddr = common.HexToAddress(addr)
-		if parsedAddr == common.HexToAddress("0x0") {
-			return common.Address{}, errors.New("address cannot be the zero address")
-		}
-	}
-	return parsedAddr, nil
+// ParseValidateAddress 校验 base58 编码的 Solana 地址，解码后必须是 32 字节
+func (v *Validator) ParseValidateAddress(addr string) (common.PublicKey, error) {
+	return address.ParseSolanaAddress(addr, address.AnyKey)
+}
+
+// ParseValidateWalletAddress 校验有私钥的钱包地址，公钥必须在 ed25519 曲线上，PDA 不能作为发送方
+func (v *Validator) ParseValidateWalletAddress(addr string) (common.PublicKey, error) {
+	return address.ParseSolanaAddress(addr, address.OnCurve)
+}
+
+// ValidateTokenAddress 校验币种地址，空字符串表示 SOL
+func (v *Validator) ValidateTokenAddress(addr string) error {
+	return address.ParseTokenAddress(addr)
 }
 
 func (v *Validator) ValidatePage(page int) int {
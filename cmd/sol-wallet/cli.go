@@ -73,6 +73,310 @@ func runGenerateAddress(ctx *cli.Context) error {
 	return tools.CreateAddressTools(ctx, &cfg, db)
 }
 
+func runCreateNonceAccount(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	return tools.CreateNonceAccountTools(ctx, &cfg, db)
+}
+
+var (
+	syncStateWorkerFlag = &cli.StringFlag{
+		Name:  "worker",
+		Usage: "The scanner worker name of the sync cursor",
+		Value: database.DepositSyncWorker,
+	}
+	syncStateSlotFlag = &cli.Uint64Flag{
+		Name:     "slot",
+		Usage:    "The last processed slot, scanning resumes from slot+1",
+		Required: true,
+	}
+)
+
+func runShowSyncState(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ShowSyncStateTools(db)
+}
+
+func runResetSyncState(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ResetSyncStateTools(db, ctx.String(syncStateWorkerFlag.Name), ctx.Uint64(syncStateSlotFlag.Name))
+}
+
+var (
+	backfillAddressFlag = &cli.StringFlag{
+		Name:  "address",
+		Usage: "The address to backfill, all user and hot wallet addresses when empty",
+	}
+	backfillFromSlotFlag = &cli.Uint64Flag{
+		Name:     "from-slot",
+		Usage:    "Backfill transactions at or after this slot",
+		Required: true,
+	}
+)
+
+func runBackfill(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.BackfillTools(ctx, &cfg, db, ctx.String(backfillAddressFlag.Name), ctx.Uint64(backfillFromSlotFlag.Name))
+}
+
+var rebuildDryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "Only print the balances that would change",
+}
+
+func runRebuildBalances(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.RebuildBalancesTools(db, ctx.Bool(rebuildDryRunFlag.Name))
+}
+
+var (
+	tokenAddressFlag = &cli.StringFlag{
+		Name:  "token-address",
+		Usage: "The mint address of the token, empty for SOL",
+	}
+	tokenNameFlag = &cli.StringFlag{
+		Name:     "token-name",
+		Usage:    "The name of the token",
+		Required: true,
+	}
+	tokenUnitFlag = &cli.UintFlag{
+		Name:  "unit",
+		Usage: "The decimals of the token",
+		Value: 9,
+	}
+	tokenCollectAmountFlag = &cli.StringFlag{
+		Name:     "collect-amount",
+		Usage:    "Collect user balances at or above this amount in base units",
+		Required: true,
+	}
+	tokenHotHighWatermarkFlag = &cli.StringFlag{
+		Name:  "hot-high-watermark",
+		Usage: "Transfer hot wallet balance above this amount to cold wallet, 0 disables cold transfer",
+		Value: "0",
+	}
+	tokenHotLowWatermarkFlag = &cli.StringFlag{
+		Name:  "hot-low-watermark",
+		Usage: "The hot wallet balance kept after a cold transfer",
+		Value: "0",
+	}
+)
+
+func runListTokens(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ListTokensTools(db)
+}
+
+func runSetToken(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.SetTokenTools(db, ctx.String(tokenAddressFlag.Name), ctx.String(tokenNameFlag.Name), uint8(ctx.Uint(tokenUnitFlag.Name)),
+		ctx.String(tokenCollectAmountFlag.Name), ctx.String(tokenHotHighWatermarkFlag.Name), ctx.String(tokenHotLowWatermarkFlag.Name))
+}
+
+var (
+	coldHotStatusFlag = &cli.IntFlag{
+		Name:  "status",
+		Usage: "Only list requests in this status, negative for all",
+		Value: database.ColdHotAwaitingSign,
+	}
+	coldHotGuidFlag = &cli.StringFlag{
+		Name:     "guid",
+		Usage:    "The guid of the cold to hot request",
+		Required: true,
+	}
+	coldHotRawTxFlag = &cli.StringFlag{
+		Name:     "raw-tx",
+		Usage:    "The base58 encoded transaction signed offline by the cold wallet",
+		Required: true,
+	}
+)
+
+func runListColdHotRequests(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ListColdHotRequestsTools(db, ctx.Int(coldHotStatusFlag.Name))
+}
+
+func runImportColdHotTx(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ImportColdHotTxTools(&cfg, db, ctx.String(coldHotGuidFlag.Name), ctx.String(coldHotRawTxFlag.Name))
+}
+
+func runCancelColdHotRequest(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.CancelColdHotRequestTools(db, ctx.String(coldHotGuidFlag.Name))
+}
+
+var (
+	consumerNameFlag = &cli.StringFlag{
+		Name:     "name",
+		Usage:    "The name of the consumer, recorded on its withdrawals and audit logs",
+		Required: true,
+	}
+	consumerScopesFlag = &cli.StringFlag{
+		Name:     "scopes",
+		Usage:    "Comma separated grpc method names (e.g. submitWithdrawInfo) or rest routes (e.g. \"GET /api/v1/deposits\"), * for all",
+		Required: true,
+	}
+)
+
+func runListConsumers(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.ListConsumersTools(db)
+}
+
+func runIssueConsumer(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.IssueConsumerTools(db, ctx.String(consumerNameFlag.Name), ctx.String(consumerScopesFlag.Name))
+}
+
+func runRevokeConsumer(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.RevokeConsumerTools(db, ctx.String(consumerNameFlag.Name))
+}
+
+func runRotateWebhookSecret(ctx *cli.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "err", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer db.Close()
+	return tools.RotateWebhookSecretTools(db, ctx.String(consumerNameFlag.Name))
+}
+
 func runMigrations(ctx *cli.Context) error {
 	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
 	log.Info("running migrations...")
@@ -120,6 +424,115 @@ func NewCli(GitCommit string, GitData string) *cli.App {
 				Description: "Run grenerate adddress tools",
 				Action:      runGenerateAddress,
 			},
+			{
+				Name:        "create-nonce-account",
+				Flags:       flags,
+				Description: "Create durable nonce accounts for hot and cold wallet",
+				Action:      runCreateNonceAccount,
+			},
+			{
+				Name:        "sync-state",
+				Description: "Show or reset the scanner sync cursor",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "show",
+						Flags:       flags,
+						Description: "Show the last processed slot of every scanner worker",
+						Action:      runShowSyncState,
+					},
+					{
+						Name:        "reset",
+						Flags:       append([]cli.Flag{syncStateWorkerFlag, syncStateSlotFlag}, flags...),
+						Description: "Reset the last processed slot of a scanner worker",
+						Action:      runResetSyncState,
+					},
+				},
+			},
+			{
+				Name:        "backfill",
+				Flags:       append([]cli.Flag{backfillAddressFlag, backfillFromSlotFlag}, flags...),
+				Description: "Backfill missed deposits by walking the signatures of addresses",
+				Action:      runBackfill,
+			},
+			{
+				Name:        "rebuild-balances",
+				Flags:       append([]cli.Flag{rebuildDryRunFlag}, flags...),
+				Description: "Rebuild the balances table from the balance journal",
+				Action:      runRebuildBalances,
+			},
+			{
+				Name:        "tokens",
+				Description: "Manage the token registry",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "list",
+						Flags:       flags,
+						Description: "List registered tokens with collect thresholds and hot wallet watermarks",
+						Action:      runListTokens,
+					},
+					{
+						Name: "set",
+						Flags: append([]cli.Flag{tokenAddressFlag, tokenNameFlag, tokenUnitFlag, tokenCollectAmountFlag,
+							tokenHotHighWatermarkFlag, tokenHotLowWatermarkFlag}, flags...),
+						Description: "Register a token or update its collect threshold and hot wallet watermarks",
+						Action:      runSetToken,
+					},
+				},
+			},
+			{
+				Name:        "cold-to-hot",
+				Description: "Manage cold to hot replenishment requests signed offline",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "list",
+						Flags:       append([]cli.Flag{coldHotStatusFlag}, flags...),
+						Description: "List cold to hot requests",
+						Action:      runListColdHotRequests,
+					},
+					{
+						Name:        "import",
+						Flags:       append([]cli.Flag{coldHotGuidFlag, coldHotRawTxFlag}, flags...),
+						Description: "Import an offline signed cold to hot transaction and broadcast it",
+						Action:      runImportColdHotTx,
+					},
+					{
+						Name:        "cancel",
+						Flags:       append([]cli.Flag{coldHotGuidFlag}, flags...),
+						Description: "Cancel a cold to hot request waiting for signature",
+						Action:      runCancelColdHotRequest,
+					},
+				},
+			},
+			{
+				Name:        "consumers",
+				Description: "Manage consumer tokens for the rpc and rest api",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "list",
+						Flags:       flags,
+						Description: "List consumers and their allowed methods",
+						Action:      runListConsumers,
+					},
+					{
+						Name:        "issue",
+						Flags:       append([]cli.Flag{consumerNameFlag, consumerScopesFlag}, flags...),
+						Description: "Issue a consumer token and webhook secret, the token is printed only once",
+						Action:      runIssueConsumer,
+					},
+					{
+						Name:        "revoke",
+						Flags:       append([]cli.Flag{consumerNameFlag}, flags...),
+						Description: "Revoke a consumer token",
+						Action:      runRevokeConsumer,
+					},
+					{
+						Name:        "rotate-webhook-secret",
+						Flags:       append([]cli.Flag{consumerNameFlag}, flags...),
+						Description: "Regenerate the secret signing webhooks sent to a consumer",
+						Action:      runRotateWebhookSecret,
+					},
+				},
+			},
 			{
 				Name:        "wallet",
 				Flags:       flags,
@@ -0,0 +1,57 @@
+package address
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
+)
+
+// Requirement 对地址公钥是否在 ed25519 曲线上的要求
+type Requirement int
+
+const (
+	AnyKey   Requirement = iota // 不检查
+	OnCurve                     // 普通钱包地址，有对应的私钥
+	OffCurve                    // PDA 等程序派生地址，没有私钥
+)
+
+var (
+	ErrEmptyAddress   = errors.New("address is empty")
+	ErrInvalidAddress = errors.New("address must be a base58 encoded 32 byte public key")
+	ErrNotOnCurve     = errors.New("address is not on the ed25519 curve")
+	ErrNotOffCurve    = errors.New("address is on the ed25519 curve, not a program derived address")
+)
+
+// ParseSolanaAddress 校验 base58 编码的 Solana 地址，解码后必须是 32 字节，并按 requirement 检查是否在曲线上
+func ParseSolanaAddress(addr string, requirement Requirement) (common.PublicKey, error) {
+	if addr == "" {
+		return common.PublicKey{}, ErrEmptyAddress
+	}
+	data, err := base58.Decode(addr)
+	if err != nil || len(data) != common.PublicKeyLength {
+		return common.PublicKey{}, fmt.Errorf("%w: %q", ErrInvalidAddress, addr)
+	}
+	publicKey := common.PublicKeyFromBytes(data)
+	switch requirement {
+	case OnCurve:
+		if !common.IsOnCurve(publicKey) {
+			return common.PublicKey{}, fmt.Errorf("%w: %q", ErrNotOnCurve, addr)
+		}
+	case OffCurve:
+		if common.IsOnCurve(publicKey) {
+			return common.PublicKey{}, fmt.Errorf("%w: %q", ErrNotOffCurve, addr)
+		}
+	}
+	return publicKey, nil
+}
+
+// ParseTokenAddress 校验币种地址，空字符串表示 SOL
+func ParseTokenAddress(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	_, err := ParseSolanaAddress(addr, AnyKey)
+	return err
+}
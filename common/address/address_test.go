@@ -0,0 +1,38 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSolanaAddress(t *testing.T) {
+	const wallet = "4wHd9tf4x4FkQ3JtgsMKyiEofEHSaZH5rYzfFKLvtESD"
+	publicKey, err := ParseSolanaAddress(wallet, OnCurve)
+	require.NoError(t, err)
+	require.Equal(t, wallet, publicKey.ToBase58())
+
+	mint := common.PublicKeyFromString("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+	pda, _, err := common.FindAssociatedTokenAddress(common.PublicKeyFromString(wallet), mint)
+	require.NoError(t, err)
+	_, err = ParseSolanaAddress(pda.ToBase58(), OffCurve)
+	require.NoError(t, err)
+	_, err = ParseSolanaAddress(pda.ToBase58(), OnCurve)
+	require.ErrorIs(t, err, ErrNotOnCurve)
+	_, err = ParseSolanaAddress(wallet, OffCurve)
+	require.ErrorIs(t, err, ErrNotOffCurve)
+
+	for _, invalid := range []string{
+		"0x00",
+		"0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+		"4wHd9tf4x4FkQ3JtgsMKyiEofEH",
+		"4whd9tf4x4fkq3jtgsmkyieofehsazh5ryzffklvtesd",
+	} {
+		_, err := ParseSolanaAddress(invalid, AnyKey)
+		require.ErrorIs(t, err, ErrInvalidAddress, invalid)
+	}
+	_, err = ParseSolanaAddress("", AnyKey)
+	require.ErrorIs(t, err, ErrEmptyAddress)
+	require.NoError(t, ParseTokenAddress(""))
+}
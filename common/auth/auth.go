@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+var (
+	ErrMissingToken     = errors.New("consumer token is required")
+	ErrInvalidToken     = errors.New("invalid consumer token")
+	ErrPermissionDenied = errors.New("consumer is not allowed to call this method")
+)
+
+type consumerKey struct{}
+
+// Authenticator 按 token 的 sha256 查询业务方并检查方法权限，gRPC 拦截器和 REST 中间件共用
+type Authenticator struct {
+	consumers database.ConsumersView
+}
+
+func NewAuthenticator(consumers database.ConsumersView) *Authenticator {
+	return &Authenticator{consumers: consumers}
+}
+
+func (a *Authenticator) Authenticate(token string, method string) (*database.Consumers, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	consumer, err := a.consumers.QueryConsumerByTokenHash(HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if consumer == nil {
+		return nil, ErrInvalidToken
+	}
+	if !consumer.Allows(method) {
+		return nil, ErrPermissionDenied
+	}
+	return consumer, nil
+}
+
+// GenerateToken 生成 32 字节随机 token，返回 hex 编码的 token 和它的 hash
+func GenerateToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func WithConsumer(ctx context.Context, consumer *database.Consumers) context.Context {
+	return context.WithValue(ctx, consumerKey{}, consumer)
+}
+
+// ConsumerFromContext 返回认证通过的业务方名称，没有认证时返回空字符串
+func ConsumerFromContext(ctx context.Context) string {
+	consumer, ok := ctx.Value(consumerKey{}).(*database.Consumers)
+	if !ok || consumer == nil {
+		return ""
+	}
+	return consumer.Name
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+type fakeConsumers struct {
+	consumers map[string]*database.Consumers
+}
+
+func (f *fakeConsumers) QueryConsumerByTokenHash(tokenHash string) (*database.Consumers, error) {
+	return f.consumers[tokenHash], nil
+}
+
+func (f *fakeConsumers) QueryConsumerByName(name string) (*database.Consumers, error) {
+	return nil, nil
+}
+
+func (f *fakeConsumers) QueryConsumerList() ([]database.Consumers, error) {
+	return nil, nil
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	token, tokenHash, err := GenerateToken()
+	require.NoError(t, err)
+	require.Equal(t, HashToken(token), tokenHash)
+
+	authenticator := NewAuthenticator(&fakeConsumers{consumers: map[string]*database.Consumers{
+		tokenHash: {Name: "exchange", Scopes: "submitWithdrawInfo, GET /api/v1/deposits"},
+	}})
+
+	consumer, err := authenticator.Authenticate(token, "submitWithdrawInfo")
+	require.NoError(t, err)
+	require.Equal(t, "exchange", consumer.Name)
+	require.Equal(t, "exchange", ConsumerFromContext(WithConsumer(context.Background(), consumer)))
+
+	_, err = authenticator.Authenticate(token, "GET /api/v1/deposits")
+	require.NoError(t, err)
+
+	_, err = authenticator.Authenticate(token, "verifyAddress")
+	require.ErrorIs(t, err, ErrPermissionDenied)
+
+	_, err = authenticator.Authenticate("", "submitWithdrawInfo")
+	require.ErrorIs(t, err, ErrMissingToken)
+
+	_, err = authenticator.Authenticate("unknown", "submitWithdrawInfo")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	require.Equal(t, "", ConsumerFromContext(context.Background()))
+}
@@ -0,0 +1,191 @@
+// Package webhook 对钱包推送给业务层的 webhook 做 HMAC-SHA256 签名和校验，业务方可以直接引入 Verifier 校验回调。
+//
+// 签名方式：MsgHash = hex(sha256(timestamp + "." + nonce + "." + eventId + "." + body))，Signature = hex(hmac_sha256(secret, MsgHash))。
+// MsgHash 和 Signature 也可以作为 RiskDOrWNotifyVerifyReq 的 MsgHash、SignMsg 交给钱包 gRPC 接口校验。
+//
+// nonce 每次投递随机生成，用于拒绝重放的请求；event id 在重试和人工重新投递时不变，只用于业务方对同一个充值或提现去重，
+// 不能用来做重放校验，否则重试会被当成重放拒绝
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderEventId   = "X-Sol-Wallet-Event-Id"
+	HeaderNonce     = "X-Sol-Wallet-Nonce"
+	HeaderTimestamp = "X-Sol-Wallet-Timestamp"
+	HeaderSignature = "X-Sol-Wallet-Signature"
+
+	// DefaultTolerance 回调时间戳和本地时间允许的最大偏差
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrMissingHeader    = errors.New("missing webhook signature headers")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrTimestampExpired = errors.New("webhook timestamp is outside the tolerance")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrReplayed         = errors.New("webhook delivery has already been received")
+)
+
+// GenerateSecret 生成 32 字节 hex 编码的随机密钥
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GenerateNonce 生成每次投递使用的 16 字节 hex 编码随机数
+func GenerateNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func MsgHash(timestamp int64, nonce string, eventId string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write([]byte(nonce))
+	h.Write([]byte("."))
+	h.Write([]byte(eventId))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func Sign(secret string, msgHash string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msgHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 常数时间比较签名
+func VerifySignature(secret string, msgHash string, signature string) bool {
+	expected, err := hex.DecodeString(Sign(secret, msgHash))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
+
+// SetHeaders 签名并写入回调请求头，nonce 每次投递都要重新生成
+func SetHeaders(header http.Header, secret string, eventId string, nonce string, timestamp int64, body []byte) {
+	header.Set(HeaderEventId, eventId)
+	header.Set(HeaderNonce, nonce)
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	header.Set(HeaderSignature, Sign(secret, MsgHash(timestamp, nonce, eventId, body)))
+}
+
+// ReplayGuard 记录已经收到的投递 nonce，Seen 在 nonce 第一次出现时返回 false
+type ReplayGuard interface {
+	Seen(nonce string) bool
+}
+
+// Verifier 校验回调签名、时间戳，设置 Guard 后拒绝重复的 nonce。同一个 event id 的重试使用新的 nonce，不会被拒绝
+type Verifier struct {
+	Secret    string
+	Tolerance time.Duration
+	Guard     ReplayGuard
+
+	now func() time.Time
+}
+
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{
+		Secret:    secret,
+		Tolerance: DefaultTolerance,
+		Guard:     NewMemoryReplayGuard(DefaultTolerance),
+	}
+}
+
+// Verify 校验通过时返回 event id，业务方用它对重复投递的同一个事件去重
+func (v *Verifier) Verify(header http.Header, body []byte) (string, error) {
+	eventId := header.Get(HeaderEventId)
+	nonce := header.Get(HeaderNonce)
+	timestampStr := header.Get(HeaderTimestamp)
+	signature := header.Get(HeaderSignature)
+	if eventId == "" || nonce == "" || timestampStr == "" || signature == "" {
+		return "", ErrMissingHeader
+	}
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return "", ErrInvalidTimestamp
+	}
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+	if v.Tolerance > 0 {
+		diff := now.Sub(time.Unix(timestamp, 0))
+		if diff > v.Tolerance || diff < -v.Tolerance {
+			return "", ErrTimestampExpired
+		}
+	}
+	if !VerifySignature(v.Secret, MsgHash(timestamp, nonce, eventId, body), signature) {
+		return "", ErrInvalidSignature
+	}
+	if v.Guard != nil && v.Guard.Seen(nonce) {
+		return "", ErrReplayed
+	}
+	return eventId, nil
+}
+
+// VerifyRequest 读取并校验请求 body，校验后 r.Body 仍可以重新读取
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if _, err := v.Verify(r.Header, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// MemoryReplayGuard 进程内记录 ttl 内收到的 nonce，多实例部署时应使用共享存储实现 ReplayGuard
+type MemoryReplayGuard struct {
+	ttl  time.Duration
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewMemoryReplayGuard(ttl time.Duration) *MemoryReplayGuard {
+	return &MemoryReplayGuard{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+func (g *MemoryReplayGuard) Seen(nonce string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for id, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, id)
+		}
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return true
+	}
+	g.seen[nonce] = now.Add(g.ttl)
+	return false
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	body := []byte(`{"type":"deposit","guid":"guid-1"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	header := http.Header{}
+	SetHeaders(header, secret, "event-1", "nonce-1", now.Unix(), body)
+
+	verifier := NewVerifier(secret)
+	verifier.now = func() time.Time { return now.Add(time.Minute) }
+	eventId, err := verifier.Verify(header, body)
+	require.NoError(t, err)
+	require.Equal(t, "event-1", eventId)
+
+	// 同一次投递重放
+	_, err = verifier.Verify(header, body)
+	require.ErrorIs(t, err, ErrReplayed)
+
+	// 同一个事件重试使用新的 nonce，返回相同的 event id 给业务方去重
+	retried := http.Header{}
+	SetHeaders(retried, secret, "event-1", "nonce-2", now.Unix(), body)
+	eventId, err = verifier.Verify(retried, body)
+	require.NoError(t, err)
+	require.Equal(t, "event-1", eventId)
+
+	_, err = NewVerifier("other-secret").Verify(header, body)
+	require.ErrorIs(t, err, ErrTimestampExpired)
+
+	other := NewVerifier("other-secret")
+	other.now = verifier.now
+	_, err = other.Verify(header, body)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	tampered := NewVerifier(secret)
+	tampered.now = verifier.now
+	_, err = tampered.Verify(header, []byte(`{"type":"deposit","guid":"guid-2"}`))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = tampered.Verify(http.Header{}, body)
+	require.ErrorIs(t, err, ErrMissingHeader)
+
+	require.True(t, VerifySignature(secret, MsgHash(now.Unix(), "nonce-1", "event-1", body), header.Get(HeaderSignature)))
+}
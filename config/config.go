@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/urfave/cli/v2"
 	"time"
 
@@ -10,12 +11,18 @@ import (
 )
 
 const (
-	defaulConfirmations     = 64
+	defaultCommitment       = "finalized"
 	defaultDepositInterval  = 5000
 	defaultWithdrawInterval = 500
 	defaultCollectInterval  = 500
 	defaultColdInterval     = 500
 	defaultBlocksStep       = 500
+	defaultFetchConcurrency = 4
+
+	defaultReconcileInterval = 10 * time.Minute
+	defaultCollectionFee     = 5000
+	defaultNotifyInterval    = 5 * time.Second
+	defaultNotifyMaxAttempts = 5
 )
 
 type Config struct {
@@ -30,18 +37,45 @@ type Config struct {
 	HTTPServer         ServerConfig
 	MetricsServer      ServerConfig
 	SignServerProvider string
+	Notify             NotifyConfig
 }
 
 type ChainConfig struct {
 	ChainID          uint
 	RpcUrl           string
+	WsUrl            string
+	BackupRpcUrls    []string
+	RpcMaxSlotLag    uint64
 	StartingHeight   uint
-	Confirmations    uint
+	Commitment       string
+	DurableNonce     bool
 	DepositInterval  uint
 	WithdrawInterval uint
 	CollectInterval  uint
 	ColdInterval     uint
 	BlocksStep       uint
+	// FetchConcurrency 扫块时并发获取区块的数量，FetchRateLimit 为每秒最多的 getBlock 请求数，0 表示不限制
+	FetchConcurrency uint
+	FetchRateLimit   uint
+	// ReconcileInterval 链上余额对账的间隔，ReconcileAutoCorrect 为 true 时连续两次对账差异不变会按链上余额修正可用余额
+	ReconcileInterval    time.Duration
+	ReconcileAutoCorrect bool
+	// CollectionFee 归集交易的手续费(lamports)，CollectionRentReserve 为 true 时 SOL 归集给用户地址保留免租金最低余额，
+	// CloseTokenAccount 为 true 时 token 归集后关闭用户的 token 账户，租金退回热钱包
+	CollectionFee         uint64
+	CollectionRentReserve bool
+	CloseTokenAccount     bool
+}
+
+// NotifyConfig 充值和提现通知业务层的配置，GrpcUrl 和 WebhookUrl 都为空时不推送，
+// webhook 按记录所属的业务方的密钥签名，充值等没有业务方的记录使用 WebhookConsumer 的密钥
+type NotifyConfig struct {
+	GrpcUrl         string
+	WebhookUrl      string
+	WebhookConsumer string
+	ConsumerToken   string
+	Interval        time.Duration
+	MaxAttempts     int
 }
 
 type DBConfig struct {
@@ -68,8 +102,11 @@ func LoadConfig(cliCtx *cli.Context) (Config, error) {
 	var cfg Config
 	cfg = NewConfig(cliCtx)
 
-	if cfg.Chain.Confirmations == 0 {
-		cfg.Chain.Confirmations = defaulConfirmations
+	if cfg.Chain.Commitment == "" {
+		cfg.Chain.Commitment = defaultCommitment
+	}
+	if cfg.Chain.Commitment != "confirmed" && cfg.Chain.Commitment != "finalized" {
+		return cfg, fmt.Errorf("unsupported commitment %q, must be confirmed or finalized", cfg.Chain.Commitment)
 	}
 
 	if cfg.Chain.DepositInterval == 0 {
@@ -92,6 +129,26 @@ func LoadConfig(cliCtx *cli.Context) (Config, error) {
 		cfg.Chain.BlocksStep = defaultBlocksStep
 	}
 
+	if cfg.Chain.FetchConcurrency == 0 {
+		cfg.Chain.FetchConcurrency = defaultFetchConcurrency
+	}
+
+	if cfg.Chain.ReconcileInterval == 0 {
+		cfg.Chain.ReconcileInterval = defaultReconcileInterval
+	}
+
+	if cfg.Chain.CollectionFee == 0 {
+		cfg.Chain.CollectionFee = defaultCollectionFee
+	}
+
+	if cfg.Notify.Interval == 0 {
+		cfg.Notify.Interval = defaultNotifyInterval
+	}
+
+	if cfg.Notify.MaxAttempts <= 0 {
+		cfg.Notify.MaxAttempts = defaultNotifyMaxAttempts
+	}
+
 	log.Info("loaded chain config", "config", cfg.Chain)
 	return cfg, nil
 }
@@ -100,15 +157,26 @@ func NewConfig(ctx *cli.Context) Config {
 	return Config{
 		Migrations: ctx.String(flags.MigrationsFlag.Name),
 		Chain: ChainConfig{
-			ChainID:          ctx.Uint(flags.ChainIdFlag.Name),
-			RpcUrl:           ctx.String(flags.RpcUrlFlag.Name),
-			StartingHeight:   ctx.Uint(flags.StartingHeightFlag.Name),
-			Confirmations:    ctx.Uint(flags.ConfirmationsFlag.Name),
-			DepositInterval:  ctx.Uint(flags.DepositIntervalFlag.Name),
-			WithdrawInterval: ctx.Uint(flags.WithdrawIntervalFlag.Name),
-			CollectInterval:  ctx.Uint(flags.CollectIntervalFlag.Name),
-			ColdInterval:     ctx.Uint(flags.ColdIntervalFlag.Name),
-			BlocksStep:       ctx.Uint(flags.BlocksStepFlag.Name),
+			ChainID:               ctx.Uint(flags.ChainIdFlag.Name),
+			RpcUrl:                ctx.String(flags.RpcUrlFlag.Name),
+			WsUrl:                 ctx.String(flags.WsUrlFlag.Name),
+			BackupRpcUrls:         ctx.StringSlice(flags.BackupRpcUrlsFlag.Name),
+			RpcMaxSlotLag:         ctx.Uint64(flags.RpcMaxSlotLagFlag.Name),
+			StartingHeight:        ctx.Uint(flags.StartingHeightFlag.Name),
+			Commitment:            ctx.String(flags.CommitmentFlag.Name),
+			DurableNonce:          ctx.Bool(flags.DurableNonceFlag.Name),
+			DepositInterval:       ctx.Uint(flags.DepositIntervalFlag.Name),
+			WithdrawInterval:      ctx.Uint(flags.WithdrawIntervalFlag.Name),
+			CollectInterval:       ctx.Uint(flags.CollectIntervalFlag.Name),
+			ColdInterval:          ctx.Uint(flags.ColdIntervalFlag.Name),
+			BlocksStep:            ctx.Uint(flags.BlocksStepFlag.Name),
+			FetchConcurrency:      ctx.Uint(flags.BlockFetchConcurrencyFlag.Name),
+			FetchRateLimit:        ctx.Uint(flags.BlockFetchRateLimitFlag.Name),
+			ReconcileInterval:     ctx.Duration(flags.ReconcileIntervalFlag.Name),
+			ReconcileAutoCorrect:  ctx.Bool(flags.ReconcileAutoCorrectFlag.Name),
+			CollectionFee:         ctx.Uint64(flags.CollectionFeeFlag.Name),
+			CollectionRentReserve: ctx.Bool(flags.CollectionRentReserveFlag.Name),
+			CloseTokenAccount:     ctx.Bool(flags.CloseTokenAccountFlag.Name),
 		},
 		MasterDB: DBConfig{
 			Host:     ctx.String(flags.MasterDbHostFlag.Name),
@@ -145,5 +213,13 @@ func NewConfig(ctx *cli.Context) Config {
 			Port: ctx.Int(flags.MetricsPortFlag.Name),
 		},
 		SignServerProvider: ctx.String(flags.SignServerProviderFlag.Name),
+		Notify: NotifyConfig{
+			GrpcUrl:         ctx.String(flags.NotifyGrpcUrlFlag.Name),
+			WebhookUrl:      ctx.String(flags.NotifyWebhookUrlFlag.Name),
+			WebhookConsumer: ctx.String(flags.NotifyWebhookConsumerFlag.Name),
+			ConsumerToken:   ctx.String(flags.NotifyConsumerTokenFlag.Name),
+			Interval:        ctx.Duration(flags.NotifyIntervalFlag.Name),
+			MaxAttempts:     ctx.Int(flags.NotifyMaxAttemptsFlag.Name),
+		},
 	}
 }
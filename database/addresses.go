@@ -20,6 +20,7 @@ type AddressesView interface {
 	QueryAddressesByToAddress(string) (*Addresses, error)
 	QueryHotWalletInfo() (*Addresses, error)
 	QueryColdWalletInfo() (*Addresses, error)
+	QueryAddressListByType(addressTypes ...uint8) ([]Addresses, error)
 }
 
 type AddressesDB interface {
@@ -76,3 +77,12 @@ func (db *addressesDB) QueryColdWalletInfo() (*Addresses, error) {
 	}
 	return &addressEntry, nil
 }
+
+func (db *addressesDB) QueryAddressListByType(addressTypes ...uint8) ([]Addresses, error) {
+	var addressList []Addresses
+	err := db.gorm.Table("addresses").Where("address_type IN ?", addressTypes).Find(&addressList).Error
+	if err != nil {
+		return nil, err
+	}
+	return addressList, nil
+}
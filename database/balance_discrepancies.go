@@ -0,0 +1,126 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BalanceDiscrepancies 对账发现的链上余额和钱包余额(balance + lock_balance)不一致的记录
+type BalanceDiscrepancies struct {
+	GUID             uuid.UUID `gorm:"primaryKey" json:"guid"`
+	Address          string    `json:"address"`
+	TokenAddress     string    `json:"token_address"`
+	AddressType      uint8     `json:"address_type"` //0:用户地址；1:热钱包地址(归集地址)；2:冷钱包地址
+	ChainBalance     *big.Int  `gorm:"serializer:u256;column:chain_balance" db:"chain_balance" json:"ChainBalance" form:"chain_balance"`
+	WalletBalance    *big.Int  `gorm:"serializer:u256;column:wallet_balance" db:"wallet_balance" json:"WalletBalance" form:"wallet_balance"`
+	Status           uint8     `json:"status"` // 0:未处理；1:已恢复一致；2:已自动修正
+	CheckedTimestamp uint64    `json:"checked_timestamp"`
+	Timestamp        uint64
+}
+
+type BalanceDiscrepanciesView interface {
+	QueryOpenDiscrepancy(address, tokenAddress string) (*BalanceDiscrepancies, error)
+	QueryOpenDiscrepancies() ([]BalanceDiscrepancies, error)
+	ApiDiscrepancyList(status int, page int, pageSize int, order string) ([]BalanceDiscrepancies, int64)
+}
+
+type BalanceDiscrepanciesDB interface {
+	BalanceDiscrepanciesView
+
+	StoreDiscrepancy(discrepancy *BalanceDiscrepancies) error
+	UpdateDiscrepancyStatus(guid uuid.UUID, status uint8) error
+	ResolveDiscrepancy(address, tokenAddress string) error
+}
+
+type balanceDiscrepanciesDB struct {
+	gorm *gorm.DB
+}
+
+func NewBalanceDiscrepanciesDB(db *gorm.DB) BalanceDiscrepanciesDB {
+	return &balanceDiscrepanciesDB{gorm: db}
+}
+
+func (db *balanceDiscrepanciesDB) QueryOpenDiscrepancy(address, tokenAddress string) (*BalanceDiscrepancies, error) {
+	var discrepancy BalanceDiscrepancies
+	err := db.gorm.Table("balance_discrepancies").Where("address = ? and token_address = ? and status = ?", address, tokenAddress, 0).Take(&discrepancy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &discrepancy, nil
+}
+
+func (db *balanceDiscrepanciesDB) QueryOpenDiscrepancies() ([]BalanceDiscrepancies, error) {
+	var discrepancyList []BalanceDiscrepancies
+	err := db.gorm.Table("balance_discrepancies").Where("status = ?", 0).Order("timestamp ASC").Find(&discrepancyList).Error
+	if err != nil {
+		return nil, err
+	}
+	return discrepancyList, nil
+}
+
+// ApiDiscrepancyList status 小于 0 时查询所有状态
+func (db *balanceDiscrepanciesDB) ApiDiscrepancyList(status int, page int, pageSize int, order string) ([]BalanceDiscrepancies, int64) {
+	var totalRecord int64
+	var discrepancyList []BalanceDiscrepancies
+	query := db.gorm.Table("balance_discrepancies")
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&totalRecord).Error; err != nil {
+		log.Error("get balance discrepancy count fail", "err", err)
+	}
+	query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	if strings.ToLower(order) == "asc" {
+		query = query.Order("timestamp asc")
+	} else {
+		query = query.Order("timestamp desc")
+	}
+	if err := query.Find(&discrepancyList).Error; err != nil {
+		log.Error("get balance discrepancy list fail", "err", err)
+	}
+	return discrepancyList, totalRecord
+}
+
+// StoreDiscrepancy 地址和币种已有未处理的差异时更新两边余额和检查时间，否则新建一条
+func (db *balanceDiscrepanciesDB) StoreDiscrepancy(discrepancy *BalanceDiscrepancies) error {
+	now := uint64(time.Now().Unix())
+	existing, err := db.QueryOpenDiscrepancy(discrepancy.Address, discrepancy.TokenAddress)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		discrepancy.GUID = existing.GUID
+		discrepancy.Timestamp = existing.Timestamp
+		discrepancy.CheckedTimestamp = now
+		return db.gorm.Table("balance_discrepancies").Where("guid = ?", existing.GUID).Updates(map[string]interface{}{
+			"chain_balance":     discrepancy.ChainBalance.String(),
+			"wallet_balance":    discrepancy.WalletBalance.String(),
+			"checked_timestamp": now,
+		}).Error
+	}
+	discrepancy.GUID = uuid.New()
+	discrepancy.Status = 0
+	discrepancy.CheckedTimestamp = now
+	discrepancy.Timestamp = now
+	return db.gorm.Create(discrepancy).Error
+}
+
+func (db *balanceDiscrepanciesDB) UpdateDiscrepancyStatus(guid uuid.UUID, status uint8) error {
+	return db.gorm.Table("balance_discrepancies").Where("guid = ?", guid).Updates(map[string]interface{}{"status": status}).Error
+}
+
+// ResolveDiscrepancy 链上余额和钱包余额恢复一致后关闭未处理的差异
+func (db *balanceDiscrepanciesDB) ResolveDiscrepancy(address, tokenAddress string) error {
+	return db.gorm.Table("balance_discrepancies").
+		Where("address = ? and token_address = ? and status = ?", address, tokenAddress, 0).
+		Updates(map[string]interface{}{"status": 1, "checked_timestamp": time.Now().Unix()}).Error
+}
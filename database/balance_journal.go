@@ -0,0 +1,206 @@
+package database
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 记账科目：balance 和 lock_balance 对应 balances 表的可用余额和锁定余额，external 表示钱包之外的对手方，只用于借贷平衡
+const (
+	JournalAccountBalance  = "balance"
+	JournalAccountLock     = "lock_balance"
+	JournalAccountExternal = "external"
+)
+
+// 记账方向：贷记增加 balances 的余额，借记减少
+const (
+	JournalDebit  = "debit"
+	JournalCredit = "credit"
+)
+
+// 流水类型，同一笔业务(TxGUID)的同一种流水只记一次，冲正之后可以再次记账
+const (
+	JournalOpening          = "opening"           // 上线流水之前的期初余额
+	JournalDeposit          = "deposit"           // 充值入账
+	JournalDepositRollback  = "deposit_rollback"  // 分叉回滚充值，等于 JournalRollback(JournalDeposit)
+	JournalWithdrawLock     = "withdraw_lock"     // 提现发送，锁定热钱包余额
+	JournalWithdrawSettle   = "withdraw_settle"   // 提现上链，扣除锁定余额
+	JournalWithdrawFee      = "withdraw_fee"      // 提现上链（包括执行失败），热钱包支付的手续费
+	JournalCollectionLock   = "collection_lock"   // 归集发送，锁定用户余额
+	JournalCollectionSettle = "collection_settle" // 归集上链，用户锁定余额转入热钱包
+	JournalCollectionFee    = "collection_fee"    // 归集发送，付手续费的地址支付的手续费，SOL 归集为用户地址，token 归集为热钱包
+	JournalCollectionRent   = "collection_rent"   // token 归集上链，关闭用户 token 账户退回热钱包的租金
+	JournalColdLock         = "cold_lock"         // 热转冷发送，锁定热钱包余额
+	JournalColdSettle       = "cold_settle"       // 热转冷上链，热钱包锁定余额转入冷钱包
+	JournalColdHotLock      = "cold_hot_lock"     // 冷转热广播，锁定冷钱包余额
+	JournalColdHotSettle    = "cold_hot_settle"   // 冷转热上链，冷钱包锁定余额转入热钱包
+	JournalRelease          = "release"           // 交易上链失败，锁定余额退回可用余额
+	JournalReconcileAdjust  = "reconcile_adjust"  // 对账自动修正，可用余额调整到和链上一致
+)
+
+// journalRollbackSuffix 冲正流水类型的后缀，冲正流水和原流水方向相反，抵消原流水还没有冲正的净额
+const journalRollbackSuffix = "_rollback"
+
+// JournalRollback 返回 entryType 对应的冲正流水类型
+func JournalRollback(entryType string) string {
+	return entryType + journalRollbackSuffix
+}
+
+// IsJournalRollback 冲正流水按未冲正的净额生成，记账时不按 (TxGUID, EntryType) 去重
+func IsJournalRollback(entryType string) bool {
+	return strings.HasSuffix(entryType, journalRollbackSuffix)
+}
+
+// BalanceJournal 只追加的余额流水，每笔业务按借贷两边成对记录，TxGUID 是充值、提现或交易记录的 GUID
+type BalanceJournal struct {
+	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
+	TxGUID       uuid.UUID `gorm:"column:tx_guid" json:"tx_guid"`
+	EntryType    string    `json:"entry_type"`
+	Address      string    `json:"address"`
+	TokenAddress string    `json:"token_address"`
+	Account      string    `json:"account"`
+	Direction    string    `json:"direction"`
+	Amount       *big.Int  `gorm:"serializer:u256;column:amount" db:"amount" json:"Amount" form:"amount"`
+	Timestamp    uint64
+}
+
+func (BalanceJournal) TableName() string {
+	return "balance_journal"
+}
+
+// JournalAccount 记账的一边：地址、币种和科目
+type JournalAccount struct {
+	Address string
+	Account string
+}
+
+// JournalSum 按地址、币种和科目汇总的流水，贷记为正借记为负
+type JournalSum struct {
+	Address      string
+	TokenAddress string
+	Account      string
+	Amount       *big.Int
+}
+
+// NewJournalEntries 生成一笔从 from 转到 to 的借贷分录
+func NewJournalEntries(txGuid uuid.UUID, entryType, tokenAddress string, amount *big.Int, from, to JournalAccount) []BalanceJournal {
+	now := uint64(time.Now().Unix())
+	return []BalanceJournal{
+		{
+			GUID:         uuid.New(),
+			TxGUID:       txGuid,
+			EntryType:    entryType,
+			Address:      from.Address,
+			TokenAddress: tokenAddress,
+			Account:      from.Account,
+			Direction:    JournalDebit,
+			Amount:       new(big.Int).Set(amount),
+			Timestamp:    now,
+		},
+		{
+			GUID:         uuid.New(),
+			TxGUID:       txGuid,
+			EntryType:    entryType,
+			Address:      to.Address,
+			TokenAddress: tokenAddress,
+			Account:      to.Account,
+			Direction:    JournalCredit,
+			Amount:       new(big.Int).Set(amount),
+			Timestamp:    now,
+		},
+	}
+}
+
+type BalanceJournalView interface {
+	QueryBalanceJournal(txGuid uuid.UUID) ([]BalanceJournal, error)
+	ExistBalanceJournal(txGuid uuid.UUID, entryType string) (bool, error)
+	SumBalanceJournal() ([]JournalSum, error)
+	SumTxBalanceJournal(txGuid uuid.UUID, entryType string) ([]JournalSum, error)
+}
+
+type BalanceJournalDB interface {
+	BalanceJournalView
+
+	StoreBalanceJournal([]BalanceJournal, uint64) error
+}
+
+type balanceJournalDB struct {
+	gorm *gorm.DB
+}
+
+func NewBalanceJournalDB(db *gorm.DB) BalanceJournalDB {
+	return &balanceJournalDB{gorm: db}
+}
+
+func (db *balanceJournalDB) StoreBalanceJournal(journalList []BalanceJournal, journalLength uint64) error {
+	result := db.gorm.CreateInBatches(&journalList, int(journalLength))
+	return result.Error
+}
+
+func (db *balanceJournalDB) QueryBalanceJournal(txGuid uuid.UUID) ([]BalanceJournal, error) {
+	var journalList []BalanceJournal
+	err := db.gorm.Table("balance_journal").Where("tx_guid = ?", txGuid.String()).Order("timestamp ASC").Find(&journalList).Error
+	if err != nil {
+		return nil, err
+	}
+	return journalList, nil
+}
+
+// ExistBalanceJournal txGuid 下 entryType 的流水扣除冲正之后还有净额时返回 true
+func (db *balanceJournalDB) ExistBalanceJournal(txGuid uuid.UUID, entryType string) (bool, error) {
+	sumList, err := db.SumTxBalanceJournal(txGuid, entryType)
+	if err != nil {
+		return false, err
+	}
+	for _, sum := range sumList {
+		if sum.Amount.Sign() != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SumTxBalanceJournal 按地址、币种和科目汇总 txGuid 下 entryType 和它的冲正流水，结果为还没有冲正的净额，包含 external 科目
+func (db *balanceJournalDB) SumTxBalanceJournal(txGuid uuid.UUID, entryType string) ([]JournalSum, error) {
+	return db.sumJournal(db.gorm.Table("balance_journal").
+		Where("tx_guid = ? and entry_type in ?", txGuid.String(), []string{entryType, JournalRollback(entryType)}))
+}
+
+// SumBalanceJournal 汇总 balance 和 lock_balance 两个科目的流水，external 科目不参与
+func (db *balanceJournalDB) SumBalanceJournal() ([]JournalSum, error) {
+	return db.sumJournal(db.gorm.Table("balance_journal").Where("account <> ?", JournalAccountExternal))
+}
+
+func (db *balanceJournalDB) sumJournal(query *gorm.DB) ([]JournalSum, error) {
+	var rows []struct {
+		Address      string
+		TokenAddress string
+		Account      string
+		Amount       string
+	}
+	err := query.
+		Select("address, token_address, account, SUM(CASE WHEN direction = ? THEN amount ELSE -amount END)::TEXT AS amount", JournalCredit).
+		Group("address, token_address, account").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sumList := make([]JournalSum, 0, len(rows))
+	for _, row := range rows {
+		amount, ok := new(big.Int).SetString(row.Amount, 10)
+		if !ok {
+			amount = big.NewInt(0)
+		}
+		sumList = append(sumList, JournalSum{
+			Address:      row.Address,
+			TokenAddress: row.TokenAddress,
+			Account:      row.Account,
+			Amount:       amount,
+		})
+	}
+	return sumList, nil
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -9,6 +10,10 @@ import (
 	"time"
 )
 
+// ErrNegativeBalance 流水借记的金额超过了余额，账本和流水不一致，整批流水不记账
+var ErrNegativeBalance = errors.New("balance journal exceeds balance")
+
+// Balances 余额是 balance_journal 流水的汇总，只通过 PostJournal 修改，可以用 RebuildBalances 由流水重建
 type Balances struct {
 	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
 	Address      string    `json:"address"`
@@ -21,17 +26,19 @@ type Balances struct {
 
 type BalancesView interface {
 	QueryWalletBalanceByTokenAndAddress(address, tokenAddress string) (*Balances, error)
-	UnCollectionList(amount *big.Int) ([]Balances, error)
-	QueryHotWalletBalances(amount *big.Int) ([]Balances, error)
+	UnCollectionList() ([]Balances, error)
+	QueryHotWalletBalances() ([]Balances, error)
 	QueryBalancesByToAddress(address string) (*Balances, error)
+	QueryBalanceList() ([]Balances, error)
+	QueryTxLockBalance(txGuid uuid.UUID, address, tokenAddress string, amount *big.Int) (*big.Int, error)
 }
 
 type BalancesDB interface {
 	BalancesView
 
-	UpdateOrCreate([]TokenBalance) error
 	StoreBalances([]Balances, uint64) error
-	UpdateBalances([]Balances, bool) error
+	PostJournal([]BalanceJournal) error
+	RebuildBalances() error
 }
 
 type balancesDB struct {
@@ -47,31 +54,6 @@ func (db *balancesDB) StoreBalances(balanceList []Balances, balanceListLength ui
 	return result.Error
 }
 
-func (db *balancesDB) UpdateBalances(balanceList []Balances, isCollection bool) error {
-	for i := 0; i < len(balanceList); i++ {
-		var balance = Balances{}
-		result := db.gorm.Where(&Balances{Address: balanceList[i].Address, TokenAddress: balanceList[i].TokenAddress}).Take(&balance)
-		if result.Error != nil {
-			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				return nil
-			}
-			return result.Error
-		}
-		if isCollection {
-			balance.LockBalance = balance.Balance
-			balance.Balance = big.NewInt(0)
-		} else {
-			balance.Balance = new(big.Int).Sub(balance.Balance, balanceList[i].LockBalance)
-			balance.LockBalance = balanceList[i].LockBalance
-		}
-		err := db.gorm.Save(&balance).Error
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (db *balancesDB) QueryBalancesByToAddress(address string) (*Balances, error) {
 	var balanceEntry Balances
 	err := db.gorm.Table("balances").Where("address", address).Take(&balanceEntry).Error
@@ -84,9 +66,13 @@ func (db *balancesDB) QueryBalancesByToAddress(address string) (*Balances, error
 	return &balanceEntry, nil
 }
 
-func (db *balancesDB) QueryHotWalletBalances(amount *big.Int) ([]Balances, error) {
+// QueryHotWalletBalances 热钱包余额高于币种配置的 hot_high_watermark 的记录，没有登记或没有配置水位的币种不转冷
+func (db *balancesDB) QueryHotWalletBalances() ([]Balances, error) {
 	var balanceList []Balances
-	err := db.gorm.Table("balances").Where("address_type = ? and balance >=?", 1, amount.Uint64()).Find(&balanceList).Error
+	err := db.gorm.Table("balances").Select("balances.*").
+		Joins("JOIN tokens ON tokens.token_address = balances.token_address").
+		Where("balances.address_type = ? and tokens.hot_high_watermark > 0 and balances.balance > tokens.hot_high_watermark", 1).
+		Find(&balanceList).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -96,9 +82,13 @@ func (db *balancesDB) QueryHotWalletBalances(amount *big.Int) ([]Balances, error
 	return balanceList, nil
 }
 
-func (db *balancesDB) UnCollectionList(amount *big.Int) ([]Balances, error) {
+// UnCollectionList 用户地址余额达到币种配置的 collect_amount 的记录，没有登记的币种不归集
+func (db *balancesDB) UnCollectionList() ([]Balances, error) {
 	var balanceList []Balances
-	err := db.gorm.Table("balances").Where("balance >=?", amount.Uint64()).Find(&balanceList).Error
+	err := db.gorm.Table("balances").Select("balances.*").
+		Joins("JOIN tokens ON tokens.token_address = balances.token_address").
+		Where("balances.address_type = ? and balances.balance >= tokens.collect_amount", 0).
+		Find(&balanceList).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -120,92 +110,154 @@ func (db *balancesDB) QueryWalletBalanceByTokenAndAddress(address, tokenAddress
 	return &balanceEntry, nil
 }
 
-func (db *balancesDB) UpdateOrCreate(balanceList []TokenBalance) error {
-	hotWalletBalances, err := db.QueryHotWalletBalances(big.NewInt(0))
+func (db *balancesDB) QueryBalanceList() ([]Balances, error) {
+	var balanceList []Balances
+	err := db.gorm.Table("balances").Order("address ASC, token_address ASC").Find(&balanceList).Error
 	if err != nil {
-		log.Error("query hot wallet balances err", "err", err)
-		return err
+		return nil, err
 	}
-	for _, value := range balanceList {
-		var userBalanceEntry Balances
-		err := db.gorm.Table("balances").Where("address = ? and token_address = ? and address_type = ?", value.Address, value.TokenAddress, 0).Take(&userBalanceEntry).Error
-		if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-			balanceValue := &Balances{
-				GUID:         uuid.New(),
-				Address:      value.Address,
-				TokenAddress: value.TokenAddress,
-				AddressType:  value.TxType,
-				Balance:      value.Balance,
-				LockBalance:  value.LockBalance,
-				Timestamp:    uint64(time.Now().Unix()),
-			}
-			errC := db.gorm.Create(balanceValue).Error
-			if errC != nil {
-				log.Error("create token info fail", "err", errC)
-				return errC
+	return balanceList, nil
+}
+
+// QueryTxLockBalance 查询 txGuid 在 address 上锁定后还没有结算的金额。
+// 流水上线之前发送的交易没有锁定流水，按当前锁定余额和 amount 的较小值计算
+func (db *balancesDB) QueryTxLockBalance(txGuid uuid.UUID, address, tokenAddress string, amount *big.Int) (*big.Int, error) {
+	journalList, err := NewBalanceJournalDB(db.gorm).QueryBalanceJournal(txGuid)
+	if err != nil {
+		return nil, err
+	}
+	locked := big.NewInt(0)
+	hasLock := false
+	for _, journal := range journalList {
+		if journal.Account != JournalAccountLock || journal.Address != address || journal.TokenAddress != tokenAddress {
+			continue
+		}
+		hasLock = true
+		if journal.Direction == JournalCredit {
+			locked.Add(locked, journal.Amount)
+		} else {
+			locked.Sub(locked, journal.Amount)
+		}
+	}
+	if hasLock {
+		if locked.Sign() < 0 {
+			return big.NewInt(0), nil
+		}
+		return locked, nil
+	}
+
+	balanceEntry, err := db.QueryWalletBalanceByTokenAndAddress(address, tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if balanceEntry == nil {
+		return big.NewInt(0), nil
+	}
+	if balanceEntry.LockBalance.Cmp(amount) < 0 {
+		return new(big.Int).Set(balanceEntry.LockBalance), nil
+	}
+	return new(big.Int).Set(amount), nil
+}
+
+// PostJournal 写入余额流水并更新余额，同一笔业务已经记过且没有冲正的同类流水直接跳过，重复调用不会重复记账。冲正流水不去重
+func (db *balancesDB) PostJournal(journalList []BalanceJournal) error {
+	journalDB := NewBalanceJournalDB(db.gorm)
+	postedMap := make(map[string]bool)
+	var postList []BalanceJournal
+	for _, journal := range journalList {
+		key := journal.TxGUID.String() + ":" + journal.EntryType
+		posted, ok := postedMap[key]
+		if !ok && !IsJournalRollback(journal.EntryType) {
+			exist, err := journalDB.ExistBalanceJournal(journal.TxGUID, journal.EntryType)
+			if err != nil {
+				return err
 			}
-			return nil
-		} else if err == nil {
-			log.Info("handle balance update", "TxType", value.TxType)
-			if value.TxType == 0 { // 0:充值；1:提现；2:归集；3:热转冷；4:冷转热
-				userBalanceEntry.Balance = new(big.Int).Add(userBalanceEntry.Balance, value.Balance)
-				log.Info("Deposit balance update", "TxType", value.TxType, "balance", value.Balance, "afterBalance", userBalanceEntry.Balance)
-				errU := db.gorm.Save(&userBalanceEntry).Error
-				if errU != nil {
-					return errU
-				}
-			} else if value.TxType == 1 { // 提现
-				for _, hotWallet := range hotWalletBalances {
-					if hotWallet.Address == value.Address && hotWallet.TokenAddress == value.TokenAddress {
-						hotWallet.LockBalance = big.NewInt(0)
-						errU := db.gorm.Save(&hotWallet).Error
-						if errU != nil {
-							return errU
-						}
-					}
-				}
-			} else if value.TxType == 2 { // 归集
-				if len(hotWalletBalances) > 0 {
-					for _, hotWallet := range hotWalletBalances {
-						if hotWallet.Address == value.Address && hotWallet.TokenAddress == value.TokenAddress {
-							userBalanceEntry.LockBalance = big.NewInt(0)
-							errU := db.gorm.Save(&userBalanceEntry).Error
-							if errU != nil {
-								return errU
-							}
-							hotWallet.Balance = new(big.Int).Add(hotWallet.Balance, value.Balance)
-							errU = db.gorm.Save(&hotWallet).Error
-							if errU != nil {
-								return errU
-							}
-						}
-					}
-				}
-			} else if value.TxType == 3 {
-				if len(hotWalletBalances) > 0 {
-					for _, hotWallet := range hotWalletBalances {
-						hotWallet.LockBalance = big.NewInt(0)
-						err := db.gorm.Save(&hotWallet).Error
-						if err != nil {
-							return err
-						}
-					}
-				}
-			} else if value.TxType == 4 {
-				if len(hotWalletBalances) > 0 {
-					for _, hotWallet := range hotWalletBalances {
-						hotWallet.Balance = new(big.Int).Add(hotWallet.Balance, value.Balance)
-						err := db.gorm.Save(&hotWallet).Error
-						if err != nil {
-							return err
-						}
-					}
-				}
+			if exist {
+				log.Warn("balance journal already posted, skip", "txGuid", journal.TxGUID, "entryType", journal.EntryType)
 			}
-		} else {
-			log.Error("update or create balances fail", "err", err)
+			posted = exist
+			postedMap[key] = posted
+		}
+		if !posted {
+			postList = append(postList, journal)
+		}
+	}
+	if len(postList) == 0 {
+		return nil
+	}
+	if err := journalDB.StoreBalanceJournal(postList, uint64(len(postList))); err != nil {
+		return err
+	}
+	for _, journal := range postList {
+		if journal.Account == JournalAccountExternal {
 			continue
 		}
+		amount := new(big.Int).Set(journal.Amount)
+		if journal.Direction == JournalDebit {
+			amount.Neg(amount)
+		}
+		if err := db.addBalance(journal.Address, journal.TokenAddress, journal.Account, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildBalances 清零所有余额后按流水汇总重新计算
+func (db *balancesDB) RebuildBalances() error {
+	sumList, err := NewBalanceJournalDB(db.gorm).SumBalanceJournal()
+	if err != nil {
+		return err
+	}
+	err = db.gorm.Table("balances").Where("1 = 1").Updates(map[string]interface{}{"balance": 0, "lock_balance": 0}).Error
+	if err != nil {
+		return err
+	}
+	for _, sum := range sumList {
+		if err := db.addBalance(sum.Address, sum.TokenAddress, sum.Account, sum.Amount); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// addBalance 给 account 对应的余额加上 amount，余额记录不存在时按地址类型新建，结果为负时返回 ErrNegativeBalance
+func (db *balancesDB) addBalance(address, tokenAddress, account string, amount *big.Int) error {
+	var balanceEntry Balances
+	err := db.gorm.Table("balances").Where("address = ? and token_address = ?", address, tokenAddress).Take(&balanceEntry).Error
+	create := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil {
+		if !create {
+			return err
+		}
+		var addressEntry Addresses
+		err = db.gorm.Table("addresses").Where("address = ?", address).Take(&addressEntry).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		balanceEntry = Balances{
+			GUID:         uuid.New(),
+			Address:      address,
+			TokenAddress: tokenAddress,
+			AddressType:  addressEntry.AddressType,
+			Balance:      big.NewInt(0),
+			LockBalance:  big.NewInt(0),
+			Timestamp:    uint64(time.Now().Unix()),
+		}
+	}
+
+	target := &balanceEntry.Balance
+	if account == JournalAccountLock {
+		target = &balanceEntry.LockBalance
+	}
+	value := new(big.Int).Add(*target, amount)
+	if value.Sign() < 0 {
+		log.Error("balance journal exceeds balance", "address", address, "tokenAddress", tokenAddress, "account", account, "balance", *target, "amount", amount)
+		return fmt.Errorf("%w: address %s token %s account %s balance %s amount %s", ErrNegativeBalance, address, tokenAddress, account, *target, amount)
+	}
+	*target = value
+	if create {
+		return db.gorm.Create(&balanceEntry).Error
+	}
+	return db.gorm.Save(&balanceEntry).Error
+}
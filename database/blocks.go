@@ -18,12 +18,14 @@ type Blocks struct {
 
 type BlocksView interface {
 	LatestBlocks() (*Blocks, error)
+	QueryBlockBefore(number uint64) (*Blocks, error)
 }
 
 type BlocksDB interface {
 	BlocksView
 
 	StoreBlockss([]Blocks, uint64) error
+	DeleteBlocksAfter(number uint64) error
 }
 
 type blocksDB struct {
@@ -50,3 +52,21 @@ func (db *blocksDB) LatestBlocks() (*Blocks, error) {
 	}
 	return &l1Header, nil
 }
+
+// QueryBlockBefore 查询 number 之前最近的一个已入库区块
+func (db *blocksDB) QueryBlockBefore(number uint64) (*Blocks, error) {
+	var block Blocks
+	result := db.gorm.Where("number < ?", number).Order("number DESC").Take(&block)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &block, nil
+}
+
+func (db *blocksDB) DeleteBlocksAfter(number uint64) error {
+	result := db.gorm.Where("number > ?", number).Delete(&Blocks{})
+	return result.Error
+}
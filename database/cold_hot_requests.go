@@ -0,0 +1,131 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 冷转热请求的状态
+const (
+	ColdHotAwaitingSign = 0 // 等待离线签名
+	ColdHotSent         = 1 // 签名交易已导入并广播
+	ColdHotConfirmed    = 2 // 交易已上链
+	ColdHotFailed       = 3 // 交易上链失败
+	ColdHotCancelled    = 4 // 人工取消
+)
+
+// 发起冷转热的原因
+const (
+	ColdHotReasonLowWatermark     = "low_watermark"
+	ColdHotReasonPendingWithdraws = "pending_withdraws"
+)
+
+// ColdHotRequests 热钱包余额不足时生成的冷转热请求，冷钱包私钥不在线，交易离线签名后导入广播，
+// 广播后生成 TxType 为 4 的交易记录，之后和其他交易一样由扫链结算
+type ColdHotRequests struct {
+	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
+	FromAddress  string    `json:"from_address"`
+	ToAddress    string    `json:"to_address"`
+	TokenAddress string    `json:"token_address"`
+	Amount       *big.Int  `gorm:"serializer:u256;column:amount" db:"amount" json:"Amount" form:"amount"`
+	Reason       string    `json:"reason"`
+	Status       uint8     `json:"status"` // 0:等待签名；1:已广播；2:已上链；3:上链失败；4:已取消
+	TxGUID       string    `gorm:"column:tx_guid" json:"tx_guid"`
+	Hash         string    `json:"hash"`
+	Timestamp    uint64
+}
+
+type ColdHotRequestsView interface {
+	QueryColdHotRequest(guid uuid.UUID) (*ColdHotRequests, error)
+	QueryOpenColdHotRequest(tokenAddress string) (*ColdHotRequests, error)
+	QueryColdHotRequestList(status int) ([]ColdHotRequests, error)
+}
+
+type ColdHotRequestsDB interface {
+	ColdHotRequestsView
+
+	StoreColdHotRequest(request *ColdHotRequests) error
+	MarkColdHotRequestSent(guid uuid.UUID, txGuid uuid.UUID, hash string) error
+	UpdateColdHotRequestStatus(guid uuid.UUID, status uint8) error
+	UpdateColdHotRequestStatusByHash(hash string, status uint8) error
+	ResetColdHotRequestByHash(hash string) error
+}
+
+type coldHotRequestsDB struct {
+	gorm *gorm.DB
+}
+
+func NewColdHotRequestsDB(db *gorm.DB) ColdHotRequestsDB {
+	return &coldHotRequestsDB{gorm: db}
+}
+
+func (db *coldHotRequestsDB) QueryColdHotRequest(guid uuid.UUID) (*ColdHotRequests, error) {
+	var request ColdHotRequests
+	err := db.gorm.Table("cold_hot_requests").Where("guid = ?", guid.String()).Take(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// QueryOpenColdHotRequest 币种等待签名或已广播还没有上链的请求
+func (db *coldHotRequestsDB) QueryOpenColdHotRequest(tokenAddress string) (*ColdHotRequests, error) {
+	var request ColdHotRequests
+	err := db.gorm.Table("cold_hot_requests").Where("token_address = ? and status in ?", tokenAddress, []uint8{ColdHotAwaitingSign, ColdHotSent}).Take(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// QueryColdHotRequestList status 小于 0 时查询所有状态
+func (db *coldHotRequestsDB) QueryColdHotRequestList(status int) ([]ColdHotRequests, error) {
+	var requestList []ColdHotRequests
+	query := db.gorm.Table("cold_hot_requests")
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("timestamp asc").Find(&requestList).Error; err != nil {
+		return nil, err
+	}
+	return requestList, nil
+}
+
+func (db *coldHotRequestsDB) StoreColdHotRequest(request *ColdHotRequests) error {
+	request.GUID = uuid.New()
+	request.Status = ColdHotAwaitingSign
+	request.Timestamp = uint64(time.Now().Unix())
+	return db.gorm.Create(request).Error
+}
+
+func (db *coldHotRequestsDB) MarkColdHotRequestSent(guid uuid.UUID, txGuid uuid.UUID, hash string) error {
+	return db.gorm.Table("cold_hot_requests").Where("guid = ?", guid.String()).Updates(map[string]interface{}{
+		"status":  ColdHotSent,
+		"tx_guid": txGuid.String(),
+		"hash":    hash,
+	}).Error
+}
+
+func (db *coldHotRequestsDB) UpdateColdHotRequestStatus(guid uuid.UUID, status uint8) error {
+	return db.gorm.Table("cold_hot_requests").Where("guid = ?", guid.String()).Updates(map[string]interface{}{"status": status}).Error
+}
+
+// UpdateColdHotRequestStatusByHash 扫到冷转热交易上链或失败后更新已广播的请求
+func (db *coldHotRequestsDB) UpdateColdHotRequestStatusByHash(hash string, status uint8) error {
+	return db.gorm.Table("cold_hot_requests").Where("hash = ? and status = ?", hash, ColdHotSent).Updates(map[string]interface{}{"status": status}).Error
+}
+
+// ResetColdHotRequestByHash 冷转热交易所在的区块被回滚，已上链或失败的请求恢复为已广播
+func (db *coldHotRequestsDB) ResetColdHotRequestByHash(hash string) error {
+	return db.gorm.Table("cold_hot_requests").Where("hash = ? and status in ?", hash, []uint8{ColdHotConfirmed, ColdHotFailed}).Updates(map[string]interface{}{"status": ColdHotSent}).Error
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ConsumerActive  uint8 = 0
+	ConsumerRevoked uint8 = 1
+
+	// ConsumerScopeAll 允许调用所有方法
+	ConsumerScopeAll = "*"
+)
+
+// Consumers 调用接口的业务方，TokenHash 为 token 的 sha256，原始 token 只在签发时输出一次
+type Consumers struct {
+	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	Name      string    `json:"name"`
+	TokenHash string    `json:"-" gorm:"column:token_hash"`
+	Scopes    string    `json:"scopes"` // 逗号分隔的方法名，* 表示所有方法
+	Status    uint8     `json:"status"` // 0:可用；1:已吊销
+	// WebhookSecret 给推送到这个业务方的 webhook 做 HMAC 签名
+	WebhookSecret string `json:"-" gorm:"column:webhook_secret"`
+	Timestamp     uint64
+}
+
+// Allows 业务方是否可以调用 method
+func (c *Consumers) Allows(method string) bool {
+	for _, scope := range strings.Split(c.Scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == ConsumerScopeAll || scope == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumerAuditLogs 业务方调用接口的记录
+type ConsumerAuditLogs struct {
+	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	Consumer  string    `json:"consumer"`
+	Method    string    `json:"method"`
+	RequestId string    `json:"request_id" gorm:"column:request_id"`
+	Result    string    `json:"result"`
+	Timestamp uint64
+}
+
+type ConsumersView interface {
+	QueryConsumerByTokenHash(tokenHash string) (*Consumers, error)
+	QueryConsumerByName(name string) (*Consumers, error)
+	QueryConsumerList() ([]Consumers, error)
+}
+
+type ConsumersDB interface {
+	ConsumersView
+
+	StoreConsumer(consumer *Consumers) error
+	RevokeConsumer(name string) error
+	UpdateWebhookSecret(name string, secret string) error
+	StoreAuditLog(auditLog *ConsumerAuditLogs) error
+}
+
+type consumersDB struct {
+	gorm *gorm.DB
+}
+
+func NewConsumersDB(db *gorm.DB) ConsumersDB {
+	return &consumersDB{gorm: db}
+}
+
+// QueryConsumerByTokenHash 只返回可用的业务方
+func (db *consumersDB) QueryConsumerByTokenHash(tokenHash string) (*Consumers, error) {
+	var consumer Consumers
+	err := db.gorm.Table("consumers").Where("token_hash = ? and status = ?", tokenHash, ConsumerActive).Take(&consumer).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &consumer, nil
+}
+
+// QueryConsumerByName 只返回可用的业务方
+func (db *consumersDB) QueryConsumerByName(name string) (*Consumers, error) {
+	var consumer Consumers
+	err := db.gorm.Table("consumers").Where("name = ? and status = ?", name, ConsumerActive).Take(&consumer).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &consumer, nil
+}
+
+func (db *consumersDB) QueryConsumerList() ([]Consumers, error) {
+	var consumerList []Consumers
+	if err := db.gorm.Table("consumers").Order("name asc").Find(&consumerList).Error; err != nil {
+		return nil, err
+	}
+	return consumerList, nil
+}
+
+func (db *consumersDB) StoreConsumer(consumer *Consumers) error {
+	if consumer.Name == "" {
+		return errors.New("consumer name is required")
+	}
+	if consumer.TokenHash == "" {
+		return errors.New("consumer token hash is required")
+	}
+	consumer.GUID = uuid.New()
+	consumer.Status = ConsumerActive
+	consumer.Timestamp = uint64(time.Now().Unix())
+	return db.gorm.Create(consumer).Error
+}
+
+// RevokeConsumer 吊销后 token 立即失效，记录保留用于追溯提现和审计记录
+func (db *consumersDB) RevokeConsumer(name string) error {
+	result := db.gorm.Table("consumers").Where("name = ? and status = ?", name, ConsumerActive).Updates(map[string]interface{}{"status": ConsumerRevoked})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *consumersDB) UpdateWebhookSecret(name string, secret string) error {
+	result := db.gorm.Table("consumers").Where("name = ? and status = ?", name, ConsumerActive).Updates(map[string]interface{}{"webhook_secret": secret})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *consumersDB) StoreAuditLog(auditLog *ConsumerAuditLogs) error {
+	auditLog.GUID = uuid.New()
+	auditLog.Timestamp = uint64(time.Now().Unix())
+	return db.gorm.Create(auditLog).Error
+}
@@ -18,13 +18,23 @@ import (
 type DB struct {
 	gorm *gorm.DB
 
-	Blocks       BlocksDB
-	Addresses    AddressesDB
-	Balances     BalancesDB
-	Deposits     DepositsDB
-	Withdraws    WithdrawsDB
-	Transactions TransactionsDB
-	Tokens       TokensDB
+	Blocks           BlocksDB
+	Addresses        AddressesDB
+	Balances         BalancesDB
+	Deposits         DepositsDB
+	Withdraws        WithdrawsDB
+	Transactions     TransactionsDB
+	Tokens           TokensDB
+	NonceAccounts    NonceAccountsDB
+	WithdrawAttempts WithdrawAttemptsDB
+	SkippedSlots     SkippedSlotsDB
+	SyncState        SyncStateDB
+	BalanceJournal   BalanceJournalDB
+	Discrepancies    BalanceDiscrepanciesDB
+	ColdHotRequests  ColdHotRequestsDB
+	Consumers        ConsumersDB
+	NotifyAttempts   NotifyAttemptsDB
+	DeadLetters      NotifyDeadLettersDB
 }
 
 func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
@@ -60,13 +70,23 @@ func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
 	db := &DB{
 		gorm: gorm,
 
-		Blocks:       NewBlocksDB(gorm),
-		Addresses:    NewAddressesDB(gorm),
-		Balances:     NewBalancesDB(gorm),
-		Deposits:     NewDepositsDB(gorm),
-		Withdraws:    NewWithdrawsDB(gorm),
-		Transactions: NewTransactionsDB(gorm),
-		Tokens:       NewTokensDB(gorm),
+		Blocks:           NewBlocksDB(gorm),
+		Addresses:        NewAddressesDB(gorm),
+		Balances:         NewBalancesDB(gorm),
+		Deposits:         NewDepositsDB(gorm),
+		Withdraws:        NewWithdrawsDB(gorm),
+		Transactions:     NewTransactionsDB(gorm),
+		Tokens:           NewTokensDB(gorm),
+		NonceAccounts:    NewNonceAccountsDB(gorm),
+		WithdrawAttempts: NewWithdrawAttemptsDB(gorm),
+		SkippedSlots:     NewSkippedSlotsDB(gorm),
+		SyncState:        NewSyncStateDB(gorm),
+		BalanceJournal:   NewBalanceJournalDB(gorm),
+		Discrepancies:    NewBalanceDiscrepanciesDB(gorm),
+		ColdHotRequests:  NewColdHotRequestsDB(gorm),
+		Consumers:        NewConsumersDB(gorm),
+		NotifyAttempts:   NewNotifyAttemptsDB(gorm),
+		DeadLetters:      NewNotifyDeadLettersDB(gorm),
 	}
 	return db, nil
 }
@@ -74,14 +94,24 @@ func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
 func (db *DB) Transaction(fn func(db *DB) error) error {
 	return db.gorm.Transaction(func(tx *gorm.DB) error {
 		txDB := &DB{
-			gorm:         tx,
-			Blocks:       NewBlocksDB(tx),
-			Addresses:    NewAddressesDB(tx),
-			Balances:     NewBalancesDB(tx),
-			Deposits:     NewDepositsDB(tx),
-			Withdraws:    NewWithdrawsDB(tx),
-			Transactions: NewTransactionsDB(tx),
-			Tokens:       NewTokensDB(tx),
+			gorm:             tx,
+			Blocks:           NewBlocksDB(tx),
+			Addresses:        NewAddressesDB(tx),
+			Balances:         NewBalancesDB(tx),
+			Deposits:         NewDepositsDB(tx),
+			Withdraws:        NewWithdrawsDB(tx),
+			Transactions:     NewTransactionsDB(tx),
+			Tokens:           NewTokensDB(tx),
+			NonceAccounts:    NewNonceAccountsDB(tx),
+			WithdrawAttempts: NewWithdrawAttemptsDB(tx),
+			SkippedSlots:     NewSkippedSlotsDB(tx),
+			SyncState:        NewSyncStateDB(tx),
+			BalanceJournal:   NewBalanceJournalDB(tx),
+			Discrepancies:    NewBalanceDiscrepanciesDB(tx),
+			ColdHotRequests:  NewColdHotRequestsDB(tx),
+			Consumers:        NewConsumersDB(tx),
+			NotifyAttempts:   NewNotifyAttemptsDB(tx),
+			DeadLetters:      NewNotifyDeadLettersDB(tx),
 		}
 		return fn(txDB)
 	})
@@ -2,7 +2,9 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"math/big"
 	"strings"
 
@@ -11,6 +13,9 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// LegacyTransactionIndex 指令序号上线之前同一笔交易里重复的转账记录，迁移时序号改到这个值之后
+const LegacyTransactionIndex = 1000000
+
 type Deposits struct {
 	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
 	BlockHash    string    `json:"block_hash" db:"block_hash"`
@@ -22,18 +27,30 @@ type Deposits struct {
 	Fee          *big.Int  `gorm:"serializer:u256;column:fee" db:"fee" json:"Fee" form:"fee"`
 	Amount       *big.Int  `gorm:"serializer:u256;column:amount" db:"amount" json:"Amount" form:"amount"`
 	Status       uint8     `json:"status"` //0:充值确认中,1:充值钱包层已到账；2:充值已通知业务层；3:充值完成
-	Timestamp    uint64
+	// TransactionIndex 转账在交易中的指令序号，和 Hash 一起唯一确定一笔充值
+	TransactionIndex uint64 `json:"transaction_index"`
+	// FromSubscription 为 true 表示由 WebSocket 订阅提前发现、还没有被扫链确认的充值，不计入余额
+	FromSubscription bool `json:"from_subscription"`
+	Timestamp        uint64
 }
 
 type DepositsView interface {
 	ApiDepositList(string, int, int, string) ([]Deposits, int64)
+	QueryDepositsAfterBlock(blockNumber uint64) ([]Deposits, error)
+	ExistDepositsByHash(hash string) (bool, error)
+	ExistDeposit(deposit *Deposits) (bool, error)
+	ExcludeStoredDeposits(depositList []Deposits) ([]Deposits, error)
+	UnNotifiedDepositsList(limit int) ([]Deposits, error)
 }
 
 type DepositsDB interface {
 	DepositsView
 
-	StoreDeposits([]Deposits, uint64) error
+	StoreDeposits([]Deposits) ([]Deposits, error)
 	UpdateDepositsStatus(blockNumber uint64) error
+	DeleteDepositsAfterBlock(blockNumber uint64) error
+	DeleteSubscribedDeposits(blockNumber uint64) error
+	MarkDepositNotified(guid uuid.UUID) error
 }
 
 type depositsDB struct {
@@ -44,7 +61,7 @@ func (db *depositsDB) ApiDepositList(address string, page int, pageSize int, ord
 	var totalRecord int64
 	var depositList []Deposits
 	queryStateRoot := db.gorm.Table("deposits")
-	if address != "0x00" {
+	if address != "" {
 		err := db.gorm.Table("deposits").Select("block_number").Where("to_address = ?", address).Count(&totalRecord).Error
 		if err != nil {
 			log.Error("get deposit list by address count fail")
@@ -70,7 +87,7 @@ func (db *depositsDB) ApiDepositList(address string, page int, pageSize int, ord
 }
 
 func (db *depositsDB) UpdateDepositsStatus(blockNumber uint64) error {
-	result := db.gorm.Model(&Deposits{}).Where("status = ? and block_number <= ?", 0, blockNumber).Updates(map[string]interface{}{"status": 1})
+	result := db.gorm.Model(&Deposits{}).Where("status = ? and block_number <= ? and from_subscription = ?", 0, blockNumber, false).Updates(map[string]interface{}{"status": 1})
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil
@@ -84,11 +101,119 @@ func NewDepositsDB(db *gorm.DB) DepositsDB {
 	return &depositsDB{gorm: db}
 }
 
-func (db *depositsDB) StoreDeposits(depositList []Deposits, depositLength uint64) error {
-	result := db.gorm.CreateInBatches(&depositList, int(depositLength))
-	if result.Error != nil {
-		log.Error("create deposit batch fail", "Err", result.Error)
-		return result.Error
+// StoreDeposits 交易哈希和指令序号相同的充值已经存在时跳过，重复写入同一批充值不会报错。
+// 返回本次实际写入的充值，调用方只对这些充值入账
+func (db *depositsDB) StoreDeposits(depositList []Deposits) ([]Deposits, error) {
+	var storedList []Deposits
+	for _, deposit := range depositList {
+		result := db.gorm.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}, {Name: "transaction_index"}},
+			DoNothing: true,
+		}).Create(&deposit)
+		if result.Error != nil {
+			log.Error("create deposit fail", "Err", result.Error)
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			storedList = append(storedList, deposit)
+		}
 	}
-	return nil
+	return storedList, nil
+}
+
+func (db *depositsDB) QueryDepositsAfterBlock(blockNumber uint64) ([]Deposits, error) {
+	var depositList []Deposits
+	err := db.gorm.Table("deposits").Where("block_number > ?", blockNumber).Find(&depositList).Error
+	if err != nil {
+		return nil, err
+	}
+	return depositList, nil
+}
+
+// DeleteDepositsAfterBlock 分叉回滚时删除确认中的充值，已到账或者已通知业务层的充值不删除，由回滚告警人工处理
+func (db *depositsDB) DeleteDepositsAfterBlock(blockNumber uint64) error {
+	result := db.gorm.Where("block_number > ? and status = ?", blockNumber, 0).Delete(&Deposits{})
+	return result.Error
+}
+
+func (db *depositsDB) ExistDepositsByHash(hash string) (bool, error) {
+	var count int64
+	err := db.gorm.Table("deposits").Where("hash = ?", hash).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ExistDeposit 按交易哈希和指令序号查询扫链确认过的充值，订阅提前写入的充值不算。
+// 指令序号上线之前入库的充值 transaction_index 是 0 或者迁移时分配的序号，这些记录按收款地址、币种和金额匹配
+func (db *depositsDB) ExistDeposit(deposit *Deposits) (bool, error) {
+	var count int64
+	err := db.gorm.Table("deposits").
+		Where("hash = ? and from_subscription = ?", deposit.Hash, false).
+		Where("transaction_index = ? or ((transaction_index = 0 or transaction_index > ?) and to_address = ? and token_address = ? and amount = ?)",
+			deposit.TransactionIndex, LegacyTransactionIndex, deposit.ToAddress, deposit.TokenAddress, deposit.Amount.String()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ExcludeStoredDeposits 去掉交易哈希和指令序号已经入库的充值，返回需要写入并入账的充值
+func (db *depositsDB) ExcludeStoredDeposits(depositList []Deposits) ([]Deposits, error) {
+	if len(depositList) == 0 {
+		return depositList, nil
+	}
+	hashList := make([]string, 0, len(depositList))
+	for _, deposit := range depositList {
+		hashList = append(hashList, deposit.Hash)
+	}
+	var storedList []Deposits
+	err := db.gorm.Table("deposits").Select("hash", "transaction_index").
+		Where("hash in ? and from_subscription = ?", hashList, false).Find(&storedList).Error
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(storedList))
+	for _, deposit := range storedList {
+		stored[depositKey(deposit.Hash, deposit.TransactionIndex)] = true
+	}
+	var newList []Deposits
+	for _, deposit := range depositList {
+		key := depositKey(deposit.Hash, deposit.TransactionIndex)
+		if stored[key] {
+			continue
+		}
+		stored[key] = true
+		newList = append(newList, deposit)
+	}
+	return newList, nil
+}
+
+func depositKey(hash string, transactionIndex uint64) string {
+	return fmt.Sprintf("%s:%d", hash, transactionIndex)
+}
+
+// DeleteSubscribedDeposits 扫链处理到 blockNumber 之后删除不高于该 slot 的订阅充值，扫链结果为准
+func (db *depositsDB) DeleteSubscribedDeposits(blockNumber uint64) error {
+	result := db.gorm.Where("from_subscription = ? and block_number <= ?", true, blockNumber).Delete(&Deposits{})
+	return result.Error
+}
+
+// UnNotifiedDepositsList 已到账还没有通知业务层的充值，有未处理死信的充值等人工重新投递
+func (db *depositsDB) UnNotifiedDepositsList(limit int) ([]Deposits, error) {
+	var depositList []Deposits
+	err := db.gorm.Table("deposits").
+		Where("status = ? and from_subscription = ? and guid not in (?)", 1, false, openDeadLetterBizGuids(db.gorm)).
+		Order("timestamp asc").Limit(limit).Find(&depositList).Error
+	if err != nil {
+		return nil, err
+	}
+	return depositList, nil
+}
+
+// MarkDepositNotified 业务层确认收到通知后更新为已通知，只更新已到账的充值
+func (db *depositsDB) MarkDepositNotified(guid uuid.UUID) error {
+	return db.gorm.Table("deposits").Where("guid = ? and status = ?", guid, 1).Updates(map[string]interface{}{"status": 2}).Error
 }
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NonceAccounts struct {
+	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
+	Address      string    `json:"address"` // nonce 账户的 authority，即热钱包或冷钱包地址
+	NonceAddress string    `json:"nonce_address"`
+	// ReservedNonce 最近一笔交易签名时占用的 nonce，链上 nonce 还是这个值时交易还没有上链，nonce 账户不能再签新交易
+	ReservedNonce string `json:"reserved_nonce"`
+	ReservedAt    uint64 `json:"reserved_at"`
+	Timestamp     uint64
+}
+
+type NonceAccountsView interface {
+	QueryNonceAccountByAddress(address string) (*NonceAccounts, error)
+}
+
+type NonceAccountsDB interface {
+	NonceAccountsView
+
+	StoreNonceAccounts([]NonceAccounts, uint64) error
+	ReserveNonce(nonceAddress, reservedNonce, nonce string, reservedAt uint64) (bool, error)
+	ReleaseNonce(nonceAddress, nonce string) error
+}
+
+type nonceAccountsDB struct {
+	gorm *gorm.DB
+}
+
+func NewNonceAccountsDB(db *gorm.DB) NonceAccountsDB {
+	return &nonceAccountsDB{gorm: db}
+}
+
+func (db *nonceAccountsDB) StoreNonceAccounts(nonceAccountList []NonceAccounts, nonceAccountLength uint64) error {
+	result := db.gorm.CreateInBatches(&nonceAccountList, int(nonceAccountLength))
+	return result.Error
+}
+
+func (db *nonceAccountsDB) QueryNonceAccountByAddress(address string) (*NonceAccounts, error) {
+	var nonceAccount NonceAccounts
+	err := db.gorm.Table("nonce_accounts").Where("address", address).Take(&nonceAccount).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &nonceAccount, nil
+}
+
+// ReserveNonce 占用 nonce 账户的 nonce 签名交易，reservedNonce 为读取时的占用值，其他任务已经抢先占用时返回 false
+func (db *nonceAccountsDB) ReserveNonce(nonceAddress, reservedNonce, nonce string, reservedAt uint64) (bool, error) {
+	result := db.gorm.Table("nonce_accounts").Where("nonce_address = ? and reserved_nonce = ?", nonceAddress, reservedNonce).
+		Updates(map[string]interface{}{"reserved_nonce": nonce, "reserved_at": reservedAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseNonce 交易没有广播出去时释放占用的 nonce，nonce 账户可以马上签下一笔交易
+func (db *nonceAccountsDB) ReleaseNonce(nonceAddress, nonce string) error {
+	return db.gorm.Table("nonce_accounts").Where("nonce_address = ? and reserved_nonce = ?", nonceAddress, nonce).
+		Update("reserved_nonce", "").Error
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	NotifyBizDeposit  = "deposit"
+	NotifyBizWithdraw = "withdraw"
+
+	NotifyAttemptFailed   uint8 = 0
+	NotifyAttemptAcked    uint8 = 1
+	NotifyAttemptRejected uint8 = 2
+)
+
+// NotifyAttempts 通知业务层的投递记录，每次投递一条
+type NotifyAttempts struct {
+	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	BizType   string    `json:"biz_type"` // deposit 或 withdraw
+	BizGuid   uuid.UUID `json:"biz_guid"`
+	Endpoint  string    `json:"endpoint"`
+	Status    uint8     `json:"status"` // 0:业务层不可达；1:业务层已确认；2:业务层拒绝确认
+	ErrMsg    string    `json:"err_msg"`
+	Timestamp uint64
+}
+
+type NotifyAttemptsView interface {
+	QueryNotifyAttempts(bizGuid uuid.UUID) ([]NotifyAttempts, error)
+}
+
+type NotifyAttemptsDB interface {
+	NotifyAttemptsView
+
+	StoreNotifyAttempt(attempt *NotifyAttempts) error
+	CountRejectedAttempts(bizGuid uuid.UUID) (int64, error)
+}
+
+type notifyAttemptsDB struct {
+	gorm *gorm.DB
+}
+
+func NewNotifyAttemptsDB(db *gorm.DB) NotifyAttemptsDB {
+	return &notifyAttemptsDB{gorm: db}
+}
+
+func (db *notifyAttemptsDB) QueryNotifyAttempts(bizGuid uuid.UUID) ([]NotifyAttempts, error) {
+	var attemptList []NotifyAttempts
+	err := db.gorm.Table("notify_attempts").Where("biz_guid = ?", bizGuid).Order("timestamp asc").Find(&attemptList).Error
+	if err != nil {
+		return nil, err
+	}
+	return attemptList, nil
+}
+
+func (db *notifyAttemptsDB) StoreNotifyAttempt(attempt *NotifyAttempts) error {
+	attempt.GUID = uuid.New()
+	attempt.Timestamp = uint64(time.Now().Unix())
+	return db.gorm.Create(attempt).Error
+}
+
+// CountRejectedAttempts 最近一次写入死信之后业务层拒绝确认的投递次数，人工重新投递后重新计数
+func (db *notifyAttemptsDB) CountRejectedAttempts(bizGuid uuid.UUID) (int64, error) {
+	var count int64
+	err := db.gorm.Table("notify_attempts").
+		Where("biz_guid = ? and status = ?", bizGuid, NotifyAttemptRejected).
+		Where("timestamp > (?)", db.gorm.Table("notify_dead_letters").Select("COALESCE(MAX(timestamp), 0)").Where("biz_guid = ?", bizGuid)).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	NotifyDeadLetterOpen    uint8 = 0
+	NotifyDeadLetterRequeue uint8 = 1
+)
+
+// NotifyDeadLetters 一轮重试用完仍然投递失败的通知，未处理期间对应的充值或提现不再自动投递
+type NotifyDeadLetters struct {
+	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	EventId   string    `json:"event_id" gorm:"column:event_id"`
+	BizType   string    `json:"biz_type"`
+	BizGuid   uuid.UUID `json:"biz_guid"`
+	Endpoint  string    `json:"endpoint"`
+	ErrMsg    string    `json:"err_msg"`
+	Status    uint8     `json:"status"` // 0:未处理；1:已重新投递
+	Timestamp uint64
+}
+
+type NotifyDeadLettersView interface {
+	ApiDeadLetterList(status int, page int, pageSize int, order string) ([]NotifyDeadLetters, int64)
+}
+
+type NotifyDeadLettersDB interface {
+	NotifyDeadLettersView
+
+	StoreDeadLetter(deadLetter *NotifyDeadLetters) error
+	RequeueDeadLetter(guid uuid.UUID) error
+}
+
+type notifyDeadLettersDB struct {
+	gorm *gorm.DB
+}
+
+func NewNotifyDeadLettersDB(db *gorm.DB) NotifyDeadLettersDB {
+	return &notifyDeadLettersDB{gorm: db}
+}
+
+// openDeadLetterBizGuids 有未处理死信的充值或提现 guid，用于投递时排除
+func openDeadLetterBizGuids(db *gorm.DB) *gorm.DB {
+	return db.Table("notify_dead_letters").Select("biz_guid").Where("status = ?", NotifyDeadLetterOpen)
+}
+
+// ApiDeadLetterList status 小于 0 时查询所有状态
+func (db *notifyDeadLettersDB) ApiDeadLetterList(status int, page int, pageSize int, order string) ([]NotifyDeadLetters, int64) {
+	var totalRecord int64
+	var deadLetterList []NotifyDeadLetters
+	query := db.gorm.Table("notify_dead_letters")
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&totalRecord).Error; err != nil {
+		log.Error("get notify dead letter count fail", "err", err)
+	}
+	query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	if strings.ToLower(order) == "asc" {
+		query = query.Order("timestamp asc")
+	} else {
+		query = query.Order("timestamp desc")
+	}
+	if err := query.Find(&deadLetterList).Error; err != nil {
+		log.Error("get notify dead letter list fail", "err", err)
+	}
+	return deadLetterList, totalRecord
+}
+
+func (db *notifyDeadLettersDB) StoreDeadLetter(deadLetter *NotifyDeadLetters) error {
+	deadLetter.GUID = uuid.New()
+	deadLetter.Status = NotifyDeadLetterOpen
+	deadLetter.Timestamp = uint64(time.Now().Unix())
+	return db.gorm.Create(deadLetter).Error
+}
+
+// RequeueDeadLetter 人工重新投递，通知服务下一轮会再次推送对应的充值或提现
+func (db *notifyDeadLettersDB) RequeueDeadLetter(guid uuid.UUID) error {
+	result := db.gorm.Table("notify_dead_letters").Where("guid = ? and status = ?", guid, NotifyDeadLetterOpen).Updates(map[string]interface{}{"status": NotifyDeadLetterRequeue})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("dead letter not found or already requeued")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SkippedSlots 扫块时链上确认被跳过、没有出块的 slot，用于核对区块同步是否有遗漏
+type SkippedSlots struct {
+	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	Slot      uint64    `json:"slot"`
+	Timestamp uint64
+}
+
+type SkippedSlotsView interface {
+	QuerySkippedSlots(fromSlot, toSlot uint64) ([]SkippedSlots, error)
+}
+
+type SkippedSlotsDB interface {
+	SkippedSlotsView
+
+	StoreSkippedSlots([]SkippedSlots, uint64) error
+	DeleteSkippedSlotsAfter(slot uint64) error
+}
+
+type skippedSlotsDB struct {
+	gorm *gorm.DB
+}
+
+func NewSkippedSlotsDB(db *gorm.DB) SkippedSlotsDB {
+	return &skippedSlotsDB{gorm: db}
+}
+
+// StoreSkippedSlots 重复扫描同一个 slot 时忽略已存在的记录
+func (db *skippedSlotsDB) StoreSkippedSlots(skippedSlotList []SkippedSlots, skippedSlotLength uint64) error {
+	result := db.gorm.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slot"}}, DoNothing: true}).CreateInBatches(&skippedSlotList, int(skippedSlotLength))
+	return result.Error
+}
+
+// QuerySkippedSlots 查询 [fromSlot, toSlot] 范围内被跳过的 slot
+func (db *skippedSlotsDB) QuerySkippedSlots(fromSlot, toSlot uint64) ([]SkippedSlots, error) {
+	var skippedSlotList []SkippedSlots
+	err := db.gorm.Table("skipped_slots").Where("slot >= ? AND slot <= ?", fromSlot, toSlot).Order("slot ASC").Find(&skippedSlotList).Error
+	if err != nil {
+		return nil, err
+	}
+	return skippedSlotList, nil
+}
+
+// DeleteSkippedSlotsAfter 回滚时删除分叉点之后的记录，新分叉上这些 slot 可能有块
+func (db *skippedSlotsDB) DeleteSkippedSlotsAfter(slot uint64) error {
+	result := db.gorm.Where("slot > ?", slot).Delete(&SkippedSlots{})
+	return result.Error
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DepositSyncWorker 扫链充值使用的游标名称
+const DepositSyncWorker = "deposit"
+
+// SyncState 每个扫链 worker 最后一个处理完成的 slot，下一轮从 Slot+1 开始扫描
+type SyncState struct {
+	Worker    string `gorm:"primaryKey" json:"worker"`
+	Slot      uint64 `json:"slot"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+func (SyncState) TableName() string {
+	return "sync_state"
+}
+
+type SyncStateView interface {
+	QuerySyncState(worker string) (*SyncState, error)
+	QuerySyncStateList() ([]SyncState, error)
+}
+
+type SyncStateDB interface {
+	SyncStateView
+
+	UpdateSyncState(worker string, slot uint64) error
+	DeleteSyncState(worker string) error
+}
+
+type syncStateDB struct {
+	gorm *gorm.DB
+}
+
+func NewSyncStateDB(db *gorm.DB) SyncStateDB {
+	return &syncStateDB{gorm: db}
+}
+
+func (db *syncStateDB) QuerySyncState(worker string) (*SyncState, error) {
+	var syncState SyncState
+	err := db.gorm.Table("sync_state").Where("worker = ?", worker).Take(&syncState).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &syncState, nil
+}
+
+func (db *syncStateDB) QuerySyncStateList() ([]SyncState, error) {
+	var syncStateList []SyncState
+	err := db.gorm.Table("sync_state").Order("worker ASC").Find(&syncStateList).Error
+	if err != nil {
+		return nil, err
+	}
+	return syncStateList, nil
+}
+
+// UpdateSyncState 写入 worker 最后处理完成的 slot，不存在时创建
+func (db *syncStateDB) UpdateSyncState(worker string, slot uint64) error {
+	syncState := SyncState{Worker: worker, Slot: slot, Timestamp: uint64(time.Now().Unix())}
+	result := db.gorm.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "worker"}},
+		DoUpdates: clause.AssignmentColumns([]string{"slot", "timestamp"}),
+	}).Create(&syncState)
+	return result.Error
+}
+
+func (db *syncStateDB) DeleteSyncState(worker string) error {
+	result := db.gorm.Where("worker = ?", worker).Delete(&SyncState{})
+	return result.Error
+}
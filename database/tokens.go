@@ -2,31 +2,38 @@ package database
 
 import (
 	"errors"
-	"gorm.io/gorm"
 	"math/big"
+	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	common2 "github.com/the-web3/sol-wallet/database/utils"
 )
 
+// Tokens 支持的币种，SOL 的 TokenAddress 为空。
+// 用户地址余额达到 CollectAmount 时归集；热钱包余额高于 HotHighWatermark 时把超出 HotLowWatermark 的部分转冷，HotHighWatermark 为 0 时不转冷
 type Tokens struct {
-	GUID          uuid.UUID `gorm:"primaryKey" json:"guid"`
-	TokenAddress  string    `json:"token_address"`
-	Uint          uint8     `json:"uint"`
-	TokenName     string    `json:"tokens_name"`
-	CollectAmount *big.Int  `gorm:"serializer:u256;column:collect_amount" db:"collect_amount" json:"CollectAmount" form:"collect_amount"`
-	Timestamp     uint64
+	GUID             uuid.UUID `gorm:"primaryKey" json:"guid"`
+	TokenAddress     string    `json:"token_address"`
+	Unit             uint8     `gorm:"column:unit" json:"unit"`
+	TokenName        string    `json:"tokens_name"`
+	CollectAmount    *big.Int  `gorm:"serializer:u256;column:collect_amount" db:"collect_amount" json:"CollectAmount" form:"collect_amount"`
+	HotHighWatermark *big.Int  `gorm:"serializer:u256;column:hot_high_watermark" db:"hot_high_watermark" json:"HotHighWatermark" form:"hot_high_watermark"`
+	HotLowWatermark  *big.Int  `gorm:"serializer:u256;column:hot_low_watermark" db:"hot_low_watermark" json:"HotLowWatermark" form:"hot_low_watermark"`
+	Timestamp        uint64
 }
 
 type TokensView interface {
 	TokensInfoByAddress(string) (*Tokens, error)
+	QueryTokenList() ([]Tokens, error)
 }
 
 type TokensDB interface {
 	TokensView
 
 	StoreTokens([]Tokens, uint64) error
+	UpsertToken(*Tokens) error
 }
 
 type tokensDB struct {
@@ -53,3 +60,46 @@ func (db *tokensDB) TokensInfoByAddress(address string) (*Tokens, error) {
 	}
 	return &tokensEntry, nil
 }
+
+func (db *tokensDB) QueryTokenList() ([]Tokens, error) {
+	var tokenList []Tokens
+	err := db.gorm.Table("tokens").Order("timestamp asc").Find(&tokenList).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokenList, nil
+}
+
+// UpsertToken 按 token_address 新增或更新币种配置
+func (db *tokensDB) UpsertToken(token *Tokens) error {
+	if token.HotHighWatermark == nil {
+		token.HotHighWatermark = big.NewInt(0)
+	}
+	if token.HotLowWatermark == nil {
+		token.HotLowWatermark = big.NewInt(0)
+	}
+	if token.HotHighWatermark.Sign() > 0 && token.HotLowWatermark.Cmp(token.HotHighWatermark) > 0 {
+		return errors.New("hot low watermark must not exceed hot high watermark")
+	}
+	if token.CollectAmount == nil || token.CollectAmount.Sign() <= 0 {
+		return errors.New("collect amount must be positive")
+	}
+	existing, err := db.TokensInfoByAddress(token.TokenAddress)
+	if err != nil {
+		return err
+	}
+	token.Timestamp = uint64(time.Now().Unix())
+	if existing == nil {
+		token.GUID = uuid.New()
+		return db.gorm.Create(token).Error
+	}
+	token.GUID = existing.GUID
+	return db.gorm.Table("tokens").Where("guid = ?", existing.GUID).Updates(map[string]interface{}{
+		"unit":               token.Unit,
+		"token_name":         token.TokenName,
+		"collect_amount":     token.CollectAmount.String(),
+		"hot_high_watermark": token.HotHighWatermark.String(),
+		"hot_low_watermark":  token.HotLowWatermark.String(),
+		"timestamp":          token.Timestamp,
+	}).Error
+}
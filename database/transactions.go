@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"math/big"
 
 	"github.com/google/uuid"
@@ -10,7 +11,7 @@ import (
 
 type Transactions struct {
 	GUID         uuid.UUID `gorm:"primaryKey" json:"guid"`
-	BlockHash    string    `gorm:"column:block_hash;serializer:bytes"  db:"block_hash" json:"block_hash"`
+	BlockHash    string    `gorm:"column:block_hash" db:"block_hash" json:"block_hash"`
 	BlockNumber  *big.Int  `gorm:"serializer:u256;column:block_number" db:"block_number" json:"BlockNumber" form:"block_number"`
 	Hash         string    `json:"hash"`
 	FromAddress  string    `json:"from_address"`
@@ -18,13 +19,25 @@ type Transactions struct {
 	TokenAddress string    `json:"token_address"`
 	Fee          *big.Int  `gorm:"serializer:u256;column:fee" db:"fee" json:"Fee" form:"fee"`
 	Amount       *big.Int  `gorm:"serializer:u256;column:amount" db:"amount" json:"Amount" form:"amount"`
-	Status       uint8     `json:"status"`  // 0:交易确认中,1:钱包交易已到账；2:交易已通知业务层；3:交易完成
-	TxType       uint8     `json:"tx_type"` // 0:充值；1:提现；2:归集；3:热转冷；4:冷转热
-	Timestamp    uint64
+	Status       uint8     `json:"status"`                          // 0:交易确认中,1:钱包交易已到账；2:交易已通知业务层；3:交易完成；4:交易上链失败
+	TxType       uint8     `json:"tx_type"`                         // 0:充值；1:提现；2:归集；3:热转冷；4:冷转热
+	ErrCode      string    `json:"err_code" gorm:"column:err_code"` // 上链失败时交易 meta.err
+	// TransactionIndex 转账在交易中的指令序号，和 Hash 一起唯一确定一笔转账
+	TransactionIndex uint64 `json:"transaction_index"`
+	// RentRefund token 归集关闭用户 token 账户后退回热钱包的租金，其他交易为空
+	RentRefund *big.Int `gorm:"serializer:u256;column:rent_refund" db:"rent_refund" json:"RentRefund" form:"rent_refund"`
+	// NonceAccount、Nonce 和 LastValidBlockHeight 为归集和热转冷签名时使用的 nonce，TxSignHex 为广播的原始交易，
+	// 交易没有上链时用来重新广播或者判断过期，其他交易为空
+	NonceAccount         string `json:"nonce_account"`
+	Nonce                string `json:"nonce"`
+	LastValidBlockHeight uint64 `json:"last_valid_block_height"`
+	TxSignHex            string `json:"tx_sign_hex" gorm:"column:tx_sign_hex"`
+	Timestamp            uint64
 }
 
 type TransactionsView interface {
 	QueryTransactionByHash(hash string) (*Transactions, error)
+	SentTransactionsList() ([]Transactions, error)
 }
 
 type TransactionsDB interface {
@@ -33,6 +46,10 @@ type TransactionsDB interface {
 	StoreTransactions([]Transactions, uint64) error
 	UpdateTransactionsStatus(blockNumber *big.Int) error
 	UpdateTransactionStatus(txList []Transactions) error
+	DeleteTransactionsAfterBlock(blockNumber uint64, txType uint8) error
+	MarkTransactionFailed(guid uuid.UUID, errCode string, blockNumber *big.Int) error
+	QuerySettledTransactionsAfterBlock(blockNumber uint64) ([]Transactions, error)
+	ResetTransactionToSent(guid uuid.UUID) error
 }
 
 type transactionsDB struct {
@@ -51,6 +68,16 @@ func (db *transactionsDB) QueryTransactionByHash(hash string) (*Transactions, er
 	return &transactionEntry, nil
 }
 
+// SentTransactionsList 已经广播还没有扫到上链结果的归集和热转冷交易
+func (db *transactionsDB) SentTransactionsList() ([]Transactions, error) {
+	var transactionList []Transactions
+	err := db.gorm.Table("transactions").Where("tx_type in ? and status = ? and tx_sign_hex <> ?", []uint8{2, 3}, 0, "").Find(&transactionList).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactionList, nil
+}
+
 func (db *transactionsDB) UpdateTransactionsStatus(blockNumber *big.Int) error {
 	result := db.gorm.Model(&Transactions{}).Where("status = ? and block_number = ?", 0, blockNumber).Updates(map[string]interface{}{"status": gorm.Expr("GREATEST(1)")})
 	if result.Error != nil {
@@ -66,24 +93,36 @@ func NewTransactionsDB(db *gorm.DB) TransactionsDB {
 	return &transactionsDB{gorm: db}
 }
 
+// StoreTransactions 交易哈希和指令序号相同的记录已经存在时跳过
 func (db *transactionsDB) StoreTransactions(transactionsList []Transactions, transactionsLength uint64) error {
-	result := db.gorm.CreateInBatches(&transactionsList, int(transactionsLength))
+	result := db.gorm.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}, {Name: "transaction_index"}},
+		DoNothing: true,
+	}).CreateInBatches(&transactionsList, int(transactionsLength))
 	return result.Error
 }
 
+// UpdateTransactionStatus 按交易哈希和指令序号确认扫到的归集、热转冷和冷转热交易。发送时还不知道转账的指令序号，
+// 没有序号相同的记录时确认同一哈希下还未上链的发送记录，并写入扫到的指令序号
 func (db *transactionsDB) UpdateTransactionStatus(txList []Transactions) error {
 	for i := 0; i < len(txList); i++ {
 		var transactionSingle = Transactions{}
 
-		result := db.gorm.Where(&Transactions{Hash: txList[i].Hash}).Take(&transactionSingle)
+		result := db.gorm.Where("hash = ? and transaction_index = ?", txList[i].Hash, txList[i].TransactionIndex).Take(&transactionSingle)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			result = db.gorm.Where("hash = ? and status = ?", txList[i].Hash, 0).Take(&transactionSingle)
+		}
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				return nil
+				continue
 			}
 			return result.Error
 		}
 		transactionSingle.Status = txList[i].Status
+		transactionSingle.BlockHash = txList[i].BlockHash
+		transactionSingle.BlockNumber = txList[i].BlockNumber
 		transactionSingle.Fee = txList[i].Fee
+		transactionSingle.TransactionIndex = txList[i].TransactionIndex
 		err := db.gorm.Save(&transactionSingle).Error
 		if err != nil {
 			return err
@@ -91,3 +130,33 @@ func (db *transactionsDB) UpdateTransactionStatus(txList []Transactions) error {
 	}
 	return nil
 }
+
+func (db *transactionsDB) DeleteTransactionsAfterBlock(blockNumber uint64, txType uint8) error {
+	result := db.gorm.Where("block_number > ? and tx_type = ?", blockNumber, txType).Delete(&Transactions{})
+	return result.Error
+}
+
+// MarkTransactionFailed blockNumber 为扫到失败交易的区块，分叉回滚时按区块恢复
+func (db *transactionsDB) MarkTransactionFailed(guid uuid.UUID, errCode string, blockNumber *big.Int) error {
+	updates := map[string]interface{}{"status": 4, "err_code": errCode}
+	if blockNumber != nil {
+		updates["block_number"] = blockNumber.String()
+	}
+	return db.gorm.Table("transactions").Where("guid = ?", guid).Updates(updates).Error
+}
+
+// QuerySettledTransactionsAfterBlock 在 blockNumber 之后的区块上链或者上链失败的归集、热转冷和冷转热交易
+func (db *transactionsDB) QuerySettledTransactionsAfterBlock(blockNumber uint64) ([]Transactions, error) {
+	var transactionList []Transactions
+	err := db.gorm.Table("transactions").Where("tx_type in ? and status <> ? and block_number > ?", []uint8{2, 3, 4}, 0, blockNumber).Find(&transactionList).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactionList, nil
+}
+
+// ResetTransactionToSent 分叉回滚后交易恢复为确认中，等扫链重新确认
+func (db *transactionsDB) ResetTransactionToSent(guid uuid.UUID) error {
+	return db.gorm.Table("transactions").Where("guid = ?", guid).
+		Updates(map[string]interface{}{"status": 0, "block_hash": "", "block_number": "1", "err_code": ""}).Error
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WithdrawAttempts 提现每一次签名广播的记录，blockhash 过期重新签名后会产生新的记录
+type WithdrawAttempts struct {
+	GUID                 uuid.UUID `gorm:"primaryKey" json:"guid"`
+	WithdrawGUID         uuid.UUID `json:"withdraw_guid" gorm:"column:withdraw_guid"`
+	Hash                 string    `json:"hash"`
+	Nonce                string    `json:"nonce"`
+	LastValidBlockHeight uint64    `json:"last_valid_block_height"`
+	Status               uint8     `json:"status"` // 0:已广播；1:已上链；2:blockhash 过期未上链；3:上链执行失败
+	TxSignHex            string    `json:"tx_sign_hex" gorm:"column:tx_sign_hex"`
+	Timestamp            uint64
+}
+
+type WithdrawAttemptsView interface {
+	QueryWithdrawAttempts(withdrawGUID uuid.UUID) ([]WithdrawAttempts, error)
+}
+
+type WithdrawAttemptsDB interface {
+	WithdrawAttemptsView
+
+	StoreWithdrawAttempts([]WithdrawAttempts, uint64) error
+	UpdateWithdrawAttemptStatus(hash string, status uint8) error
+}
+
+type withdrawAttemptsDB struct {
+	gorm *gorm.DB
+}
+
+func NewWithdrawAttemptsDB(db *gorm.DB) WithdrawAttemptsDB {
+	return &withdrawAttemptsDB{gorm: db}
+}
+
+func (db *withdrawAttemptsDB) QueryWithdrawAttempts(withdrawGUID uuid.UUID) ([]WithdrawAttempts, error) {
+	var attemptList []WithdrawAttempts
+	err := db.gorm.Table("withdraw_attempts").Where("withdraw_guid = ?", withdrawGUID).Order("timestamp asc").Find(&attemptList).Error
+	if err != nil {
+		return nil, err
+	}
+	return attemptList, nil
+}
+
+func (db *withdrawAttemptsDB) StoreWithdrawAttempts(attemptList []WithdrawAttempts, attemptLength uint64) error {
+	result := db.gorm.CreateInBatches(&attemptList, int(attemptLength))
+	return result.Error
+}
+
+func (db *withdrawAttemptsDB) UpdateWithdrawAttemptStatus(hash string, status uint8) error {
+	return db.gorm.Table("withdraw_attempts").Where("hash = ?", hash).Update("status", status).Error
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"math/big"
 	"strings"
 	"time"
@@ -22,17 +23,36 @@ type Withdraws struct {
 	TokenAddress string    `json:"token_address"`
 	Fee          *big.Int  `gorm:"serializer:u256;column:fee" db:"fee" json:"Fee" form:"fee"`
 	Amount       *big.Int  `gorm:"serializer:u256;column:amount" db:"amount" json:"Amount" form:"amount"`
-	Status       uint8     `json:"status"` // 0:提现未签名发送,1:提现已经发送到区块链网络；2:提现已上链；3:提现在钱包层已完成；4:提现已通知业务；5:提现成功
+	Status       uint8     `json:"status"` // 0:提现未签名发送,1:提现已经发送到区块链网络；2:提现已上链；3:提现在钱包层已完成；4:提现已通知业务；5:提现成功；6:提现上链失败
 	TxSignHex    string    `json:"tx_sign_hex" gorm:"column:tx_sign_hex"`
-	Timestamp    uint64
+	// LastValidBlockHeight 签名使用的 blockhash 失效的区块高度，使用 durable nonce 签名时为 0
+	LastValidBlockHeight uint64 `json:"last_valid_block_height"`
+	ErrCode              string `json:"err_code" gorm:"column:err_code"` // 上链失败时交易 meta.err
+	// TransactionIndex 转账在交易中的指令序号，扫链确认提现时写入
+	TransactionIndex uint64 `json:"transaction_index"`
+	// Consumer 和 RequestId 为提交提现的业务方和业务方的请求号，同一个业务方的请求号只会生成一笔提现
+	Consumer  string `json:"consumer"`
+	RequestId string `json:"request_id" gorm:"column:request_id"`
+	Timestamp uint64
 }
 
+var (
+	// ErrWithdrawRequestConflict 同一个请求号重复提交时参数和已有提现不一致
+	ErrWithdrawRequestConflict = errors.New("withdraw request id already used with different params")
+	// ErrWithdrawConsumerRequired 带请求号的提现必须有认证过的业务方，不同业务方不能共用同一个请求号空间
+	ErrWithdrawConsumerRequired = errors.New("withdraw request id requires an authenticated consumer")
+)
+
 type WithdrawsView interface {
 	QueryWithdrawsByHash(hash string) (*Withdraws, error)
 	UnSendWithdrawsList() ([]Withdraws, error)
+	SentWithdrawsList() ([]Withdraws, error)
 	ApiWithdrawList(string, int, int, string) ([]Withdraws, int64)
+	UnNotifiedWithdrawsList(limit int) ([]Withdraws, error)
+	SumUnSendWithdraws(fromAddress, tokenAddress string) (*big.Int, error)
 
 	SubmitWithdrawFromBusiness(fromAddress string, toAddress string, TokenAddress string, amount *big.Int) error
+	SubmitWithdraw(consumer, requestId, fromAddress, toAddress, tokenAddress string, amount *big.Int) (*Withdraws, bool, error)
 }
 
 type WithdrawsDB interface {
@@ -41,6 +61,10 @@ type WithdrawsDB interface {
 	StoreWithdraws([]Withdraws, uint64) error
 	UpdateTransactionStatus(withdrawsList []Withdraws) error
 	MarkWithdrawsToSend(withdrawsList []Withdraws) error
+	MarkWithdrawFailed(guid uuid.UUID, errCode string, blockNumber *big.Int) error
+	MarkWithdrawNotified(guid uuid.UUID) error
+	QuerySettledWithdrawsAfterBlock(blockNumber uint64) ([]Withdraws, error)
+	ResetWithdrawToSent(guid uuid.UUID) error
 }
 
 type withdrawsDB struct {
@@ -51,7 +75,7 @@ func (db *withdrawsDB) ApiWithdrawList(address string, page int, pageSize int, o
 	var totalRecord int64
 	var withdrawList []Withdraws
 	queryStateRoot := db.gorm.Table("withdraws")
-	if address != "0x00" {
+	if address != "" {
 		err := db.gorm.Table("withdraws").Select("block_number").Where("from_address = ?", address).Count(&totalRecord).Error
 		if err != nil {
 			log.Error("get withdraws list by address count fail")
@@ -89,6 +113,16 @@ func (db *withdrawsDB) QueryWithdrawsByHash(hash string) (*Withdraws, error) {
 }
 
 func (db *withdrawsDB) SubmitWithdrawFromBusiness(fromAddress string, toAddress string, TokenAddress string, amount *big.Int) error {
+	_, _, err := db.SubmitWithdraw("", "", fromAddress, toAddress, TokenAddress, amount)
+	return err
+}
+
+// SubmitWithdraw 新建一笔待发送的提现。consumer 是认证后的业务方名称，不能传 token。
+// requestId 不为空且 consumer 已经提交过时不再新建，返回已有的提现和 true；参数不一致时返回 ErrWithdrawRequestConflict
+func (db *withdrawsDB) SubmitWithdraw(consumer, requestId, fromAddress, toAddress, tokenAddress string, amount *big.Int) (*Withdraws, bool, error) {
+	if requestId != "" && consumer == "" {
+		return nil, false, ErrWithdrawConsumerRequired
+	}
 	withdrawS := Withdraws{
 		GUID:         uuid.New(),
 		BlockHash:    "",
@@ -96,34 +130,66 @@ func (db *withdrawsDB) SubmitWithdrawFromBusiness(fromAddress string, toAddress
 		Hash:         "",
 		FromAddress:  fromAddress,
 		ToAddress:    toAddress,
-		TokenAddress: TokenAddress,
+		TokenAddress: tokenAddress,
 		Fee:          big.NewInt(1),
 		Amount:       amount,
 		Status:       0,
 		TxSignHex:    "",
+		Consumer:     consumer,
+		RequestId:    requestId,
 		Timestamp:    uint64(time.Now().Unix()),
 	}
-	errC := db.gorm.Create(withdrawS).Error
-	if errC != nil {
-		log.Error("create withdraw fail", "err", errC)
-		return errC
+	if requestId == "" {
+		if err := db.gorm.Create(&withdrawS).Error; err != nil {
+			log.Error("create withdraw fail", "err", err)
+			return nil, false, err
+		}
+		return &withdrawS, false, nil
 	}
-	return nil
+
+	result := db.gorm.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "consumer"}, {Name: "request_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Neq{Column: "request_id", Value: ""}}},
+		DoNothing:   true,
+	}).Create(&withdrawS)
+	if result.Error != nil {
+		log.Error("create withdraw fail", "err", result.Error)
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return &withdrawS, false, nil
+	}
+
+	var existing Withdraws
+	if err := db.gorm.Table("withdraws").Where("consumer = ? and request_id = ?", consumer, requestId).Take(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.FromAddress != fromAddress || existing.ToAddress != toAddress || existing.TokenAddress != tokenAddress || existing.Amount.Cmp(amount) != 0 {
+		return &existing, true, ErrWithdrawRequestConflict
+	}
+	return &existing, true, nil
 }
 
+// UpdateTransactionStatus 按交易哈希和指令序号确认扫到的提现，没有序号相同的记录时确认同一哈希下已发送还未上链的提现
 func (db *withdrawsDB) UpdateTransactionStatus(withdrawsList []Withdraws) error {
 	for i := 0; i < len(withdrawsList); i++ {
 		var withdrawsSingle = Withdraws{}
 
-		result := db.gorm.Where(&Transactions{Hash: withdrawsList[i].Hash}).Take(&withdrawsSingle)
+		result := db.gorm.Where("hash = ? and transaction_index = ?", withdrawsList[i].Hash, withdrawsList[i].TransactionIndex).Take(&withdrawsSingle)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			result = db.gorm.Where("hash = ? and status = ?", withdrawsList[i].Hash, 1).Take(&withdrawsSingle)
+		}
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				return nil
+				continue
 			}
 			return result.Error
 		}
 		withdrawsSingle.Status = 2
+		withdrawsSingle.BlockHash = withdrawsList[i].BlockHash
+		withdrawsSingle.BlockNumber = withdrawsList[i].BlockNumber
 		withdrawsSingle.Fee = withdrawsList[i].Fee
+		withdrawsSingle.TransactionIndex = withdrawsList[i].TransactionIndex
 		err := db.gorm.Save(&withdrawsSingle).Error
 		if err != nil {
 			return err
@@ -153,17 +219,46 @@ func (db *withdrawsDB) UnSendWithdrawsList() ([]Withdraws, error) {
 	return withdrawsList, nil
 }
 
+// SumUnSendWithdraws 汇总 fromAddress 还没有签名发送的 tokenAddress 提现金额，这部分余额还没有锁定
+func (db *withdrawsDB) SumUnSendWithdraws(fromAddress, tokenAddress string) (*big.Int, error) {
+	var total string
+	err := db.gorm.Table("withdraws").
+		Select("COALESCE(SUM(amount), 0)::TEXT").
+		Where("from_address = ? and token_address = ? and status = ?", fromAddress, tokenAddress, 0).
+		Scan(&total).Error
+	if err != nil {
+		return nil, err
+	}
+	amount, ok := new(big.Int).SetString(total, 10)
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return amount, nil
+}
+
+// SentWithdrawsList 已经广播但是还没有被扫链确认上链的提现
+func (db *withdrawsDB) SentWithdrawsList() ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws").Where("status = ?", 1).Find(&withdrawsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawsList, nil
+}
+
 func (db *withdrawsDB) MarkWithdrawsToSend(withdrawsList []Withdraws) error {
 	for i := 0; i < len(withdrawsList); i++ {
 		var withdrawsSingle = Withdraws{}
 		result := db.gorm.Where(&Transactions{GUID: withdrawsList[i].GUID}).Take(&withdrawsSingle)
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				return nil
+				continue
 			}
 			return result.Error
 		}
 		withdrawsSingle.Hash = withdrawsList[i].Hash
+		withdrawsSingle.TxSignHex = withdrawsList[i].TxSignHex
+		withdrawsSingle.LastValidBlockHeight = withdrawsList[i].LastValidBlockHeight
 		withdrawsSingle.Status = 1
 		err := db.gorm.Save(&withdrawsSingle).Error
 		if err != nil {
@@ -172,3 +267,45 @@ func (db *withdrawsDB) MarkWithdrawsToSend(withdrawsList []Withdraws) error {
 	}
 	return nil
 }
+
+// MarkWithdrawFailed blockNumber 为扫到失败交易的区块，分叉回滚时按区块恢复；不是扫链发现的失败传 nil
+func (db *withdrawsDB) MarkWithdrawFailed(guid uuid.UUID, errCode string, blockNumber *big.Int) error {
+	updates := map[string]interface{}{"status": 6, "err_code": errCode}
+	if blockNumber != nil {
+		updates["block_number"] = blockNumber.String()
+	}
+	return db.gorm.Table("withdraws").Where("guid = ?", guid).Updates(updates).Error
+}
+
+// QuerySettledWithdrawsAfterBlock 在 blockNumber 之后的区块上链或者上链失败的提现，分叉回滚时恢复为已发送
+func (db *withdrawsDB) QuerySettledWithdrawsAfterBlock(blockNumber uint64) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws").Where("status >= ? and block_number > ?", 2, blockNumber).Find(&withdrawsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawsList, nil
+}
+
+// ResetWithdrawToSent 分叉回滚后提现恢复为已发送，等扫链重新确认或者超时重发
+func (db *withdrawsDB) ResetWithdrawToSent(guid uuid.UUID) error {
+	return db.gorm.Table("withdraws").Where("guid = ?", guid).
+		Updates(map[string]interface{}{"status": 1, "block_hash": "", "block_number": "1", "err_code": ""}).Error
+}
+
+// UnNotifiedWithdrawsList 已上链还没有通知业务层的提现，有未处理死信的提现等人工重新投递
+func (db *withdrawsDB) UnNotifiedWithdrawsList(limit int) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws").
+		Where("status = ? and guid not in (?)", 2, openDeadLetterBizGuids(db.gorm)).
+		Order("timestamp asc").Limit(limit).Find(&withdrawsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawsList, nil
+}
+
+// MarkWithdrawNotified 业务层确认收到通知后更新为已通知，只更新已上链的提现
+func (db *withdrawsDB) MarkWithdrawNotified(guid uuid.UUID) error {
+	return db.gorm.Table("withdraws").Where("guid = ? and status = ?", guid, 2).Updates(map[string]interface{}{"status": 4}).Error
+}
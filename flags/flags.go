@@ -32,17 +32,39 @@ var (
 		EnvVars:  prefixEnvVars("RPC_RUL"),
 		Required: true,
 	}
+	WsUrlFlag = &cli.StringFlag{
+		Name:    "ws-url",
+		Usage:   "The WebSocket provider URL, deposits are detected through logsSubscribe when set",
+		EnvVars: prefixEnvVars("WS_URL"),
+	}
+	BackupRpcUrlsFlag = &cli.StringSliceFlag{
+		Name:    "backup-rpc-urls",
+		Usage:   "Additional HTTP provider URLs used for failover, separated by comma",
+		EnvVars: prefixEnvVars("BACKUP_RPC_URLS"),
+	}
+	RpcMaxSlotLagFlag = &cli.Uint64Flag{
+		Name:    "rpc-max-slot-lag",
+		Usage:   "The max slots an endpoint may fall behind the best endpoint before it is marked unhealthy",
+		EnvVars: prefixEnvVars("RPC_MAX_SLOT_LAG"),
+		Value:   50,
+	}
 	StartingHeightFlag = &cli.UintFlag{
 		Name:    "starting-height",
 		Usage:   "The starting height of chain",
 		EnvVars: prefixEnvVars("STARTING_HEIGHT"),
 		Value:   0,
 	}
-	ConfirmationsFlag = &cli.UintFlag{
-		Name:    "confirmations",
-		Usage:   "The confirmation depth of l1",
-		EnvVars: prefixEnvVars("CONFIRMATIONS"),
-		Value:   64,
+	CommitmentFlag = &cli.StringFlag{
+		Name:    "commitment",
+		Usage:   "The commitment level a deposit slot must reach before it is confirmed (confirmed or finalized)",
+		EnvVars: prefixEnvVars("COMMITMENT"),
+		Value:   "finalized",
+	}
+	DurableNonceFlag = &cli.BoolFlag{
+		Name:    "durable-nonce",
+		Usage:   "Sign hot and cold wallet transactions with durable nonce accounts instead of recent blockhash",
+		EnvVars: prefixEnvVars("DURABLE_NONCE"),
+		Value:   false,
 	}
 	DepositIntervalFlag = &cli.DurationFlag{
 		Name:    "deposit-interval",
@@ -74,6 +96,81 @@ var (
 		EnvVars: prefixEnvVars("BLOCKS_STEP"),
 		Value:   500,
 	}
+	BlockFetchConcurrencyFlag = &cli.UintFlag{
+		Name:    "block-fetch-concurrency",
+		Usage:   "The number of blocks fetched concurrently during sync",
+		EnvVars: prefixEnvVars("BLOCK_FETCH_CONCURRENCY"),
+		Value:   4,
+	}
+	BlockFetchRateLimitFlag = &cli.UintFlag{
+		Name:    "block-fetch-rate-limit",
+		Usage:   "The max getBlock requests per second during sync, 0 means unlimited",
+		EnvVars: prefixEnvVars("BLOCK_FETCH_RATE_LIMIT"),
+		Value:   0,
+	}
+	ReconcileIntervalFlag = &cli.DurationFlag{
+		Name:    "reconcile-interval",
+		Usage:   "The interval of reconciling wallet balances with on-chain balances",
+		EnvVars: prefixEnvVars("RECONCILE_INTERVAL"),
+		Value:   time.Minute * 10,
+	}
+	ReconcileAutoCorrectFlag = &cli.BoolFlag{
+		Name:    "reconcile-auto-correct",
+		Usage:   "Correct available balances to on-chain balances when a discrepancy persists across two reconciliations",
+		EnvVars: prefixEnvVars("RECONCILE_AUTO_CORRECT"),
+		Value:   false,
+	}
+	CollectionFeeFlag = &cli.Uint64Flag{
+		Name:    "collection-fee",
+		Usage:   "The fee in lamports deducted from SOL collection amounts",
+		EnvVars: prefixEnvVars("COLLECTION_FEE"),
+		Value:   5000,
+	}
+	CollectionRentReserveFlag = &cli.BoolFlag{
+		Name:    "collection-rent-reserve",
+		Usage:   "Keep the rent-exempt minimum on user addresses when collecting SOL",
+		EnvVars: prefixEnvVars("COLLECTION_RENT_RESERVE"),
+		Value:   false,
+	}
+	CloseTokenAccountFlag = &cli.BoolFlag{
+		Name:    "close-token-account",
+		Usage:   "Close user token accounts after collecting tokens and reclaim the rent to the hot wallet",
+		EnvVars: prefixEnvVars("CLOSE_TOKEN_ACCOUNT"),
+		Value:   false,
+	}
+	// notify flags
+	NotifyGrpcUrlFlag = &cli.StringFlag{
+		Name:    "notify-grpc-url",
+		Usage:   "The grpc address of the business service receiving deposit and withdraw notifications",
+		EnvVars: prefixEnvVars("NOTIFY_GRPC_URL"),
+	}
+	NotifyWebhookUrlFlag = &cli.StringFlag{
+		Name:    "notify-webhook-url",
+		Usage:   "The http webhook receiving deposit and withdraw notifications, used when notify-grpc-url is empty",
+		EnvVars: prefixEnvVars("NOTIFY_WEBHOOK_URL"),
+	}
+	NotifyWebhookConsumerFlag = &cli.StringFlag{
+		Name:    "notify-webhook-consumer",
+		Usage:   "The default webhook consumer, its webhook secret signs callbacks of records without a consumer such as deposits",
+		EnvVars: prefixEnvVars("NOTIFY_WEBHOOK_CONSUMER"),
+	}
+	NotifyConsumerTokenFlag = &cli.StringFlag{
+		Name:    "notify-consumer-token",
+		Usage:   "The consumer token sent to the business grpc service",
+		EnvVars: prefixEnvVars("NOTIFY_CONSUMER_TOKEN"),
+	}
+	NotifyIntervalFlag = &cli.DurationFlag{
+		Name:    "notify-interval",
+		Usage:   "The interval of pushing notifications to the business service",
+		EnvVars: prefixEnvVars("NOTIFY_INTERVAL"),
+		Value:   time.Second * 5,
+	}
+	NotifyMaxAttemptsFlag = &cli.IntFlag{
+		Name:    "notify-max-attempts",
+		Usage:   "The max deliveries of a notification rejected by the business service before it is moved to the dead letters",
+		EnvVars: prefixEnvVars("NOTIFY_MAX_ATTEMPTS"),
+		Value:   5,
+	}
 	// Rest api flags
 	HttpHostFlag = &cli.StringFlag{
 		Name:     "http-host",
@@ -226,7 +323,7 @@ var requireFlags = []cli.Flag{
 	ChainIdFlag,
 	RpcUrlFlag,
 	StartingHeightFlag,
-	ConfirmationsFlag,
+	CommitmentFlag,
 	DepositIntervalFlag,
 	WithdrawIntervalFlag,
 	CollectIntervalFlag,
@@ -257,6 +354,23 @@ var optionalFlags = []cli.Flag{
 	ApiCacheDetailSizeFlag,
 	ApiCacheListExpireTimeFlag,
 	ApiCacheDetailExpireTimeFlag,
+	DurableNonceFlag,
+	BackupRpcUrlsFlag,
+	RpcMaxSlotLagFlag,
+	WsUrlFlag,
+	BlockFetchConcurrencyFlag,
+	BlockFetchRateLimitFlag,
+	ReconcileIntervalFlag,
+	ReconcileAutoCorrectFlag,
+	CollectionFeeFlag,
+	CollectionRentReserveFlag,
+	CloseTokenAccountFlag,
+	NotifyGrpcUrlFlag,
+	NotifyWebhookUrlFlag,
+	NotifyWebhookConsumerFlag,
+	NotifyConsumerTokenFlag,
+	NotifyIntervalFlag,
+	NotifyMaxAttemptsFlag,
 }
 
 func init() {
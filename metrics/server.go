@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/the-web3/sol-wallet/api/common/httputil"
+	"github.com/the-web3/sol-wallet/config"
+)
+
+const (
+	HealthPath       = "/healthz"
+	RpcEndpointsPath = "/metrics/rpc/endpoints"
+	ReconcilePath    = "/metrics/reconcile/discrepancies"
+)
+
+// Server 运维查看钱包内部状态的 http 服务，各模块通过 Handle 注册只读的 json 接口
+type Server struct {
+	router *chi.Mux
+	srv    *httputil.HTTPServer
+}
+
+func NewServer() *Server {
+	router := chi.NewRouter()
+	router.Use(middleware.Recoverer)
+	router.Use(middleware.Heartbeat(HealthPath))
+	return &Server{router: router}
+}
+
+// HandleJSON 注册 GET 接口，返回 fn 的 json 结果
+func (s *Server) HandleJSON(path string, fn func() (interface{}, error)) {
+	s.router.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		data, err := fn()
+		if err != nil {
+			log.Error("metrics handler fail", "path", path, "err", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			log.Error("Error writing response", "err", err.Error())
+		}
+	})
+}
+
+func (s *Server) Start(serverConfig config.ServerConfig) error {
+	addr := net.JoinHostPort(serverConfig.Host, strconv.Itoa(serverConfig.Port))
+	srv, err := httputil.StartHTTPServer(addr, s.router)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+	log.Info("metrics server started", "addr", srv.Addr().String())
+	s.srv = srv
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Stop(ctx)
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleJSON(t *testing.T) {
+	s := NewServer()
+	s.HandleJSON(RpcEndpointsPath, func() (interface{}, error) {
+		return []map[string]interface{}{{"url": "http://primary", "active": true, "lag": 0}}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, RpcEndpointsPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var endpoints []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &endpoints))
+	require.Len(t, endpoints, 1)
+	require.Equal(t, "http://primary", endpoints[0]["url"])
+	require.Equal(t, true, endpoints[0]["active"])
+}
@@ -0,0 +1,3 @@
+ALTER TABLE transactions ALTER COLUMN transaction_index SET DEFAULT 0;
+ALTER TABLE deposits ALTER COLUMN transaction_index SET DEFAULT 0;
+ALTER TABLE withdraws ALTER COLUMN transaction_index SET DEFAULT 0;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS nonce_accounts (
+    guid  VARCHAR PRIMARY KEY,
+    address VARCHAR NOT NULL,
+    nonce_address VARCHAR NOT NULL,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS nonce_accounts_address ON nonce_accounts(address);
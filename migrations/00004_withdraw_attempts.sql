@@ -0,0 +1,18 @@
+ALTER TABLE withdraws ADD COLUMN IF NOT EXISTS last_valid_block_height BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS withdraw_attempts (
+    guid  VARCHAR PRIMARY KEY,
+    withdraw_guid VARCHAR NOT NULL,
+    hash VARCHAR NOT NULL,
+    nonce VARCHAR NOT NULL,
+    last_valid_block_height BIGINT NOT NULL DEFAULT 0,
+    status SMALLINT NOT NULL DEFAULT 0,
+    tx_sign_hex VARCHAR NOT NULL,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE INDEX IF NOT EXISTS withdraw_attempts_withdraw_guid ON withdraw_attempts(withdraw_guid);
+CREATE INDEX IF NOT EXISTS withdraw_attempts_hash ON withdraw_attempts(hash);
+
+-- 同一个 nonce 账户同时只能有一笔交易在途，reserved_nonce 记录在途交易签名使用的 nonce
+ALTER TABLE nonce_accounts ADD COLUMN IF NOT EXISTS reserved_nonce VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE nonce_accounts ADD COLUMN IF NOT EXISTS reserved_at INTEGER NOT NULL DEFAULT 0;
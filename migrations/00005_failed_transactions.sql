@@ -0,0 +1,11 @@
+ALTER TABLE withdraws ADD COLUMN IF NOT EXISTS err_code VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS err_code VARCHAR NOT NULL DEFAULT '';
+
+-- 归集和热转冷交易广播后没有上链时用来重新广播或者判断过期
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS nonce_account VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS nonce VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS last_valid_block_height BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tx_sign_hex VARCHAR NOT NULL DEFAULT '';
+
+-- token 归集关闭用户 token 账户时退回热钱包的租金，归集上链后记入热钱包余额
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS rent_refund UINT256 DEFAULT 0;
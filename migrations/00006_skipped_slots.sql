@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS skipped_slots (
+    guid  VARCHAR PRIMARY KEY,
+    slot BIGINT NOT NULL,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS skipped_slots_slot ON skipped_slots(slot);
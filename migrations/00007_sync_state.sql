@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS sync_state (
+    worker VARCHAR PRIMARY KEY,
+    slot BIGINT NOT NULL,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
@@ -0,0 +1 @@
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS from_subscription BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,17 @@
+-- 指令序号上线之前同一笔交易里的多条转账 transaction_index 都是 0，建唯一索引之前把重复记录的序号改到 1000000 之后
+UPDATE deposits SET transaction_index = 1000000 + dup.rn
+FROM (SELECT guid, ROW_NUMBER() OVER (PARTITION BY hash, transaction_index ORDER BY timestamp, guid) AS rn FROM deposits) dup
+WHERE deposits.guid = dup.guid AND dup.rn > 1;
+
+UPDATE transactions SET transaction_index = 1000000 + dup.rn
+FROM (SELECT guid, ROW_NUMBER() OVER (PARTITION BY hash, transaction_index ORDER BY timestamp, guid) AS rn FROM transactions) dup
+WHERE transactions.guid = dup.guid AND dup.rn > 1;
+
+UPDATE withdraws SET transaction_index = 1000000 + dup.rn
+FROM (SELECT guid, ROW_NUMBER() OVER (PARTITION BY hash, transaction_index ORDER BY timestamp, guid) AS rn FROM withdraws WHERE hash <> '') dup
+WHERE withdraws.guid = dup.guid AND dup.rn > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS deposits_hash_transaction_index ON deposits(hash, transaction_index);
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_hash_transaction_index ON transactions(hash, transaction_index);
+-- 未发送的提现还没有交易哈希
+CREATE UNIQUE INDEX IF NOT EXISTS withdraws_hash_transaction_index ON withdraws(hash, transaction_index) WHERE hash <> '';
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS balance_journal (
+    guid  VARCHAR PRIMARY KEY,
+    tx_guid VARCHAR NOT NULL,
+    entry_type VARCHAR NOT NULL,
+    address VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    account VARCHAR NOT NULL,
+    direction VARCHAR NOT NULL,
+    amount UINT256 NOT NULL,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE INDEX IF NOT EXISTS balance_journal_tx_guid ON balance_journal(tx_guid, entry_type);
+CREATE INDEX IF NOT EXISTS balance_journal_address ON balance_journal(address, token_address);
+
+-- 流水表为空时把现有余额记成期初分录，之后的余额都可以由流水重建
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM balance_journal) THEN
+        INSERT INTO balance_journal (guid, tx_guid, entry_type, address, token_address, account, direction, amount, timestamp)
+        SELECT gen_random_uuid()::VARCHAR, guid, 'opening', address, token_address, 'external', 'debit', balance, EXTRACT(EPOCH FROM NOW())::INTEGER
+        FROM balances WHERE balance > 0
+        UNION ALL
+        SELECT gen_random_uuid()::VARCHAR, guid, 'opening', address, token_address, 'balance', 'credit', balance, EXTRACT(EPOCH FROM NOW())::INTEGER
+        FROM balances WHERE balance > 0
+        UNION ALL
+        SELECT gen_random_uuid()::VARCHAR, guid, 'opening', address, token_address, 'external', 'debit', lock_balance, EXTRACT(EPOCH FROM NOW())::INTEGER
+        FROM balances WHERE lock_balance > 0
+        UNION ALL
+        SELECT gen_random_uuid()::VARCHAR, guid, 'opening', address, token_address, 'lock_balance', 'credit', lock_balance, EXTRACT(EPOCH FROM NOW())::INTEGER
+        FROM balances WHERE lock_balance > 0;
+    END IF;
+END $$;
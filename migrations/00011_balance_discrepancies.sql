@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS balance_discrepancies (
+    guid  VARCHAR PRIMARY KEY,
+    address VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    address_type SMALLINT NOT NULL DEFAULT 0,
+    chain_balance UINT256 NOT NULL,
+    wallet_balance UINT256 NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    checked_timestamp INTEGER NOT NULL CHECK(checked_timestamp>0),
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+-- 每个地址和币种同时只有一条未处理的差异
+CREATE UNIQUE INDEX IF NOT EXISTS balance_discrepancies_open ON balance_discrepancies(address, token_address) WHERE status = 0;
+CREATE INDEX IF NOT EXISTS balance_discrepancies_timestamp ON balance_discrepancies(timestamp);
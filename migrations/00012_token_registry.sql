@@ -0,0 +1,8 @@
+-- 热钱包水位：余额高于 hot_high_watermark 时把超出 hot_low_watermark 的部分转到冷钱包，为 0 时不转冷
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS hot_high_watermark UINT256 NOT NULL DEFAULT 0;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS hot_low_watermark UINT256 NOT NULL DEFAULT 0;
+
+-- SOL 的 token_address 为空，默认 0.1 SOL 起归集，不转冷
+INSERT INTO tokens (guid, token_address, unit, token_name, collect_amount, timestamp)
+SELECT gen_random_uuid()::VARCHAR, '', 9, 'SOL', 100000000, EXTRACT(EPOCH FROM NOW())::INTEGER
+WHERE NOT EXISTS (SELECT 1 FROM tokens WHERE token_address = '');
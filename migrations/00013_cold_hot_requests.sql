@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS cold_hot_requests (
+    guid  VARCHAR PRIMARY KEY,
+    from_address VARCHAR NOT NULL,
+    to_address VARCHAR NOT NULL,
+    token_address VARCHAR NOT NULL,
+    amount UINT256 NOT NULL,
+    reason VARCHAR NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    tx_guid VARCHAR NOT NULL DEFAULT '',
+    hash VARCHAR NOT NULL DEFAULT '',
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+-- 每个币种同时只有一个待签名或已广播的冷转热请求
+CREATE UNIQUE INDEX IF NOT EXISTS cold_hot_requests_open ON cold_hot_requests(token_address) WHERE status IN (0, 1);
+CREATE INDEX IF NOT EXISTS cold_hot_requests_hash ON cold_hot_requests(hash);
+CREATE INDEX IF NOT EXISTS cold_hot_requests_timestamp ON cold_hot_requests(timestamp);
@@ -0,0 +1,4 @@
+-- 业务方重试提交提现时按 consumer + request_id 去重，没有 request_id 的旧记录不参与。consumer 为业务方标识，不保存原始 token
+ALTER TABLE withdraws ADD COLUMN IF NOT EXISTS consumer VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE withdraws ADD COLUMN IF NOT EXISTS request_id VARCHAR NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS withdraws_consumer_request_id ON withdraws(consumer, request_id) WHERE request_id <> '';
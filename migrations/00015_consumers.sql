@@ -0,0 +1,21 @@
+-- 调用 gRPC 和 REST 接口的业务方，只保存 token 的 sha256，scopes 为逗号分隔的允许调用的方法，* 表示所有方法
+CREATE TABLE IF NOT EXISTS consumers (
+    guid  VARCHAR PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL,
+    scopes VARCHAR NOT NULL DEFAULT '',
+    status SMALLINT NOT NULL DEFAULT 0,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS consumers_name ON consumers(name);
+CREATE UNIQUE INDEX IF NOT EXISTS consumers_token_hash ON consumers(token_hash);
+
+CREATE TABLE IF NOT EXISTS consumer_audit_logs (
+    guid  VARCHAR PRIMARY KEY,
+    consumer VARCHAR NOT NULL,
+    method VARCHAR NOT NULL,
+    request_id VARCHAR NOT NULL DEFAULT '',
+    result VARCHAR NOT NULL DEFAULT '',
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE INDEX IF NOT EXISTS consumer_audit_logs_consumer ON consumer_audit_logs(consumer, timestamp);
@@ -0,0 +1,13 @@
+-- 充值和提现通知业务层的每一次投递
+CREATE TABLE IF NOT EXISTS notify_attempts (
+    guid  VARCHAR PRIMARY KEY,
+    biz_type VARCHAR NOT NULL,
+    biz_guid VARCHAR NOT NULL,
+    endpoint VARCHAR NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    err_msg VARCHAR NOT NULL DEFAULT '',
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE INDEX IF NOT EXISTS notify_attempts_biz_guid ON notify_attempts(biz_guid);
+CREATE INDEX IF NOT EXISTS deposits_status ON deposits(status);
+CREATE INDEX IF NOT EXISTS withdraws_status ON withdraws(status);
@@ -0,0 +1,16 @@
+-- webhook 签名使用的业务方密钥，HMAC 需要原文，不能只保存 hash
+ALTER TABLE consumers ADD COLUMN IF NOT EXISTS webhook_secret VARCHAR NOT NULL DEFAULT '';
+
+-- 重试用完仍然投递失败的通知，status 0 时通知服务不再投递，人工重新投递后改为 1
+CREATE TABLE IF NOT EXISTS notify_dead_letters (
+    guid  VARCHAR PRIMARY KEY,
+    event_id VARCHAR NOT NULL,
+    biz_type VARCHAR NOT NULL,
+    biz_guid VARCHAR NOT NULL,
+    endpoint VARCHAR NOT NULL,
+    err_msg VARCHAR NOT NULL DEFAULT '',
+    status SMALLINT NOT NULL DEFAULT 0,
+    timestamp INTEGER NOT NULL CHECK(timestamp>0)
+);
+CREATE INDEX IF NOT EXISTS notify_dead_letters_biz_guid ON notify_dead_letters(biz_guid, status);
+CREATE INDEX IF NOT EXISTS notify_dead_letters_timestamp ON notify_dead_letters(timestamp);
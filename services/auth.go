@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path"
+
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/the-web3/sol-wallet/common/auth"
+	"github.com/the-web3/sol-wallet/database"
+)
+
+type consumerTokenReq interface {
+	GetConsumerToken() string
+}
+
+type requestIdReq interface {
+	GetRequestId() string
+}
+
+type codeRep interface {
+	GetCode() string
+}
+
+// authInterceptor 用请求里的 ConsumerToken 认证业务方，scope 为 gRPC 方法名(如 submitWithdrawInfo)，
+// 认证通过后把业务方放到 context 里，并记录调用审计
+func authInterceptor(db *database.DB) grpc.UnaryServerInterceptor {
+	authenticator := auth.NewAuthenticator(db.Consumers)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := path.Base(info.FullMethod)
+		var token string
+		if tokenReq, ok := req.(consumerTokenReq); ok {
+			token = tokenReq.GetConsumerToken()
+		}
+		consumer, err := authenticator.Authenticate(token, method)
+		if err != nil {
+			log.Warn("grpc consumer auth fail", "method", method, "err", err)
+			switch {
+			case errors.Is(err, auth.ErrPermissionDenied):
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			case errors.Is(err, auth.ErrMissingToken), errors.Is(err, auth.ErrInvalidToken):
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			default:
+				return nil, status.Error(codes.Internal, "authenticate consumer fail")
+			}
+		}
+
+		resp, err := handler(auth.WithConsumer(ctx, consumer), req)
+
+		auditLog := &database.ConsumerAuditLogs{Consumer: consumer.Name, Method: method}
+		if idReq, ok := req.(requestIdReq); ok {
+			auditLog.RequestId = idReq.GetRequestId()
+		}
+		if err != nil {
+			auditLog.Result = status.Code(err).String()
+		} else if rep, ok := resp.(codeRep); ok {
+			auditLog.Result = rep.GetCode()
+		}
+		if auditErr := db.Consumers.StoreAuditLog(auditLog); auditErr != nil {
+			log.Error("store consumer audit log fail", "consumer", consumer.Name, "method", method, "err", auditErr)
+		}
+		return resp, err
+	}
+}
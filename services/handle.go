@@ -2,12 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 
+	"github.com/the-web3/sol-wallet/common/address"
+	"github.com/the-web3/sol-wallet/common/auth"
+	"github.com/the-web3/sol-wallet/common/webhook"
+	"github.com/the-web3/sol-wallet/database"
 	"github.com/the-web3/sol-wallet/proto/wallet"
 )
 
@@ -23,23 +29,61 @@ func (s *RpcServer) SubmitWithdrawInfo(ctx context.Context, in *wallet.WithdrawR
 			Hash: common.Hash{}.String(),
 		}, nil
 	}
-	amountBig.SetString(in.Amount, 10)
-	err := s.db.Withdraws.SubmitWithdrawFromBusiness(in.FromAddress, in.ToAddress, in.TokenAddress, amountBig)
+	if err := validateWithdrawAddresses(in); err != nil {
+		log.Error("invalid withdraw address", "err", err)
+		return &wallet.WithdrawRep{
+			Code: strconv.Itoa(4000),
+			Msg:  err.Error(),
+			Hash: common.Hash{}.String(),
+		}, nil
+	}
+	withdraw, duplicate, err := s.db.Withdraws.SubmitWithdraw(auth.ConsumerFromContext(ctx), in.RequestId, in.FromAddress, in.ToAddress, in.TokenAddress, amountBig)
 	if err != nil {
-		log.Error("submit withdraw fail", "err", err)
+		log.Error("submit withdraw fail", "requestId", in.RequestId, "err", err)
+		msg := "submit withdraw fail"
+		if errors.Is(err, database.ErrWithdrawRequestConflict) {
+			msg = err.Error()
+		}
 		return &wallet.WithdrawRep{
 			Code: strconv.Itoa(4000),
-			Msg:  "submit withdraw fail",
+			Msg:  msg,
 			Hash: common.Hash{}.String(),
 		}, nil
 	}
+	msg := "submit withdraw success"
+	if duplicate {
+		log.Info("duplicate withdraw request", "requestId", in.RequestId, "guid", withdraw.GUID, "status", withdraw.Status)
+		msg = fmt.Sprintf("duplicate withdraw request, status %d", withdraw.Status)
+	}
 	return &wallet.WithdrawRep{
 		Code: strconv.Itoa(2000),
-		Msg:  "submit withdraw success",
-		Hash: common.Hash{}.String(),
+		Msg:  msg,
+		Hash: withdrawRepHash(withdraw),
 	}, nil
 }
 
+// withdrawRepHash 提现已经广播时返回交易 hash，还没有签名发送时返回提现 GUID，业务方可以用它查询提现
+func withdrawRepHash(withdraw *database.Withdraws) string {
+	if withdraw.Hash != "" {
+		return withdraw.Hash
+	}
+	return withdraw.GUID.String()
+}
+
+// validateWithdrawAddresses 发送方必须是有私钥的钱包地址，接收方可以是 PDA，币种地址为空表示 SOL
+func validateWithdrawAddresses(in *wallet.WithdrawReq) error {
+	if _, err := address.ParseSolanaAddress(in.FromAddress, address.OnCurve); err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	if _, err := address.ParseSolanaAddress(in.ToAddress, address.AnyKey); err != nil {
+		return fmt.Errorf("invalid to address: %w", err)
+	}
+	if err := address.ParseTokenAddress(in.TokenAddress); err != nil {
+		return fmt.Errorf("invalid token address: %w", err)
+	}
+	return nil
+}
+
 func (s *RpcServer) VerifyAddress(ctx context.Context, in *wallet.RiskVerifyAddressReq) (*wallet.RiskVerifyAddressRep, error) {
 	return &wallet.RiskVerifyAddressRep{
 		Code:   strconv.Itoa(200),
@@ -56,10 +100,27 @@ func (s *RpcServer) VerifyWithdrawSign(ctx context.Context, in *wallet.RiskWithd
 	}, nil
 }
 
+// VerifyRiskDOrWNotify 用调用方业务方的 webhook 密钥校验通知签名，MsgHash 和 SignMsg 的计算方式见 common/webhook
 func (s *RpcServer) VerifyRiskDOrWNotify(ctx context.Context, in *wallet.RiskDOrWNotifyVerifyReq) (*wallet.RiskDOrWNotifyVerifyRep, error) {
+	consumer, err := s.db.Consumers.QueryConsumerByName(auth.ConsumerFromContext(ctx))
+	if err != nil {
+		log.Error("query consumer fail", "err", err)
+		return &wallet.RiskDOrWNotifyVerifyRep{
+			Code:   strconv.Itoa(5000),
+			Msg:    "query consumer fail",
+			Verify: false,
+		}, nil
+	}
+	if consumer == nil || consumer.WebhookSecret == "" {
+		return &wallet.RiskDOrWNotifyVerifyRep{
+			Code:   strconv.Itoa(4000),
+			Msg:    "consumer has no webhook secret",
+			Verify: false,
+		}, nil
+	}
 	return &wallet.RiskDOrWNotifyVerifyRep{
 		Code:   strconv.Itoa(200),
 		Msg:    "success request",
-		Verify: true,
+		Verify: webhook.VerifySignature(consumer.WebhookSecret, in.MsgHash, in.SignMsg),
 	}, nil
 }
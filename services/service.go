@@ -60,7 +60,7 @@ func (s *RpcServer) Start(ctx context.Context) error {
 		gs := grpc.NewServer(
 			opt,
 			grpc.ChainUnaryInterceptor(
-				nil,
+				authInterceptor(s.db),
 			),
 		)
 		reflection.Register(gs)
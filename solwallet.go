@@ -8,23 +8,35 @@ import (
 
 	"github.com/the-web3/sol-wallet/config"
 	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/metrics"
 	"github.com/the-web3/sol-wallet/wallet"
 	"github.com/the-web3/sol-wallet/wallet/node"
+	"github.com/the-web3/sol-wallet/wallet/notify"
 	"github.com/the-web3/sol-wallet/wallet/sign"
 )
 
 type SolWallet struct {
 	deposit        *wallet.Deposit
+	subscriber     *wallet.DepositSubscriber
 	withdraw       *wallet.Withdraw
 	collectionCold *wallet.CollectionCold
+	reconciler     *wallet.Reconciler
+	notifier       *wallet.Notifier
+
+	clientPool    *node.ClientPool
+	metricsServer *metrics.Server
+	metricsConfig config.ServerConfig
+	poolCancel    context.CancelFunc
 
 	shutdown context.CancelCauseFunc
 	stopped  atomic.Bool
 }
 
 func NewSolWallet(ctx context.Context, cfg *config.Config, shutdown context.CancelCauseFunc) (*SolWallet, error) {
-	solClient, err := node.NewSolanaClient(cfg.Chain.RpcUrl)
+	rpcUrls := append([]string{cfg.Chain.RpcUrl}, cfg.Chain.BackupRpcUrls...)
+	solClient, err := node.NewClientPool(rpcUrls, cfg.Chain.RpcMaxSlotLag)
 	if err != nil {
+		log.Error("new solana client pool fail", "err", err)
 		return nil, err
 	}
 
@@ -40,37 +52,86 @@ func NewSolWallet(ctx context.Context, cfg *config.Config, shutdown context.Canc
 		return nil, err
 	}
 
-	deposit, err := wallet.NewDeposit(cfg, db, *solClient, shutdown)
+	deposit, err := wallet.NewDeposit(cfg, db, solClient, shutdown)
 	if err != nil {
 		log.Error("new deposit fail", "err", err)
 		return nil, err
 	}
-	withdraw, err := wallet.NewWithdraw(cfg, db, *solClient, signCli, shutdown)
+	var subscriber *wallet.DepositSubscriber
+	if cfg.Chain.WsUrl != "" {
+		subscriber, err = wallet.NewDepositSubscriber(cfg, db, solClient, shutdown)
+		if err != nil {
+			log.Error("new deposit subscriber fail", "err", err)
+			return nil, err
+		}
+	}
+	withdraw, err := wallet.NewWithdraw(cfg, db, solClient, signCli, shutdown)
 	if err != nil {
 		log.Error("new withdraw fail", "err", err)
 		return nil, err
 	}
-	collectionCold, err := wallet.NewCollectionCold(cfg, db, *solClient, signCli, shutdown)
+	collectionCold, err := wallet.NewCollectionCold(cfg, db, solClient, signCli, shutdown)
 	if err != nil {
 		log.Error("new collection and to cold fail", "err", err)
 		return nil, err
 	}
+	reconciler, err := wallet.NewReconciler(cfg, db, solClient, shutdown)
+	if err != nil {
+		log.Error("new reconciler fail", "err", err)
+		return nil, err
+	}
+	var notifier *wallet.Notifier
+	notifyClient, err := notify.NewClient(cfg.Notify, notify.ConsumerSecrets(db.Consumers))
+	if err != nil {
+		log.Error("new notify client fail", "err", err)
+		return nil, err
+	}
+	if notifyClient != nil {
+		notifier, err = wallet.NewNotifier(cfg, db, notifyClient, shutdown)
+		if err != nil {
+			log.Error("new notifier fail", "err", err)
+			return nil, err
+		}
+	}
 
 	out := &SolWallet{
 		deposit:        deposit,
+		subscriber:     subscriber,
 		withdraw:       withdraw,
 		collectionCold: collectionCold,
+		reconciler:     reconciler,
+		notifier:       notifier,
+		clientPool:     solClient,
+		metricsServer:  metrics.NewServer(),
+		metricsConfig:  cfg.MetricsServer,
 		shutdown:       shutdown,
 	}
+	out.metricsServer.HandleJSON(metrics.RpcEndpointsPath, func() (interface{}, error) {
+		return solClient.Endpoints(), nil
+	})
+	out.metricsServer.HandleJSON(metrics.ReconcilePath, reconciler.Summary)
 
 	return out, nil
 }
 
 func (ew *SolWallet) Start(ctx context.Context) error {
+	poolCtx, poolCancel := context.WithCancel(context.Background())
+	ew.poolCancel = poolCancel
+	ew.clientPool.Start(poolCtx)
+
+	if err := ew.metricsServer.Start(ew.metricsConfig); err != nil {
+		return err
+	}
+
 	err := ew.deposit.Start()
 	if err != nil {
 		return err
 	}
+	if ew.subscriber != nil {
+		if err := ew.subscriber.Start(); err != nil {
+			return err
+		}
+	}
 	err = ew.withdraw.Start()
 	if err != nil {
 		return err
@@ -79,6 +140,15 @@ func (ew *SolWallet) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	err = ew.reconciler.Start()
+	if err != nil {
+		return err
+	}
+	if ew.notifier != nil {
+		if err := ew.notifier.Start(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -87,6 +157,11 @@ func (ew *SolWallet) Stop(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if ew.subscriber != nil {
+		if err := ew.subscriber.Close(); err != nil {
+			return err
+		}
+	}
 	err = ew.withdraw.Close()
 	if err != nil {
 		return err
@@ -96,7 +171,22 @@ func (ew *SolWallet) Stop(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = ew.reconciler.Close()
+	if err != nil {
+		return err
+	}
+
+	if ew.notifier != nil {
+		if err := ew.notifier.Close(); err != nil {
+			return err
+		}
+	}
+
+	if ew.poolCancel != nil {
+		ew.poolCancel()
+	}
+	return ew.metricsServer.Stop(ctx)
 }
 
 func (ew *SolWallet) Stopped() bool {
@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// BackfillTools 按地址回补 fromSlot 之后漏掉的充值，address 为空时回补所有用户地址和热钱包地址
+func BackfillTools(ctx *cli.Context, cfg *config.Config, db *database.DB, address string, fromSlot uint64) error {
+	solClient, err := node.NewClientPool(append([]string{cfg.Chain.RpcUrl}, cfg.Chain.BackupRpcUrls...), cfg.Chain.RpcMaxSlotLag)
+	if err != nil {
+		log.Error("new solana client pool fail", "err", err)
+		return err
+	}
+
+	addresses := []string{address}
+	if address == "" {
+		addressList, err := db.Addresses.QueryAddressListByType(0, 1)
+		if err != nil {
+			log.Error("query address list fail", "err", err)
+			return err
+		}
+		addresses = addresses[:0]
+		for _, item := range addressList {
+			addresses = append(addresses, item.Address)
+		}
+	}
+
+	backfill := wallet.NewBackfill(db, solClient)
+	total := 0
+	for _, item := range addresses {
+		count, err := backfill.BackfillAddress(ctx.Context, item, fromSlot)
+		if err != nil {
+			return err
+		}
+		total += count
+	}
+	log.Info("backfill deposits finished", "addresses", len(addresses), "fromSlot", fromSlot, "deposits", total)
+	return nil
+}
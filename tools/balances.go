@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+var errDryRun = errors.New("dry run")
+
+// RebuildBalancesTools 按 balance_journal 流水重建 balances 表并打印有变化的余额，dryRun 时只打印不提交
+func RebuildBalancesTools(db *database.DB, dryRun bool) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADDRESS\tTOKEN\tBALANCE\tREBUILT\tLOCK_BALANCE\tREBUILT")
+	changed := 0
+	err := db.Transaction(func(tx *database.DB) error {
+		beforeList, err := tx.Balances.QueryBalanceList()
+		if err != nil {
+			return err
+		}
+		if err := tx.Balances.RebuildBalances(); err != nil {
+			return err
+		}
+		afterList, err := tx.Balances.QueryBalanceList()
+		if err != nil {
+			return err
+		}
+		before := make(map[string]database.Balances, len(beforeList))
+		for _, balance := range beforeList {
+			before[balance.Address+":"+balance.TokenAddress] = balance
+		}
+		for _, after := range afterList {
+			old, ok := before[after.Address+":"+after.TokenAddress]
+			if ok && old.Balance.Cmp(after.Balance) == 0 && old.LockBalance.Cmp(after.LockBalance) == 0 {
+				continue
+			}
+			oldBalance, oldLockBalance := "-", "-"
+			if ok {
+				oldBalance, oldLockBalance = old.Balance.String(), old.LockBalance.String()
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", after.Address, after.TokenAddress, oldBalance, after.Balance, oldLockBalance, after.LockBalance)
+			changed++
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Error("rebuild balances fail", "err", err)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("rebuild balances from journal", "changed", changed, "dryRun", dryRun)
+	return nil
+}
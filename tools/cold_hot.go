@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// ListColdHotRequestsTools 打印冷转热请求，status 小于 0 时打印所有状态
+func ListColdHotRequestsTools(db *database.DB, status int) error {
+	requestList, err := db.ColdHotRequests.QueryColdHotRequestList(status)
+	if err != nil {
+		log.Error("query cold to hot requests fail", "err", err)
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "GUID\tFROM\tTO\tTOKEN ADDRESS\tAMOUNT\tREASON\tSTATUS\tHASH\tCREATED")
+	for _, request := range requestList {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", request.GUID, request.FromAddress, request.ToAddress, request.TokenAddress,
+			request.Amount, request.Reason, request.Status, request.Hash, time.Unix(int64(request.Timestamp), 0).Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
+
+// ImportColdHotTxTools 导入离线签名的冷转热交易并广播
+func ImportColdHotTxTools(cfg *config.Config, db *database.DB, guid string, rawTx string) error {
+	requestGuid, err := uuid.Parse(guid)
+	if err != nil {
+		return fmt.Errorf("invalid request guid %q: %w", guid, err)
+	}
+	solClient, err := node.NewClientPool(append([]string{cfg.Chain.RpcUrl}, cfg.Chain.BackupRpcUrls...), cfg.Chain.RpcMaxSlotLag)
+	if err != nil {
+		log.Error("new solana client pool fail", "err", err)
+		return err
+	}
+	txHash, err := wallet.ImportColdHotTx(db, solClient, requestGuid, rawTx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(txHash)
+	return nil
+}
+
+// CancelColdHotRequestTools 取消还没有导入签名交易的冷转热请求，之后可以重新生成
+func CancelColdHotRequestTools(db *database.DB, guid string) error {
+	requestGuid, err := uuid.Parse(guid)
+	if err != nil {
+		return fmt.Errorf("invalid request guid %q: %w", guid, err)
+	}
+	request, err := db.ColdHotRequests.QueryColdHotRequest(requestGuid)
+	if err != nil {
+		return err
+	}
+	if request == nil {
+		return fmt.Errorf("cold to hot request %s not found", guid)
+	}
+	if request.Status != database.ColdHotAwaitingSign {
+		return fmt.Errorf("cold to hot request %s is not waiting for signature, status %d", guid, request.Status)
+	}
+	if err := db.ColdHotRequests.UpdateColdHotRequestStatus(requestGuid, database.ColdHotCancelled); err != nil {
+		log.Error("cancel cold to hot request fail", "err", err)
+		return err
+	}
+	log.Info("cancel cold to hot request success", "guid", guid)
+	return nil
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+
+	"github.com/the-web3/sol-wallet/common/auth"
+	"github.com/the-web3/sol-wallet/common/webhook"
+	"github.com/the-web3/sol-wallet/database"
+)
+
+// ListConsumersTools 打印业务方和允许调用的方法，不输出 token
+func ListConsumersTools(db *database.DB) error {
+	consumerList, err := db.Consumers.QueryConsumerList()
+	if err != nil {
+		log.Error("query consumers fail", "err", err)
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSCOPES\tSTATUS\tCREATED")
+	for _, consumer := range consumerList {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", consumer.Name, consumer.Scopes, consumer.Status,
+			time.Unix(int64(consumer.Timestamp), 0).Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
+
+// IssueConsumerTools 签发业务方 token 和 webhook 密钥，token 只在这里输出一次，数据库只保存 hash
+func IssueConsumerTools(db *database.DB, name string, scopes string) error {
+	if scopes == "" {
+		return errors.New("scopes is required, use * to allow all methods")
+	}
+	token, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	if err := db.Consumers.StoreConsumer(&database.Consumers{Name: name, TokenHash: tokenHash, Scopes: scopes, WebhookSecret: secret}); err != nil {
+		log.Error("store consumer fail", "name", name, "err", err)
+		return err
+	}
+	log.Info("issue consumer token success", "name", name, "scopes", scopes)
+	fmt.Printf("token: %s\nwebhook secret: %s\n", token, secret)
+	return nil
+}
+
+// RotateWebhookSecretTools 重新生成业务方的 webhook 密钥，正在运行的通知服务需要重启后才使用新密钥
+func RotateWebhookSecretTools(db *database.DB, name string) error {
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	if err := db.Consumers.UpdateWebhookSecret(name, secret); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("active consumer %s not found", name)
+		}
+		log.Error("update webhook secret fail", "name", name, "err", err)
+		return err
+	}
+	log.Info("rotate webhook secret success", "name", name)
+	fmt.Println(secret)
+	return nil
+}
+
+// RevokeConsumerTools 吊销业务方 token
+func RevokeConsumerTools(db *database.DB, name string) error {
+	if err := db.Consumers.RevokeConsumer(name); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("active consumer %s not found", name)
+		}
+		log.Error("revoke consumer fail", "name", name, "err", err)
+		return err
+	}
+	log.Info("revoke consumer success", "name", name)
+	return nil
+}
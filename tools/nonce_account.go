@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+	"github.com/the-web3/sol-wallet/wallet/retry"
+	"github.com/the-web3/sol-wallet/wallet/sign"
+)
+
+// CreateNonceAccountTools 为还没有 nonce 账户的热钱包和冷钱包创建 durable nonce 账户，authority 为钱包地址本身
+func CreateNonceAccountTools(ctx *cli.Context, cfg *config.Config, db *database.DB) error {
+	signClient, err := sign.NewSolSignClient(cfg.SignServerProvider)
+	if err != nil {
+		log.Error("New sol sign client fail", "err", err)
+		return err
+	}
+	solClient, err := node.NewSolanaClient(cfg.Chain.RpcUrl)
+	if err != nil {
+		log.Error("New solana client fail", "err", err)
+		return err
+	}
+
+	hotWallet, err := db.Addresses.QueryHotWalletInfo()
+	if err != nil {
+		log.Error("query hot wallet info fail", "err", err)
+		return err
+	}
+	coldWallet, err := db.Addresses.QueryColdWalletInfo()
+	if err != nil {
+		log.Error("query cold wallet info fail", "err", err)
+		return err
+	}
+
+	for _, wallet := range []*database.Addresses{hotWallet, coldWallet} {
+		if wallet == nil {
+			continue
+		}
+		if err := createNonceAccount(ctx.Context, db, solClient, signClient, wallet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createNonceAccount(ctx context.Context, db *database.DB, client node.SolanaChain, signClient sign.SolSignClient, wallet *database.Addresses) error {
+	nonceAccount, err := db.NonceAccounts.QueryNonceAccountByAddress(wallet.Address)
+	if err != nil {
+		log.Error("query nonce account fail", "err", err)
+		return err
+	}
+	if nonceAccount != nil {
+		log.Info("nonce account already exist", "address", wallet.Address, "nonceAddress", nonceAccount.NonceAddress)
+		return nil
+	}
+
+	accountInfo, err := signClient.GenerateAddress(1)
+	if err != nil {
+		log.Error("generate nonce address fail", "err", err)
+		return err
+	}
+	if accountInfo.Code != 2000 || len(accountInfo.Addresses) == 0 {
+		return fmt.Errorf("generate nonce address fail, code: %d, msg: %s", accountInfo.Code, accountInfo.Msg)
+	}
+	nonceKey := accountInfo.Addresses[0]
+
+	recentBlockhash, err := client.GetRecentBlockHash()
+	if err != nil {
+		log.Error("get recent blockhash fail", "err", err)
+		return err
+	}
+	minRent, err := client.GetMinRent(node.NonceAccountSize)
+	if err != nil {
+		log.Error("get min rent fail", "err", err)
+		return err
+	}
+	minBalanceForRentExemption, err := strconv.ParseUint(minRent, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid min rent %s: %w", minRent, err)
+	}
+
+	prepareRep, err := signClient.PrepareAccount(&sign.PrepareAccountReq{
+		AuthorAddress:              wallet.Address,
+		FromAddress:                nonceKey.Address,
+		RecentBlockhash:            recentBlockhash.Blockhash,
+		MinBalanceForRentExemption: minBalanceForRentExemption,
+		Privs: []sign.KeyAddress{
+			{Address: nonceKey.Address, Key: nonceKey.PrivateKey},
+			{Address: wallet.Address, Key: wallet.PrivateKey},
+		},
+	})
+	if err != nil {
+		log.Error("prepare nonce account fail", "err", err)
+		return err
+	}
+	if prepareRep.Code != 2000 {
+		return fmt.Errorf("prepare nonce account fail, code: %d, msg: %s", prepareRep.Code, prepareRep.Msg)
+	}
+
+	txHash, err := client.SendRawTransaction(prepareRep.RawTx)
+	if err != nil {
+		log.Error("send create nonce account transaction fail", "err", err)
+		return err
+	}
+	// 交易上链并且链上能读到 nonce 之后才记录 nonce 账户，否则签名时会使用不存在的 nonce 账户
+	if err := awaitNonceAccount(ctx, client, txHash, nonceKey.Address, recentBlockhash.LastValidBlockHeight); err != nil {
+		log.Error("await nonce account fail", "nonceAddress", nonceKey.Address, "txHash", txHash, "err", err)
+		return err
+	}
+	log.Info("create nonce account success", "address", wallet.Address, "nonceAddress", nonceKey.Address, "txHash", txHash)
+
+	nonceAccountList := []database.NonceAccounts{{
+		GUID:         uuid.New(),
+		Address:      wallet.Address,
+		NonceAddress: nonceKey.Address,
+		Timestamp:    uint64(time.Now().Unix()),
+	}}
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	if _, err := retry.Do[interface{}](ctx, 10, retryStrategy, func() (interface{}, error) {
+		if err := db.NonceAccounts.StoreNonceAccounts(nonceAccountList, uint64(len(nonceAccountList))); err != nil {
+			log.Error("store nonce account fail", "err", err)
+			return nil, err
+		}
+		return nil, nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// awaitNonceAccount 等待创建 nonce 账户的交易上链并且能从链上读到 nonce，交易执行失败或者 blockhash 过期仍未上链时返回错误
+func awaitNonceAccount(ctx context.Context, client node.SolanaChain, txHash, nonceAddress string, lastValidBlockHeight uint64) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		statuses, err := client.GetSignatureStatuses([]string{txHash})
+		if err != nil {
+			return err
+		}
+		if status := statuses[0]; status != nil {
+			if status.Err != nil {
+				return fmt.Errorf("create nonce account transaction %s failed: %v", txHash, status.Err)
+			}
+			if _, err := client.GetNonce(nonceAddress); err == nil {
+				return nil
+			}
+		} else {
+			blockHeight, err := client.GetLatestBlockHeight(rpc.CommitmentConfirmed)
+			if err != nil {
+				return err
+			}
+			if blockHeight > lastValidBlockHeight {
+				return fmt.Errorf("create nonce account transaction %s expired at block height %d", txHash, lastValidBlockHeight)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
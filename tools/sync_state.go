@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+// ShowSyncStateTools 打印所有扫链 worker 的游标
+func ShowSyncStateTools(db *database.DB) error {
+	syncStateList, err := db.SyncState.QuerySyncStateList()
+	if err != nil {
+		log.Error("query sync state fail", "err", err)
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "WORKER\tSLOT\tUPDATED")
+	for _, syncState := range syncStateList {
+		fmt.Fprintf(writer, "%s\t%d\t%s\n", syncState.Worker, syncState.Slot, time.Unix(int64(syncState.Timestamp), 0).Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
+
+// ResetSyncStateTools 把 worker 的游标设置为 slot，下一轮从 slot+1 开始扫描。
+// 不允许退回到已入库的最新区块之前，否则这些区块中的充值会被重复入账，回退需要走分叉回滚流程
+func ResetSyncStateTools(db *database.DB, worker string, slot uint64) error {
+	if worker == database.DepositSyncWorker {
+		latestBlock, err := db.Blocks.LatestBlocks()
+		if err != nil {
+			log.Error("get latest block from database fail", "err", err)
+			return err
+		}
+		if latestBlock != nil && slot < latestBlock.Number.Uint64() {
+			return fmt.Errorf("cannot reset %s cursor to %d, below latest stored block %s", worker, slot, latestBlock.Number)
+		}
+	}
+	if err := db.SyncState.UpdateSyncState(worker, slot); err != nil {
+		log.Error("update sync state fail", "err", err)
+		return err
+	}
+	log.Info("reset sync state success", "worker", worker, "slot", slot)
+	return nil
+}
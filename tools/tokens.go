@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+// ListTokensTools 打印登记的币种以及归集门槛和热钱包水位
+func ListTokensTools(db *database.DB) error {
+	tokenList, err := db.Tokens.QueryTokenList()
+	if err != nil {
+		log.Error("query token list fail", "err", err)
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tTOKEN ADDRESS\tUNIT\tCOLLECT AMOUNT\tHOT HIGH\tHOT LOW")
+	for _, token := range tokenList {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\n", token.TokenName, token.TokenAddress, token.Unit, token.CollectAmount, token.HotHighWatermark, token.HotLowWatermark)
+	}
+	return writer.Flush()
+}
+
+// SetTokenTools 新增或更新币种配置，tokenAddress 为空表示 SOL
+func SetTokenTools(db *database.DB, tokenAddress, tokenName string, unit uint8, collectAmount, hotHighWatermark, hotLowWatermark string) error {
+	token := &database.Tokens{
+		TokenAddress: tokenAddress,
+		Unit:         unit,
+		TokenName:    tokenName,
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"collect amount", collectAmount, &token.CollectAmount},
+		{"hot high watermark", hotHighWatermark, &token.HotHighWatermark},
+		{"hot low watermark", hotLowWatermark, &token.HotLowWatermark},
+	} {
+		amount, ok := new(big.Int).SetString(field.value, 10)
+		if !ok || amount.Sign() < 0 {
+			return fmt.Errorf("invalid %s %q", field.name, field.value)
+		}
+		*field.dest = amount
+	}
+	if err := db.Tokens.UpsertToken(token); err != nil {
+		log.Error("set token fail", "err", err)
+		return err
+	}
+	log.Info("set token success", "tokenAddress", tokenAddress, "tokenName", tokenName, "collectAmount", collectAmount)
+	return nil
+}
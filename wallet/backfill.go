@@ -0,0 +1,216 @@
+package wallet
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+// backfillPageSize getSignaturesForAddress 每页最多返回的签名数
+const backfillPageSize = 1000
+
+// Backfill 按地址回补充值：通过 getSignaturesForAddress 倒序遍历地址和它名下 token 账户的历史交易，逐笔查询交易并补录漏掉的充值。
+// token 转账只引用 token 账户，不会出现在钱包地址的签名列表中，所以 token 充值要从 token 账户查。
+// 已经关闭的 token 账户不会出现在 getTokenAccountsByOwner 的结果里，所以还会按 tokens 表推导每个 token 的关联 token 账户。
+// 只处理扫链游标已经处理过的 slot，之后的 slot 留给扫链，避免同一笔充值被两边重复入账；
+// 充值以交易签名和指令序号去重，重复执行不会重复入账
+type Backfill struct {
+	db     *database.DB
+	client node.SolanaChain
+}
+
+func NewBackfill(db *database.DB, client node.SolanaChain) *Backfill {
+	return &Backfill{db: db, client: client}
+}
+
+// BackfillAddress 回补 address 在 fromSlot 及之后的充值，返回新补录的充值数量
+func (b *Backfill) BackfillAddress(ctx context.Context, address string, fromSlot uint64) (int, error) {
+	syncState, err := b.db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	if err != nil {
+		log.Error("get sync state from database fail", "err", err)
+		return 0, err
+	}
+	if syncState == nil {
+		log.Warn("deposit scanner has not started, nothing to backfill", "address", address)
+		return 0, nil
+	}
+
+	tokenAccounts, err := b.tokenAccounts(address)
+	if err != nil {
+		return 0, err
+	}
+	var signatures []node.SignatureInfo
+	seen := make(map[string]bool)
+	for _, account := range append([]string{address}, tokenAccounts...) {
+		accountSignatures, err := b.accountSignatures(account, fromSlot, syncState.Slot)
+		if err != nil {
+			log.Error("get signatures for address fail", "address", address, "account", account, "err", err)
+			return 0, err
+		}
+		// 同一笔交易可能同时涉及钱包地址和它的 token 账户
+		for _, signature := range accountSignatures {
+			if !seen[signature.Signature] {
+				seen[signature.Signature] = true
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+	// 从最早的交易开始处理
+	sort.SliceStable(signatures, func(i, j int) bool { return signatures[i].Slot < signatures[j].Slot })
+	log.Info("backfill address signatures", "address", address, "tokenAccounts", len(tokenAccounts), "fromSlot", fromSlot, "toSlot", syncState.Slot, "total", len(signatures))
+
+	total := 0
+	for _, signature := range signatures {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		count, err := b.backfillSignature(ctx, signature)
+		if err != nil {
+			log.Error("backfill signature fail", "signature", signature.Signature, "err", err)
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// tokenAccounts 合并 address 当前持有的 token 账户和按 tokens 表推导出来的关联 token 账户
+func (b *Backfill) tokenAccounts(address string) ([]string, error) {
+	openAccounts, err := b.client.GetTokenAccounts(address)
+	if err != nil {
+		log.Error("get token accounts fail", "address", address, "err", err)
+		return nil, err
+	}
+	tokenList, err := b.db.Tokens.QueryTokenList()
+	if err != nil {
+		log.Error("query token list fail", "err", err)
+		return nil, err
+	}
+	var accounts []string
+	seen := make(map[string]bool)
+	for _, account := range append(openAccounts, associatedTokenAccounts(address, tokenList)...) {
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// accountSignatures 分页倒序取出 account 在 [fromSlot, toSlot] 之间执行成功的交易签名
+func (b *Backfill) accountSignatures(account string, fromSlot, toSlot uint64) ([]node.SignatureInfo, error) {
+	var signatures []node.SignatureInfo
+	before := ""
+	for {
+		page, err := b.client.GetSignaturesForAddress(account, before, backfillPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, signature := range page {
+			if signature.Slot < fromSlot {
+				return signatures, nil
+			}
+			if signature.Err != nil || signature.Slot > toSlot {
+				continue
+			}
+			signatures = append(signatures, signature)
+		}
+		if len(page) < backfillPageSize {
+			return signatures, nil
+		}
+		before = page[len(page)-1].Signature
+	}
+}
+
+// backfillSignature 查询交易并补录其中还没有入库的充值，和扫链一样写入充值、充值交易并增加余额
+func (b *Backfill) backfillSignature(ctx context.Context, signature node.SignatureInfo) (int, error) {
+	txList, err := b.client.GetTransaction(signature.Signature, rpc.CommitmentFinalized)
+	if err != nil {
+		return 0, err
+	}
+
+	var deposits []database.Deposits
+	var depositTransactions []database.Transactions
+	// getTransaction 不返回区块哈希，有需要补录的充值时再查询区块头
+	var blockHash string
+	for _, txDetail := range txList {
+		if txDetail.Err != "" {
+			continue
+		}
+		fromAddress, err := b.db.Addresses.QueryAddressesByToAddress(txDetail.Source)
+		if err != nil {
+			return 0, err
+		}
+		toAddress, err := b.db.Addresses.QueryAddressesByToAddress(txDetail.Destination)
+		if err != nil {
+			return 0, err
+		}
+		if fromAddress != nil || toAddress == nil {
+			continue
+		}
+		depositItem, depositTx := newDepositRecords(txDetail)
+		exist, err := b.db.Deposits.ExistDeposit(&depositItem)
+		if err != nil {
+			return 0, err
+		}
+		if exist {
+			continue
+		}
+		if blockHash == "" {
+			header, err := b.client.GetBlockHeader(signature.Slot, rpc.CommitmentFinalized)
+			if err != nil {
+				return 0, err
+			}
+			blockHash = header.BlockHash
+		}
+		depositItem.BlockHash = blockHash
+		depositTx.BlockHash = blockHash
+		log.Warn("backfill missed deposit", "hash", txDetail.TxHash, "index", txDetail.InstructionIndex, "to", txDetail.Destination, "amount", txDetail.Lamports)
+		deposits = append(deposits, depositItem)
+		depositTransactions = append(depositTransactions, depositTx)
+	}
+	if len(deposits) == 0 {
+		return 0, nil
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	storedCount, err := retry.Do[int](ctx, 10, retryStrategy, func() (int, error) {
+		var storedDeposits []database.Deposits
+		if err := b.db.Transaction(func(tx *database.DB) error {
+			newDeposits, err := tx.Deposits.ExcludeStoredDeposits(deposits)
+			if err != nil {
+				return err
+			}
+			if len(newDeposits) == 0 {
+				return nil
+			}
+			// 只对实际写入的充值入账
+			storedDeposits, err = tx.Deposits.StoreDeposits(newDeposits)
+			if err != nil {
+				return err
+			}
+			storedTransactions := storedDepositTransactions(depositTransactions, storedDeposits)
+			if len(storedTransactions) == 0 {
+				return nil
+			}
+			if err := tx.Transactions.StoreTransactions(storedTransactions, uint64(len(storedTransactions))); err != nil {
+				return err
+			}
+			return tx.Balances.PostJournal(depositJournal(storedDeposits))
+		}); err != nil {
+			log.Error("unable to persist batch", "err", err)
+			return 0, err
+		}
+		return len(storedDeposits), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return storedCount, nil
+}
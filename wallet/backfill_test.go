@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestBackfill_RecoversMissedDeposits(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	for slot, amount := range map[uint64]int64{10: 1_000_000, 11: 2_000_000, 12: 4_000_000} {
+		chain.AddBlock(slot, node.TransactionDetail{
+			TxHash:      fmt.Sprintf("deposit-signature-%d", slot),
+			Source:      testExternalAddress,
+			Destination: testUserAddress,
+			Lamports:    big.NewInt(amount),
+			Type:        "transfer",
+		})
+	}
+	// 游标已经越过 slot 11，但是 slot 10 和 11 的充值没有入库
+	require.NoError(t, db.SyncState.UpdateSyncState(database.DepositSyncWorker, 11))
+
+	backfill := NewBackfill(db, chain)
+	total, err := backfill.BackfillAddress(context.Background(), testUserAddress, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	// 重复执行不会重复入账，游标之后的 slot 12 留给扫链
+	total, err = backfill.BackfillAddress(context.Background(), testUserAddress, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, total)
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 2)
+	for _, deposit := range deposits {
+		require.NotEmpty(t, deposit.BlockHash)
+	}
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(3_000_000), balance.Balance)
+}
+
+func TestBackfill_RecoversTokenAccountDeposits(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	chain := node.NewFakeChain()
+	chain.SetTokenBalance(testUserAddress, usdcMint, big.NewInt(2_500_000))
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:       "spl-deposit-signature-1",
+		Source:       testExternalAddress,
+		Destination:  testUserAddress,
+		TokenAddress: usdcMint,
+		Lamports:     big.NewInt(2_500_000),
+		Type:         "transfer",
+	})
+	require.NoError(t, db.SyncState.UpdateSyncState(database.DepositSyncWorker, 10))
+
+	// token 转账只出现在 token 账户的签名列表里
+	signatures, err := chain.GetSignaturesForAddress(testUserAddress, "", backfillPageSize)
+	require.NoError(t, err)
+	require.Empty(t, signatures)
+
+	backfill := NewBackfill(db, chain)
+	total, err := backfill.BackfillAddress(context.Background(), testUserAddress, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, usdcMint)
+	require.NoError(t, err)
+	require.NotNil(t, balance)
+	require.Equal(t, big.NewInt(2_500_000), balance.Balance)
+}
+
+func TestBackfill_RecoversClosedTokenAccountDeposits(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:  usdcMint,
+		Unit:          6,
+		TokenName:     "USDC",
+		CollectAmount: big.NewInt(1_000_000),
+	}))
+	// token 账户已经关闭，getTokenAccountsByOwner 查不到，只能按 tokens 表推导关联 token 账户
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:       "spl-deposit-signature-1",
+		Source:       testExternalAddress,
+		Destination:  testUserAddress,
+		TokenAddress: usdcMint,
+		Lamports:     big.NewInt(2_500_000),
+		Type:         "transfer",
+	})
+	require.NoError(t, db.SyncState.UpdateSyncState(database.DepositSyncWorker, 10))
+	tokenAccounts, err := chain.GetTokenAccounts(testUserAddress)
+	require.NoError(t, err)
+	require.Empty(t, tokenAccounts)
+
+	backfill := NewBackfill(db, chain)
+	total, err := backfill.BackfillAddress(context.Background(), testUserAddress, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, usdcMint)
+	require.NoError(t, err)
+	require.NotNil(t, balance)
+	require.Equal(t, big.NewInt(2_500_000), balance.Balance)
+}
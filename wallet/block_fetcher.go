@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// fetchedBlock 一个 slot 的获取结果，err 不为空时 txList 无效
+type fetchedBlock struct {
+	slot   uint64
+	txList []node.TransactionDetail
+	err    error
+}
+
+// blockFetcher 追块时并发获取区块，最多 concurrency 个请求同时进行，rateLimit 大于 0 时限制每秒请求数。
+// 结果按 slot 顺序返回，入库逻辑和逐块获取时保持一致
+type blockFetcher struct {
+	client      node.SolanaChain
+	concurrency int
+	rateLimit   uint
+}
+
+func newBlockFetcher(client node.SolanaChain, concurrency, rateLimit uint) *blockFetcher {
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	return &blockFetcher{
+		client:      client,
+		concurrency: int(concurrency),
+		rateLimit:   rateLimit,
+	}
+}
+
+// fetchBlocks 获取 [start, end) 范围内的区块，ctx 取消后未开始的请求返回 ctx.Err()
+func (f *blockFetcher) fetchBlocks(ctx context.Context, start, end uint64) []fetchedBlock {
+	if end <= start {
+		return nil
+	}
+	results := make([]fetchedBlock, end-start)
+
+	var ticker *time.Ticker
+	if f.rateLimit > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(f.rateLimit))
+		defer ticker.Stop()
+	}
+
+	var group errgroup.Group
+	group.SetLimit(f.concurrency)
+	for slot := start; slot < end; slot++ {
+		index := slot - start
+		results[index].slot = slot
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			results[index].err = err
+			continue
+		}
+		group.Go(func() error {
+			results[index].txList, results[index].err = f.client.GetBlock(slot, scanCommitment)
+			return nil
+		})
+	}
+	_ = group.Wait()
+	return results
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// slowChain 每次获取区块都等待一段时间，并记录同时进行中的最大请求数
+type slowChain struct {
+	*node.FakeChain
+	delay    time.Duration
+	inFlight atomic.Int64
+	maxSeen  atomic.Int64
+}
+
+func (c *slowChain) GetBlock(slot uint64, commitment rpc.Commitment) ([]node.TransactionDetail, error) {
+	current := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		seen := c.maxSeen.Load()
+		if current <= seen || c.maxSeen.CompareAndSwap(seen, current) {
+			break
+		}
+	}
+	// slot 越小等待越久，使得返回顺序和 slot 顺序相反
+	time.Sleep(c.delay * time.Duration(110-slot))
+	return c.FakeChain.GetBlock(slot, commitment)
+}
+
+func TestBlockFetcher_FetchBlocksInOrder(t *testing.T) {
+	chain := &slowChain{FakeChain: node.NewFakeChain(), delay: time.Millisecond}
+	for slot := uint64(100); slot < 110; slot++ {
+		if slot == 105 {
+			chain.SkipSlot(slot)
+			continue
+		}
+		chain.AddBlock(slot, node.TransactionDetail{TxHash: "tx", Lamports: big.NewInt(int64(slot))})
+	}
+
+	fetcher := newBlockFetcher(chain, 3, 0)
+	results := fetcher.fetchBlocks(context.Background(), 100, 110)
+	require.Len(t, results, 10)
+	for i, block := range results {
+		slot := uint64(100 + i)
+		require.Equal(t, slot, block.slot)
+		if slot == 105 {
+			require.True(t, errors.Is(block.err, node.ErrSlotSkipped))
+			continue
+		}
+		require.NoError(t, block.err)
+		require.Len(t, block.txList, 1)
+		require.Equal(t, big.NewInt(int64(slot)), block.txList[0].Lamports)
+	}
+	require.LessOrEqual(t, chain.maxSeen.Load(), int64(3))
+	require.Greater(t, chain.maxSeen.Load(), int64(1))
+}
+
+func TestBlockFetcher_RateLimit(t *testing.T) {
+	chain := node.NewFakeChain()
+	for slot := uint64(100); slot < 105; slot++ {
+		chain.AddBlock(slot)
+	}
+
+	fetcher := newBlockFetcher(chain, 5, 50)
+	begin := time.Now()
+	results := fetcher.fetchBlocks(context.Background(), 100, 105)
+	require.Len(t, results, 5)
+	// 每秒 50 个请求，5 个请求至少需要等待 5 个 20ms 的间隔
+	require.GreaterOrEqual(t, time.Since(begin), 100*time.Millisecond)
+}
@@ -18,22 +18,17 @@ import (
 	"github.com/the-web3/sol-wallet/wallet/retry"
 )
 
-var (
-	CollectionFunding = big.NewInt(10000000000000000)
-	ColdFunding       = big.NewInt(2000000000000000000)
-)
-
 type CollectionCold struct {
 	db             *database.DB
 	chainConf      *config.ChainConfig
-	client         node.SolanaClient
-	signClient     *sign.Client
+	client         node.SolanaChain
+	signClient     sign.SolSignClient
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
 	tasks          tasks.Group
 }
 
-func NewCollectionCold(cfg *config.Config, db *database.DB, client node.SolanaClient, signCli *sign.Client, shutdown context.CancelCauseFunc) (*CollectionCold, error) {
+func NewCollectionCold(cfg *config.Config, db *database.DB, client node.SolanaChain, signCli sign.SolSignClient, shutdown context.CancelCauseFunc) (*CollectionCold, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &CollectionCold{
 		db:             db,
@@ -52,27 +47,33 @@ func (cc *CollectionCold) Close() error {
 	var result error
 	cc.resourceCancel()
 	if err := cc.tasks.Wait(); err != nil {
-		result = errors.Join(result, fmt.Errorf("failed to await deposit %w"), err)
+		result = errors.Join(result, fmt.Errorf("failed to await deposit %w", err))
 	}
 	return nil
 }
 
 func (cc *CollectionCold) Start() error {
 	log.Info("start collection and cold......")
-	tickerCollectionColdWorker := time.NewTicker(time.Second * 5)
+	// 三个任务各用一个 ticker，共用一个 ticker 时每次只有其中一个任务能收到
+	tickerCollectionWorker := time.NewTicker(time.Second * 5)
 	cc.tasks.Go(func() error {
-		for range tickerCollectionColdWorker.C {
+		for range tickerCollectionWorker.C {
 			err := cc.Collection()
 			if err != nil {
 				log.Error("collect fail", "err", err)
 				return err
 			}
+			if err := cc.trackTransactions(); err != nil {
+				log.Error("track transactions fail", "err", err)
+				return err
+			}
 		}
 		return nil
 	})
 
+	tickerColdWorker := time.NewTicker(time.Second * 5)
 	cc.tasks.Go(func() error {
-		for range tickerCollectionColdWorker.C {
+		for range tickerColdWorker.C {
 			err := cc.ToCold()
 			if err != nil {
 				log.Error("to cold fail", "err", err)
@@ -82,19 +83,50 @@ func (cc *CollectionCold) Start() error {
 		return nil
 	})
 
+	tickerReplenishWorker := time.NewTicker(time.Second * 5)
+	cc.tasks.Go(func() error {
+		for range tickerReplenishWorker.C {
+			err := cc.Replenish()
+			if err != nil {
+				log.Error("replenish hot wallet fail", "err", err)
+				return err
+			}
+		}
+		return nil
+	})
+
 	return nil
 }
 
 func (cc *CollectionCold) ToCold() error {
-	hotWalletBalancesList, err := cc.db.Balances.QueryHotWalletBalances(ColdFunding)
+	hotWalletBalancesList, err := cc.db.Balances.QueryHotWalletBalances()
 	if err != nil {
 		log.Error("to cold query hot wallet info fail", "err", err)
 		return err
 	}
 	var txList []database.Transactions
-	balanceForStore := make([]database.Balances, len(hotWalletBalancesList))
+	var journalList []database.BalanceJournal
 	for _, value := range hotWalletBalancesList {
-		index := 0
+		token, err := cc.db.Tokens.TokensInfoByAddress(value.TokenAddress)
+		if err != nil {
+			log.Error("query token info fail", "err", err)
+			return err
+		}
+		if token == nil {
+			continue
+		}
+		// 转冷后热钱包保留 hot_low_watermark，还没有发送的提现尚未锁定余额，也要留在热钱包
+		unSendAmount, err := cc.db.Withdraws.SumUnSendWithdraws(value.Address, value.TokenAddress)
+		if err != nil {
+			log.Error("sum un send withdraws fail", "err", err)
+			return err
+		}
+		amount := new(big.Int).Sub(value.Balance, token.HotLowWatermark)
+		amount.Sub(amount, unSendAmount)
+		if amount.Sign() <= 0 {
+			continue
+		}
+
 		coldWalletInfo, err := cc.db.Addresses.QueryColdWalletInfo()
 		if err != nil {
 			log.Error("query cold wallet info err", "err", err)
@@ -102,26 +134,29 @@ func (cc *CollectionCold) ToCold() error {
 		}
 
 		// nonce
-		recentBlockhash, err := cc.client.GetRecentBlockHash()
+		hotAccount, err := cc.db.Addresses.QueryHotWalletInfo()
 		if err != nil {
-			log.Error("query nonce by address fail", "err", err)
+			log.Error("query account info by address fail", "err", err)
 			return err
 		}
 
-		hotAccount, err := cc.db.Addresses.QueryHotWalletInfo()
+		nonce, err := queryTxNonce(cc.db, cc.client, cc.chainConf.DurableNonce, hotAccount.Address)
 		if err != nil {
-			log.Error("query account info by address fail", "err", err)
+			log.Error("query nonce by address fail", "err", err)
 			return err
 		}
+		if nonce == nil {
+			continue
+		}
 
 		//  sendRawTx
 		txReq := &sign.TransactionReq{
 			FromAddress:  hotAccount.Address,
 			ToAddress:    coldWalletInfo.Address,
-			Amount:       value.Balance.String(),
-			NonceAccount: hotAccount.Address,
-			Nonce:        recentBlockhash,
-			Decimal:      9,
+			Amount:       amount.String(),
+			NonceAccount: nonce.NonceAccount,
+			Nonce:        nonce.Nonce,
+			Decimal:      uint64(token.Unit),
 			PrivateKey:   hotAccount.PrivateKey,
 			MintAddress:  value.TokenAddress,
 		}
@@ -134,40 +169,51 @@ func (cc *CollectionCold) ToCold() error {
 
 		if txRep.Code != 2000 {
 			log.Error("sign server occur unknown error")
+			if err := releaseTxNonce(cc.db, nonce); err != nil {
+				return err
+			}
 			continue
 		}
 
 		txHash, err := cc.client.SendRawTransaction(txRep.RawTx)
 		if err != nil {
 			log.Error("send raw transaction fail", "err", err)
+			if errors.Is(err, node.ErrTransactionRejected) {
+				if err := releaseTxNonce(cc.db, nonce); err != nil {
+					return err
+				}
+				continue
+			}
 			return err
 		}
 
 		guid, _ := uuid.NewUUID()
 		coldTx := database.Transactions{
-			GUID:         guid,
-			BlockHash:    "",
-			BlockNumber:  nil,
-			Hash:         txHash,
-			FromAddress:  value.Address,
-			ToAddress:    coldWalletInfo.Address,
-			TokenAddress: value.TokenAddress,
-			Fee:          big.NewInt(0),
-			Amount:       value.Balance,
-			Status:       0,
-			TxType:       2,
-			Timestamp:    uint64(time.Time{}.Unix()),
+			GUID:                 guid,
+			BlockHash:            "",
+			BlockNumber:          nil,
+			Hash:                 txHash,
+			FromAddress:          value.Address,
+			ToAddress:            coldWalletInfo.Address,
+			TokenAddress:         value.TokenAddress,
+			Fee:                  big.NewInt(0),
+			Amount:               amount,
+			Status:               0,
+			TxType:               3,
+			NonceAccount:         nonce.NonceAccount,
+			Nonce:                nonce.Nonce,
+			LastValidBlockHeight: nonce.LastValidBlockHeight,
+			TxSignHex:            txRep.RawTx,
+			Timestamp:            uint64(time.Now().Unix()),
 		}
 		txList = append(txList, coldTx)
-		balanceForStore[index].LockBalance = new(big.Int).Sub(balanceForStore[index].Balance, ColdFunding)
-		balanceForStore[index].Address = value.Address
-		balanceForStore[index].TokenAddress = value.TokenAddress
+		journalList = append(journalList, lockJournal(guid, database.JournalColdLock, value.Address, value.TokenAddress, amount)...)
 	}
 	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
 	if _, err := retry.Do[interface{}](cc.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
 		if err := cc.db.Transaction(func(tx *database.DB) error {
-			if len(hotWalletBalancesList) > 0 {
-				if err := tx.Balances.UpdateBalances(balanceForStore, false); err != nil {
+			if len(journalList) > 0 {
+				if err := tx.Balances.PostJournal(journalList); err != nil {
 					return err
 				}
 			}
@@ -188,9 +234,10 @@ func (cc *CollectionCold) ToCold() error {
 	return nil
 }
 
-// Collection 归集
+// Collection 归集。SOL 归集金额扣掉手续费和可选的免租金保留额；token 归集由热钱包付手续费，用户地址不需要持有 SOL，
+// 关闭 token 账户时租金退回热钱包，归集上链后记账
 func (cc *CollectionCold) Collection() error {
-	unCollectionList, err := cc.db.Balances.UnCollectionList(CollectionFunding)
+	unCollectionList, err := cc.db.Balances.UnCollectionList()
 	if err != nil {
 		log.Error("query uncollection fail", "err", err)
 		return err
@@ -202,7 +249,35 @@ func (cc *CollectionCold) Collection() error {
 		return err
 	}
 
+	fee := new(big.Int).SetUint64(cc.chainConf.CollectionFee)
+	rentReserve := big.NewInt(0)
+	if cc.chainConf.CollectionRentReserve {
+		rentReserve, err = cc.queryMinRent(node.SystemAccountSize)
+		if err != nil {
+			return err
+		}
+	}
+	rentRefund := big.NewInt(0)
+	if cc.chainConf.CloseTokenAccount {
+		rentRefund, err = cc.queryMinRent(node.TokenAccountSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	// token 归集的手续费从热钱包的 SOL 可用余额扣，不够付手续费时跳过 token 归集
+	hotSolBalance, err := cc.db.Balances.QueryWalletBalanceByTokenAndAddress(hotWalletInfo.Address, "")
+	if err != nil {
+		log.Error("query hot wallet balance fail", "err", err)
+		return err
+	}
+	hotFeeBalance := big.NewInt(0)
+	if hotSolBalance != nil {
+		hotFeeBalance.Set(hotSolBalance.Balance)
+	}
+
 	var txList []database.Transactions
+	var journalList []database.BalanceJournal
 	for _, uncollect := range unCollectionList {
 		accountInfo, err := cc.db.Addresses.QueryAddressesByToAddress(uncollect.Address)
 		if err != nil {
@@ -210,24 +285,62 @@ func (cc *CollectionCold) Collection() error {
 			return err
 		}
 
-		// nonce
-		recentBlockHash, err := cc.client.GetRecentBlockHash()
+		token, err := cc.db.Tokens.TokensInfoByAddress(uncollect.TokenAddress)
+		if err != nil {
+			log.Error("query token info fail", "err", err)
+			return err
+		}
+		if token == nil {
+			continue
+		}
+
+		isToken := uncollect.TokenAddress != ""
+		amount := new(big.Int).Set(uncollect.Balance)
+		feePayer := uncollect.Address
+		txRentRefund := big.NewInt(0)
+		if isToken {
+			if hotFeeBalance.Cmp(fee) < 0 {
+				log.Warn("hot wallet balance not enough for token collection fee", "address", uncollect.Address, "tokenAddress", uncollect.TokenAddress, "hotBalance", hotFeeBalance)
+				continue
+			}
+			feePayer = hotWalletInfo.Address
+			if cc.chainConf.CloseTokenAccount {
+				txRentRefund = rentRefund
+			}
+		} else {
+			amount.Sub(amount, fee).Sub(amount, rentReserve)
+			if amount.Sign() <= 0 {
+				log.Warn("collection amount not enough for fee and rent", "address", uncollect.Address, "balance", uncollect.Balance)
+				continue
+			}
+		}
+
+		// token 归集的手续费和 nonce 都由热钱包提供
+		nonce, err := queryTxNonce(cc.db, cc.client, cc.chainConf.DurableNonce, feePayer)
 		if err != nil {
 			log.Error("query nonce by address fail", "err", err)
 			return err
 		}
+		if nonce == nil {
+			continue
+		}
 
 		//  sendRawTx
 		txReq := &sign.TransactionReq{
 			FromAddress:  uncollect.Address,
 			ToAddress:    hotWalletInfo.Address,
-			Amount:       uncollect.Balance.String(),
-			NonceAccount: uncollect.Address,
-			Nonce:        recentBlockHash,
-			Decimal:      9,
+			Amount:       amount.String(),
+			NonceAccount: nonce.NonceAccount,
+			Nonce:        nonce.Nonce,
+			Decimal:      uint64(token.Unit),
 			PrivateKey:   accountInfo.PrivateKey,
 			MintAddress:  uncollect.TokenAddress,
 		}
+		if isToken {
+			txReq.FeePayer = hotWalletInfo.Address
+			txReq.FeePayerPrivateKey = hotWalletInfo.PrivateKey
+			txReq.CloseAccount = cc.chainConf.CloseTokenAccount
+		}
 
 		txRep, err := cc.signClient.SignTransaction(txReq)
 		if err != nil {
@@ -237,44 +350,67 @@ func (cc *CollectionCold) Collection() error {
 
 		if txRep.Code != 2000 {
 			log.Error("sign server occur unknown error")
+			if err := releaseTxNonce(cc.db, nonce); err != nil {
+				return err
+			}
 			continue
 		}
 
 		txHash, err := cc.client.SendRawTransaction(txRep.RawTx)
 		if err != nil {
 			log.Error("send raw transaction fail", "err", err)
+			if errors.Is(err, node.ErrTransactionRejected) {
+				if err := releaseTxNonce(cc.db, nonce); err != nil {
+					return err
+				}
+				continue
+			}
 			return err
 		}
 		guid, _ := uuid.NewUUID()
 		collection := database.Transactions{
-			GUID:         guid,
-			BlockHash:    "",
-			BlockNumber:  big.NewInt(1),
-			Hash:         txHash,
-			FromAddress:  uncollect.Address,
-			ToAddress:    hotWalletInfo.Address,
-			TokenAddress: uncollect.TokenAddress,
-			Fee:          big.NewInt(1),
-			Amount:       uncollect.Balance,
-			Status:       0,
-			TxType:       2,
-			Timestamp:    uint64(time.Now().Unix()),
+			GUID:                 guid,
+			BlockHash:            "",
+			BlockNumber:          big.NewInt(1),
+			Hash:                 txHash,
+			FromAddress:          uncollect.Address,
+			ToAddress:            hotWalletInfo.Address,
+			TokenAddress:         uncollect.TokenAddress,
+			Fee:                  fee,
+			Amount:               amount,
+			Status:               0,
+			TxType:               2,
+			RentRefund:           txRentRefund,
+			NonceAccount:         nonce.NonceAccount,
+			Nonce:                nonce.Nonce,
+			LastValidBlockHeight: nonce.LastValidBlockHeight,
+			TxSignHex:            txRep.RawTx,
+			Timestamp:            uint64(time.Now().Unix()),
 		}
 		txList = append(txList, collection)
+		journalList = append(journalList, lockJournal(guid, database.JournalCollectionLock, uncollect.Address, uncollect.TokenAddress, amount)...)
+		if isToken {
+			hotFeeBalance.Sub(hotFeeBalance, fee)
+		}
+		if fee.Sign() > 0 {
+			journalList = append(journalList, database.NewJournalEntries(guid, database.JournalCollectionFee, "", fee,
+				database.JournalAccount{Address: feePayer, Account: database.JournalAccountBalance},
+				database.JournalAccount{Address: feePayer, Account: database.JournalAccountExternal})...)
+		}
 	}
 	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
 	if _, err := retry.Do[interface{}](cc.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
 		if err := cc.db.Transaction(func(tx *database.DB) error {
-			if len(unCollectionList) > 0 {
-				if err := tx.Balances.UpdateBalances(unCollectionList, true); err != nil {
+			if len(journalList) > 0 {
+				if err := tx.Balances.PostJournal(journalList); err != nil {
 					return err
 				}
 			}
-
-			if err := tx.Transactions.StoreTransactions(txList, uint64(len(txList))); err != nil {
-				return err
+			if len(txList) > 0 {
+				if err := tx.Transactions.StoreTransactions(txList, uint64(len(txList))); err != nil {
+					return err
+				}
 			}
-
 			return nil
 		}); err != nil {
 			log.Error("unable to persist batch", "err", err)
@@ -286,3 +422,17 @@ func (cc *CollectionCold) Collection() error {
 	}
 	return nil
 }
+
+// queryMinRent 查询数据长度为 dataLen 的账户免租金的最低余额
+func (cc *CollectionCold) queryMinRent(dataLen uint64) (*big.Int, error) {
+	minRent, err := cc.client.GetMinRent(dataLen)
+	if err != nil {
+		log.Error("query min rent fail", "dataLen", dataLen, "err", err)
+		return nil, err
+	}
+	rent, ok := new(big.Int).SetString(minRent, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid min rent %s", minRent)
+	}
+	return rent, nil
+}
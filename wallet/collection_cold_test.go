@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestCollectionCold_Collection(t *testing.T) {
+	db := newTestDB(t)
+	// migrations 登记的 SOL 归集门槛为 0.1 SOL
+	userBalance := big.NewInt(2_000_000_000)
+	storeTestWallets(t, db, userBalance.Uint64(), 0)
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	collection, err := NewCollectionCold(newTestConfig(), db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	require.NoError(t, collection.Collection())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, testUserAddress, signer.signed[0].FromAddress)
+	require.Equal(t, testHotAddress, signer.signed[0].ToAddress)
+	require.Len(t, chain.SentTransactions(), 1)
+
+	collectTx, err := db.Transactions.QueryTransactionByHash("fake-signature-1")
+	require.NoError(t, err)
+	require.NotNil(t, collectTx)
+	require.Equal(t, uint8(2), collectTx.TxType)
+	require.Equal(t, uint8(0), collectTx.Status)
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, balance.Balance.Sign())
+	require.Equal(t, userBalance, balance.LockBalance)
+}
+
+func TestCollectionCold_CollectionFeeRentAndFeePayer(t *testing.T) {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	db := newTestDB(t)
+	userBalance := big.NewInt(2_000_000_000)
+	storeTestWallets(t, db, userBalance.Uint64(), 1_000_000)
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:  usdcMint,
+		Unit:          6,
+		TokenName:     "USDC",
+		CollectAmount: big.NewInt(1_000_000),
+	}))
+	tokenBalance := big.NewInt(3_000_000)
+	require.NoError(t, db.Balances.StoreBalances([]database.Balances{{
+		GUID:         uuid.New(),
+		Address:      testUserAddress,
+		TokenAddress: usdcMint,
+		AddressType:  0,
+		Balance:      tokenBalance,
+		LockBalance:  big.NewInt(0),
+		Timestamp:    uint64(time.Now().Unix()),
+	}}, 1))
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	cfg := newTestConfig()
+	cfg.Chain.CollectionFee = 5000
+	cfg.Chain.CollectionRentReserve = true
+	cfg.Chain.CloseTokenAccount = true
+	collection, err := NewCollectionCold(cfg, db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, collection.Collection())
+	require.Len(t, signer.signed, 2)
+
+	minRent, err := chain.GetMinRent(node.SystemAccountSize)
+	require.NoError(t, err)
+	rent, _ := new(big.Int).SetString(minRent, 10)
+	tokenCollectionHash := ""
+	for i, req := range signer.signed {
+		if req.MintAddress == "" {
+			// SOL 归集扣掉手续费和免租金保留额，由用户地址付手续费
+			expected := new(big.Int).Sub(userBalance, big.NewInt(5000))
+			expected.Sub(expected, rent)
+			require.Equal(t, expected.String(), req.Amount)
+			require.Empty(t, req.FeePayer)
+			continue
+		}
+		// token 全额归集，热钱包付手续费并关闭用户 token 账户
+		tokenCollectionHash = fmt.Sprintf("fake-signature-%d", i+1)
+		require.Equal(t, tokenBalance.String(), req.Amount)
+		require.Equal(t, uint64(6), req.Decimal)
+		require.Equal(t, testHotAddress, req.FeePayer)
+		require.True(t, req.CloseAccount)
+	}
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, rent, balance.Balance)
+	tokenRecord, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, usdcMint)
+	require.NoError(t, err)
+	require.Equal(t, 0, tokenRecord.Balance.Sign())
+	require.Equal(t, tokenBalance, tokenRecord.LockBalance)
+	// token 归集的手续费记在热钱包
+	requireBalance(t, db, testHotAddress, 995_000, 0)
+
+	// token 归集上链后关闭 token 账户退回的租金记入热钱包
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:       tokenCollectionHash,
+		Source:       testUserAddress,
+		Destination:  testHotAddress,
+		TokenAddress: usdcMint,
+		Lamports:     tokenBalance,
+		Type:         "transfer",
+	})
+	deposit, err := NewDeposit(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+	tokenRent, err := chain.GetMinRent(node.TokenAccountSize)
+	require.NoError(t, err)
+	expectedHot, _ := new(big.Int).SetString(tokenRent, 10)
+	expectedHot.Add(expectedHot, big.NewInt(995_000))
+	requireBalance(t, db, testHotAddress, expectedHot.Int64(), 0)
+}
+
+func TestCollectionCold_ToColdAboveHighWatermark(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000_000)
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	collection, err := NewCollectionCold(newTestConfig(), db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	// 没有配置水位时不转冷
+	require.NoError(t, collection.ToCold())
+	require.Len(t, signer.signed, 0)
+
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:     "",
+		Unit:             9,
+		TokenName:        "SOL",
+		CollectAmount:    big.NewInt(100_000_000),
+		HotHighWatermark: big.NewInt(4_000_000_000),
+		HotLowWatermark:  big.NewInt(1_000_000_000),
+	}))
+	require.NoError(t, collection.ToCold())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, testColdAddress, signer.signed[0].ToAddress)
+	require.Equal(t, "4000000000", signer.signed[0].Amount)
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1_000_000_000), balance.Balance)
+}
+
+func TestCollectionCold_ToColdKeepsUnSendWithdraws(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000_000)
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:     "",
+		Unit:             9,
+		TokenName:        "SOL",
+		CollectAmount:    big.NewInt(100_000_000),
+		HotHighWatermark: big.NewInt(4_000_000_000),
+		HotLowWatermark:  big.NewInt(1_000_000_000),
+	}))
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_500_000_000)))
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	collection, err := NewCollectionCold(newTestConfig(), db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	// 还没有发送的提现留在热钱包，只转出低水位和待发送提现之外的部分
+	require.NoError(t, collection.ToCold())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, "2500000000", signer.signed[0].Amount)
+}
+
+func TestCollectionCold_TrackTransactionsReleaseExpired(t *testing.T) {
+	db := newTestDB(t)
+	userBalance := big.NewInt(2_000_000_000)
+	storeTestWallets(t, db, userBalance.Uint64(), 0)
+
+	chain := node.NewFakeChain()
+	cfg := newTestConfig()
+	cfg.Chain.CollectionFee = 5000
+	collection, err := NewCollectionCold(cfg, db, chain, &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, collection.Collection())
+	requireBalance(t, db, testUserAddress, 0, 2_000_000_000-5000)
+
+	// blockhash 还没有过期，继续等待上链
+	chain.AddBlock(100)
+	require.NoError(t, collection.trackTransactions())
+	collectTx, err := db.Transactions.QueryTransactionByHash("fake-signature-1")
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), collectTx.Status)
+
+	// 超过 lastValidBlockHeight 仍未上链，归集失败，释放锁定余额并冲正手续费，下一轮归集重新发送
+	chain.AddBlock(151)
+	require.NoError(t, collection.trackTransactions())
+	collectTx, err = db.Transactions.QueryTransactionByHash("fake-signature-1")
+	require.NoError(t, err)
+	require.Equal(t, uint8(4), collectTx.Status)
+	require.Equal(t, errTransactionExpired, collectTx.ErrCode)
+
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, userBalance, balance.Balance)
+	require.Equal(t, 0, balance.LockBalance.Sign())
+
+	require.NoError(t, collection.Collection())
+	require.Len(t, chain.SentTransactions(), 2)
+}
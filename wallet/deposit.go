@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/blocto/solana-go-sdk/rpc"
 	"github.com/google/uuid"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -18,23 +19,29 @@ import (
 	"github.com/the-web3/sol-wallet/wallet/retry"
 )
 
+// scanCommitment 扫块使用 confirmed 级别，充值先以确认中(status=0)入库，
+// 等 slot 达到配置的确认级别后再更新为已到账
+const scanCommitment = rpc.CommitmentConfirmed
+
 type Deposit struct {
 	db        *database.DB
 	chainConf *config.ChainConfig
 
-	client node.SolanaClient
+	client  node.SolanaChain
+	fetcher *blockFetcher
 
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
 	tasks          tasks.Group
 }
 
-func NewDeposit(cfg *config.Config, db *database.DB, client node.SolanaClient, shutdown context.CancelCauseFunc) (*Deposit, error) {
+func NewDeposit(cfg *config.Config, db *database.DB, client node.SolanaChain, shutdown context.CancelCauseFunc) (*Deposit, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &Deposit{
 		db:             db,
 		chainConf:      &cfg.Chain,
 		client:         client,
+		fetcher:        newBlockFetcher(client, cfg.Chain.FetchConcurrency, cfg.Chain.FetchRateLimit),
 		resourceCtx:    resCtx,
 		resourceCancel: resCancel,
 		tasks: tasks.Group{HandleCrit: func(err error) {
@@ -47,7 +54,7 @@ func (d *Deposit) Close() error {
 	var result error
 	d.resourceCancel()
 	if err := d.tasks.Wait(); err != nil {
-		result = errors.Join(result, fmt.Errorf("failed to await deposit %w"), err)
+		result = errors.Join(result, fmt.Errorf("failed to await deposit %w", err))
 		return result
 	}
 	return nil
@@ -58,113 +65,247 @@ func (d *Deposit) Start() error {
 	tickerDepositWorker := time.NewTicker(time.Second * 5)
 	d.tasks.Go(func() error {
 		for range tickerDepositWorker.C {
-			// 获取最新区块高度，并且获取数据库里面上次同步到高度，比较这两个高度，如果数据库里面的高度等于最新区块高度，不再往下执行交易解析，继续扫描最新的块
-			// 如果是第一次进入，那么以配置起始高度开始网上同步，若起始高度没有配置或者配置是 0，那么就是 0 开始同步
-			// 每次同步按照配置的同步步长往下执行
-			var startSyncBlock *big.Int
-			dbLastestBlock, err := d.db.Blocks.LatestBlocks()
-			if err != nil {
-				log.Error("get latest block from database fail", "err", err)
+			if err := d.processBatch(); err != nil {
 				return err
 			}
-			if dbLastestBlock == nil {
-				startSyncBlock = big.NewInt(int64(d.chainConf.StartingHeight))
-			} else {
-				startSyncBlock = dbLastestBlock.Number
-			}
+		}
+		return nil
+	})
+	return nil
+}
 
-			chainLatestBlock, err := d.client.GetCurrentSlot()
-			if err != nil {
-				log.Error("get latest block from solana chain fail", "err", err)
+// processBatch 执行一轮扫块：检查分叉，按步长扫描区块并在一个数据库事务里入库，最后更新充值确认状态
+func (d *Deposit) processBatch() error {
+	// 获取最新区块高度，并且获取数据库里面上次同步到高度，比较这两个高度，如果数据库里面的高度等于最新区块高度，不再往下执行交易解析，继续扫描最新的块
+	// 如果是第一次进入，那么以配置起始高度开始网上同步，若起始高度没有配置或者配置是 0，那么就是 0 开始同步
+	// 每次同步按照配置的同步步长往下执行
+	forkBlock, err := d.detectReorg()
+	if err != nil {
+		log.Error("detect reorg fail", "err", err)
+		return err
+	}
+	if forkBlock != nil {
+		log.Warn("chain reorg detected, rollback to fork block", "forkBlock", forkBlock)
+		if err := d.rollbackToBlock(forkBlock); err != nil {
+			log.Error("rollback orphaned blocks fail", "err", err)
+			return err
+		}
+	}
+
+	startSyncBlock, err := d.syncStartBlock()
+	if err != nil {
+		return err
+	}
+
+	chainLatestBlock, err := d.client.GetCurrentSlot(scanCommitment)
+	if err != nil {
+		log.Error("get latest block from solana chain fail", "err", err)
+		return err
+	}
+
+	if startSyncBlock.Cmp(new(big.Int).SetUint64(chainLatestBlock)) > 0 {
+		if err := d.confirmDeposits(); err != nil {
+			log.Error("confirm deposits fail", "err", err)
+			return err
+		}
+		return nil
+	}
+
+	// 按照步长处理，不超过链上 confirmed 的最新 slot
+	endSyncBlock := new(big.Int).Add(startSyncBlock, big.NewInt(int64(d.chainConf.BlocksStep)))
+	if endSyncBlock.Cmp(new(big.Int).SetUint64(chainLatestBlock+1)) > 0 {
+		endSyncBlock = new(big.Int).SetUint64(chainLatestBlock + 1)
+	}
+
+	blocks, skippedSlots, deposits, withdraws, depositTransactions, outherTransactions, failedTransactions, nextSyncBlock, err := d.processTransactions(startSyncBlock, endSyncBlock)
+	if err != nil {
+		log.Error("process transaction fail", "err", err)
+		return err
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	if _, err := retry.Do[interface{}](d.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
+		if err := d.db.Transaction(func(tx *database.DB) error {
+			if err := tx.Blocks.StoreBlockss(blocks, uint64(len(blocks))); err != nil {
 				return err
 			}
 
-			if startSyncBlock.Cmp(big.NewInt(int64(chainLatestBlock))) >= 0 {
-				continue
+			if len(skippedSlots) > 0 {
+				if err := tx.SkippedSlots.StoreSkippedSlots(skippedSlots, uint64(len(skippedSlots))); err != nil {
+					return err
+				}
 			}
 
-			// 按照步长处理
-			endSyncBlock := new(big.Int).Add(startSyncBlock, big.NewInt(int64(d.chainConf.BlocksStep)))
+			// 订阅提前写入的充值由扫链结果替换，链上已不存在的直接删除
+			if nextSyncBlock > startSyncBlock.Uint64() {
+				if err := tx.Deposits.DeleteSubscribedDeposits(nextSyncBlock - 1); err != nil {
+					return err
+				}
+			}
 
-			blocks, deposits, withdraws, depositTransactions, outherTransactions, tokenBalances, err := d.processTransactions(startSyncBlock, endSyncBlock)
+			// 重试或者重新扫描同一段 slot 时，已经入库的充值不再重复入账
+			newDeposits, err := tx.Deposits.ExcludeStoredDeposits(deposits)
 			if err != nil {
-				log.Error("process transaction fail", "err", err)
 				return err
 			}
+			// 只对本次实际写入的充值入账，并发写入或者唯一索引冲突跳过的充值不会重复入账
+			var storedDeposits []database.Deposits
+			if len(newDeposits) > 0 {
+				storedDeposits, err = tx.Deposits.StoreDeposits(newDeposits)
+				if err != nil {
+					return err
+				}
+				log.Info("Store deposit transaction success", "totalTx", len(storedDeposits))
+			}
+			// 游标和充值在同一个事务里推进，没有相关交易的 slot 也会推进游标
+			if nextSyncBlock > startSyncBlock.Uint64() {
+				if err := tx.SyncState.UpdateSyncState(database.DepositSyncWorker, nextSyncBlock-1); err != nil {
+					return err
+				}
+			}
+			log.Info("batch latest block number", "endSyncBlock", endSyncBlock)
 
-			retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
-			if _, err := retry.Do[interface{}](d.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
-				if err := d.db.Transaction(func(tx *database.DB) error {
-					if err := tx.Blocks.StoreBlockss(blocks, uint64(len(blocks))); err != nil {
-						return err
-					}
-
-					if len(deposits) > 0 {
-						log.Info("Store deposit transaction success", "totalTx", len(deposits))
-						if err := tx.Deposits.StoreDeposits(deposits, uint64(len(deposits))); err != nil {
-							return err
-						}
-					}
-					log.Info("batch latest block number", "endSyncBlock", endSyncBlock)
-
-					// 更新之前充值确认位
-					if err := tx.Deposits.UpdateDepositsStatus(endSyncBlock.Uint64() - uint64(d.chainConf.Confirmations)); err != nil {
-						return err
-					}
+			if len(withdraws) > 0 {
+				if err := tx.Withdraws.UpdateTransactionStatus(withdraws); err != nil {
+					return err
+				}
+			}
 
-					if len(withdraws) > 0 {
-						if err := tx.Withdraws.UpdateTransactionStatus(withdraws); err != nil {
-							return err
-						}
-					}
+			if storedTransactions := storedDepositTransactions(depositTransactions, storedDeposits); len(storedTransactions) > 0 {
+				if err := tx.Transactions.StoreTransactions(storedTransactions, uint64(len(storedTransactions))); err != nil {
+					return err
+				}
+			}
 
-					if len(depositTransactions) > 0 {
-						if err := tx.Transactions.StoreTransactions(depositTransactions, uint64(len(depositTransactions))); err != nil {
-							return err
-						}
+			if len(outherTransactions) > 0 { // 归集、热转冷和冷转热
+				if err := tx.Transactions.UpdateTransactionStatus(outherTransactions); err != nil {
+					return err
+				}
+				for _, item := range outherTransactions {
+					if item.TxType != 4 {
+						continue
 					}
-
-					if len(outherTransactions) > 0 { // 提现和归集
-						if err := tx.Transactions.UpdateTransactionStatus(outherTransactions); err != nil {
-							return err
-						}
+					if err := tx.ColdHotRequests.UpdateColdHotRequestStatusByHash(item.Hash, database.ColdHotConfirmed); err != nil {
+						return err
 					}
+				}
+			}
 
-					if len(tokenBalances) > 0 {
-						log.Info("update or store token balance", "tokenBalanceList", len(tokenBalances))
-						if err := tx.Balances.UpdateOrCreate(tokenBalances); err != nil {
-							return err
-						}
-					}
-					return nil
-				}); err != nil {
-					log.Error("unable to persist batch", "err", err)
-					return nil, err
+			if len(failedTransactions) > 0 {
+				if err := markFailedTransactions(tx, failedTransactions); err != nil {
+					return err
 				}
-				return nil, nil
-			}); err != nil {
+			}
+
+			settleList, err := outgoingJournal(tx, withdraws, outherTransactions)
+			if err != nil {
 				return err
 			}
+			journalList := append(depositJournal(storedDeposits), settleList...)
+			if len(journalList) > 0 {
+				log.Info("post balance journal", "journalList", len(journalList))
+				if err := tx.Balances.PostJournal(journalList); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Error("unable to persist batch", "err", err)
+			return nil, err
 		}
-		return nil
-	})
+		return nil, nil
+	}); err != nil {
+		return err
+	}
+
+	if err := d.confirmDeposits(); err != nil {
+		log.Error("confirm deposits fail", "err", err)
+		return err
+	}
 	return nil
 }
 
-func (d *Deposit) processTransactions(startSyncBlock, endSyncBlock *big.Int) ([]database.Blocks, []database.Deposits, []database.Withdraws, []database.Transactions, []database.Transactions, []database.TokenBalance, error) {
+// syncStartBlock 本轮扫描的起始 slot：优先使用 sync_state 中记录的游标，
+// 没有游标时兼容旧数据使用 blocks 表中最新的区块，都没有时使用配置的起始高度
+func (d *Deposit) syncStartBlock() (*big.Int, error) {
+	syncState, err := d.db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	if err != nil {
+		log.Error("get sync state from database fail", "err", err)
+		return nil, err
+	}
+	if syncState != nil {
+		return new(big.Int).SetUint64(syncState.Slot + 1), nil
+	}
+	dbLastestBlock, err := d.db.Blocks.LatestBlocks()
+	if err != nil {
+		log.Error("get latest block from database fail", "err", err)
+		return nil, err
+	}
+	if dbLastestBlock == nil {
+		return big.NewInt(int64(d.chainConf.StartingHeight)), nil
+	}
+	return new(big.Int).Add(dbLastestBlock.Number, big.NewInt(1)), nil
+}
+
+// confirmDeposits 查询配置确认级别(confirmed/finalized)下的最新 slot，把不高于该 slot 的充值更新为已到账
+func (d *Deposit) confirmDeposits() error {
+	committedSlot, err := d.client.GetCurrentSlot(rpc.Commitment(d.chainConf.Commitment))
+	if err != nil {
+		return err
+	}
+	return d.db.Deposits.UpdateDepositsStatus(committedSlot)
+}
+
+// refetchBlock 重新获取并发获取时失败的区块，ErrSlotSkipped 不再重试
+func (d *Deposit) refetchBlock(slot uint64) ([]node.TransactionDetail, error) {
+	var skippedErr error
+	retryStrategy := &retry.ExponentialStrategy{Min: 500 * time.Millisecond, Max: 5 * time.Second, MaxJitter: 250 * time.Millisecond}
+	txList, err := retry.Do[[]node.TransactionDetail](d.resourceCtx, 3, retryStrategy, func() ([]node.TransactionDetail, error) {
+		txList, err := d.client.GetBlock(slot, scanCommitment)
+		if errors.Is(err, node.ErrSlotSkipped) {
+			skippedErr = err
+			return nil, nil
+		}
+		return txList, err
+	})
+	if skippedErr != nil {
+		return nil, skippedErr
+	}
+	return txList, err
+}
+
+func (d *Deposit) processTransactions(startSyncBlock, endSyncBlock *big.Int) ([]database.Blocks, []database.SkippedSlots, []database.Deposits, []database.Withdraws, []database.Transactions, []database.Transactions, []node.TransactionDetail, uint64, error) {
 	var blockList []database.Blocks
-	var balanceList []database.TokenBalance
 	var depositList []database.Deposits
 	var withdrawList []database.Withdraws
 	var transactionList []database.Transactions
 	var otherTransactionList []database.Transactions
-	for index := startSyncBlock.Uint64(); index < endSyncBlock.Uint64(); index++ {
-		log.Info("handle block success", "block", index)
-		txList, err := d.client.GetBlock(index)
-		if err != nil {
-			log.Error("get block info faill", err)
+	var failedList []node.TransactionDetail
+	var skippedList []database.SkippedSlots
+	// nextSyncBlock 为第一个还没有处理完成的 slot
+	nextSyncBlock := startSyncBlock.Uint64()
+	for _, block := range d.fetcher.fetchBlocks(d.resourceCtx, startSyncBlock.Uint64(), endSyncBlock.Uint64()) {
+		log.Info("handle block success", "block", block.slot)
+		txList, err := block.txList, block.err
+		if err != nil && !errors.Is(err, node.ErrSlotSkipped) {
+			txList, err = d.refetchBlock(block.slot)
+		}
+		if errors.Is(err, node.ErrSlotSkipped) {
+			log.Info("slot skipped", "slot", block.slot)
+			skippedList = append(skippedList, database.SkippedSlots{
+				GUID:      uuid.New(),
+				Slot:      block.slot,
+				Timestamp: uint64(time.Now().Unix()),
+			})
+			nextSyncBlock = block.slot + 1
 			continue
 		}
+		if err != nil {
+			// 暂时性错误重试后仍然失败，停在这个 slot，本轮只入库之前的区块，下一轮从这里重新扫描
+			log.Error("get block info fail, stop at slot", "slot", block.slot, "err", err)
+			break
+		}
+		nextSyncBlock = block.slot + 1
 		if txList == nil {
 			continue
 		}
@@ -196,62 +337,41 @@ func (d *Deposit) processTransactions(startSyncBlock, endSyncBlock *big.Int) ([]
 				continue
 			}
 
-			var TokenBalanceAddress string
-			var TokenTxType uint8
+			// 执行失败的交易不入账，我们自己发出的提现、归集和转冷需要标记失败并释放锁定的余额
+			if txDetail.Err != "" {
+				log.Warn("skip failed transaction", "hash", txDetail.TxHash, "err", txDetail.Err)
+				if fromAddress != nil {
+					failedList = append(failedList, txDetail)
+				}
+				continue
+			}
+
 			// 处理充值
 			if fromAddress == nil && toAddress != nil {
-				depositItem := database.Deposits{
-					GUID:         uuid.New(),
-					BlockHash:    txDetail.BlockHash,
-					BlockNumber:  txDetail.BlockHeight,
-					Hash:         txDetail.TxHash,
-					FromAddress:  txDetail.Source,
-					ToAddress:    txDetail.Destination,
-					TokenAddress: "",
-					Fee:          txDetail.Fee,
-					Amount:       txDetail.Lamports,
-					Status:       0,
-					Timestamp:    uint64(time.Now().Unix()),
-				}
+				depositItem, depositTx := newDepositRecords(txDetail)
 				depositList = append(depositList, depositItem)
-				TokenBalanceAddress = txDetail.Destination
-				TokenTxType = 0
-
-				depositTx := database.Transactions{
-					GUID:         uuid.New(),
-					BlockHash:    txDetail.BlockHash,
-					BlockNumber:  txDetail.BlockHeight,
-					Hash:         txDetail.TxHash,
-					FromAddress:  txDetail.Source,
-					ToAddress:    txDetail.Destination,
-					TokenAddress: txDetail.Destination,
-					Fee:          txDetail.Fee,
-					Amount:       txDetail.Lamports,
-					Status:       1,
-					TxType:       0,
-					Timestamp:    uint64(time.Now().Unix()),
-				}
 				transactionList = append(transactionList, depositTx)
+				// 充值入账在写库时按实际新增的充值记账，见 depositJournal
+				continue
 			}
 
 			// 提现处理
 			if fromAddress != nil && toAddress == nil {
 				withdrawItem := database.Withdraws{
-					BlockHash:    txDetail.BlockHash,
-					BlockNumber:  txDetail.BlockHeight,
-					Hash:         txDetail.TxHash,
-					FromAddress:  txDetail.Source,
-					ToAddress:    txDetail.Destination,
-					TokenAddress: "",
-					Fee:          txDetail.Fee,
-					Amount:       txDetail.Lamports,
-					Status:       0,
-					TxSignHex:    "tx_sign_hex",
-					Timestamp:    uint64(time.Now().Unix()),
+					BlockHash:        txDetail.BlockHash,
+					BlockNumber:      txDetail.BlockHeight,
+					Hash:             txDetail.TxHash,
+					FromAddress:      txDetail.Source,
+					ToAddress:        txDetail.Destination,
+					TokenAddress:     txDetail.TokenAddress,
+					Fee:              txDetail.Fee,
+					Amount:           txDetail.Lamports,
+					Status:           0,
+					TxSignHex:        "tx_sign_hex",
+					TransactionIndex: txDetail.InstructionIndex,
+					Timestamp:        uint64(time.Now().Unix()),
 				}
 				withdrawList = append(withdrawList, withdrawItem)
-				TokenBalanceAddress = txDetail.Source
-				TokenTxType = 1
 			}
 
 			// 处理归集转冷
@@ -266,43 +386,142 @@ func (d *Deposit) processTransactions(startSyncBlock, endSyncBlock *big.Int) ([]
 					log.Error("query cold wallet info fail", "err", err)
 					continue
 				}
-				// 归集：from 地址是用户地址，to 地址是热钱包地址; 转冷：from 热钱包地址，to 地址是冷钱包地址
+				// 归集：from 地址是用户地址，to 地址是热钱包地址; 转冷：from 热钱包地址，to 地址是冷钱包地址；冷转热：from 冷钱包地址，to 地址是热钱包地址
 				if (txDetail.Destination == hotWallet.Address && txDetail.Source != "") || (txDetail.Destination == coldWallet.Address && txDetail.Source == hotWallet.Address) || fromAddress != nil && toAddress == nil { // 2:归集；3:热转冷；4:冷转热
 					var TxType uint8
 					if fromAddress != nil && toAddress == nil {
 						TxType = 1
+					} else if txDetail.Destination == hotWallet.Address && txDetail.Source == coldWallet.Address {
+						TxType = 4
 					} else if txDetail.Destination == hotWallet.Address && txDetail.Source != "" {
 						TxType = 2
 					} else {
 						TxType = 3
 					}
 					transactionItem := database.Transactions{
-						GUID:         uuid.New(),
-						BlockHash:    txDetail.BlockHash,
-						BlockNumber:  txDetail.BlockHeight,
-						Hash:         txDetail.TxHash,
-						FromAddress:  txDetail.Source,
-						ToAddress:    txDetail.Destination,
-						TokenAddress: txDetail.Destination,
-						Fee:          txDetail.Fee,
-						Amount:       txDetail.Lamports,
-						Status:       1,
-						TxType:       TxType,
-						Timestamp:    uint64(time.Now().Unix()),
+						GUID:             uuid.New(),
+						BlockHash:        txDetail.BlockHash,
+						BlockNumber:      txDetail.BlockHeight,
+						Hash:             txDetail.TxHash,
+						FromAddress:      txDetail.Source,
+						ToAddress:        txDetail.Destination,
+						TokenAddress:     txDetail.TokenAddress,
+						Fee:              txDetail.Fee,
+						Amount:           txDetail.Lamports,
+						Status:           1,
+						TxType:           TxType,
+						TransactionIndex: txDetail.InstructionIndex,
+						Timestamp:        uint64(time.Now().Unix()),
 					}
 					otherTransactionList = append(otherTransactionList, transactionItem)
 				}
 			}
+		}
+	}
+	return blockList, skippedList, depositList, withdrawList, transactionList, otherTransactionList, failedList, nextSyncBlock, nil
+}
+
+// newDepositRecords 由一笔充值转账生成充值记录和对应的充值交易记录，扫链和回补共用
+func newDepositRecords(txDetail node.TransactionDetail) (database.Deposits, database.Transactions) {
+	depositItem := database.Deposits{
+		GUID:             uuid.New(),
+		BlockHash:        txDetail.BlockHash,
+		BlockNumber:      txDetail.BlockHeight,
+		Hash:             txDetail.TxHash,
+		FromAddress:      txDetail.Source,
+		ToAddress:        txDetail.Destination,
+		TokenAddress:     txDetail.TokenAddress,
+		Fee:              txDetail.Fee,
+		Amount:           txDetail.Lamports,
+		Status:           0,
+		TransactionIndex: txDetail.InstructionIndex,
+		Timestamp:        uint64(time.Now().Unix()),
+	}
+	depositTx := database.Transactions{
+		GUID:             uuid.New(),
+		BlockHash:        txDetail.BlockHash,
+		BlockNumber:      txDetail.BlockHeight,
+		Hash:             txDetail.TxHash,
+		FromAddress:      txDetail.Source,
+		ToAddress:        txDetail.Destination,
+		TokenAddress:     txDetail.TokenAddress,
+		Fee:              txDetail.Fee,
+		Amount:           txDetail.Lamports,
+		Status:           1,
+		TxType:           0,
+		TransactionIndex: txDetail.InstructionIndex,
+		Timestamp:        uint64(time.Now().Unix()),
+	}
+	return depositItem, depositTx
+}
 
-			balanceItem := database.TokenBalance{
-				Address:      TokenBalanceAddress,
-				TokenAddress: txDetail.Destination,
-				Balance:      txDetail.Lamports,
-				LockBalance:  big.NewInt(0),
-				TxType:       TokenTxType,
+// storedDepositTransactions 只保留实际写入了充值的充值交易，并发写入时被别人先写入的充值不再重复记交易
+func storedDepositTransactions(depositTransactions []database.Transactions, storedDeposits []database.Deposits) []database.Transactions {
+	stored := make(map[string]bool, len(storedDeposits))
+	for _, deposit := range storedDeposits {
+		stored[fmt.Sprintf("%s-%d", deposit.Hash, deposit.TransactionIndex)] = true
+	}
+	var transactions []database.Transactions
+	for _, depositTx := range depositTransactions {
+		if stored[fmt.Sprintf("%s-%d", depositTx.Hash, depositTx.TransactionIndex)] {
+			transactions = append(transactions, depositTx)
+		}
+	}
+	return transactions
+}
+
+// markFailedTransactions 把上链失败的提现标记为 6 并记手续费，归集、热转冷和冷转热交易标记为 4，并释放发送时锁定的余额
+func markFailedTransactions(tx *database.DB, failedList []node.TransactionDetail) error {
+	for _, txDetail := range failedList {
+		withdraw, err := tx.Withdraws.QueryWithdrawsByHash(txDetail.TxHash)
+		if err != nil {
+			return err
+		}
+		if withdraw != nil {
+			if withdraw.Status != 1 {
+				continue
+			}
+			if err := tx.Withdraws.MarkWithdrawFailed(withdraw.GUID, txDetail.Err, txDetail.BlockHeight); err != nil {
+				return err
+			}
+			journalList, err := releaseJournal(tx, withdraw.GUID, txDetail.Source, withdraw.TokenAddress, withdraw.Amount)
+			if err != nil {
+				return err
 			}
-			balanceList = append(balanceList, balanceItem)
+			// 执行失败的交易同样扣了手续费
+			feeList, err := boundJournal(tx, feeJournal(withdraw.GUID, database.JournalWithdrawFee, txDetail.Source, txDetail.Fee))
+			if err != nil {
+				return err
+			}
+			journalList = append(journalList, feeList...)
+			if err := tx.Balances.PostJournal(journalList); err != nil {
+				return err
+			}
+			continue
+		}
+
+		transaction, err := tx.Transactions.QueryTransactionByHash(txDetail.TxHash)
+		if err != nil {
+			return err
+		}
+		if transaction == nil || transaction.Status != 0 || transaction.TxType == 0 {
+			continue
+		}
+		if err := tx.Transactions.MarkTransactionFailed(transaction.GUID, txDetail.Err, txDetail.BlockHeight); err != nil {
+			return err
+		}
+		if transaction.TxType == 4 {
+			if err := tx.ColdHotRequests.UpdateColdHotRequestStatusByHash(transaction.Hash, database.ColdHotFailed); err != nil {
+				return err
+			}
+		}
+		journalList, err := releaseJournal(tx, transaction.GUID, transaction.FromAddress, transaction.TokenAddress, transaction.Amount)
+		if err != nil {
+			return err
+		}
+		if err := tx.Balances.PostJournal(journalList); err != nil {
+			return err
 		}
 	}
-	return blockList, depositList, withdrawList, transactionList, otherTransactionList, balanceList, nil
+	return nil
 }
@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/the-web3/sol-wallet/common/tasks"
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+const (
+	subscribeReconnectInterval = 5 * time.Second
+	subscribeRefreshInterval   = time.Minute
+)
+
+// DepositSubscriber 通过 logsSubscribe 订阅用户地址、热钱包地址以及它们在 tokens 表中各个 token 的关联 token 账户，
+// token 转账只提到 token 账户，不订阅 token 账户收不到 token 充值的推送。收到推送后查询交易，
+// 把充值以确认中(status=0)提前写入 deposits 表，不修改余额。扫链仍然是到账和确认的唯一依据，
+// 扫链处理到对应 slot 时会用扫链结果替换这些记录
+type DepositSubscriber struct {
+	db     *database.DB
+	client node.SolanaChain
+	wsUrl  string
+
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+}
+
+func NewDepositSubscriber(cfg *config.Config, db *database.DB, client node.SolanaChain, shutdown context.CancelCauseFunc) (*DepositSubscriber, error) {
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &DepositSubscriber{
+		db:             db,
+		client:         client,
+		wsUrl:          cfg.Chain.WsUrl,
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in deposit subscriber: %w", err))
+		}},
+	}, nil
+}
+
+func (s *DepositSubscriber) Close() error {
+	s.resourceCancel()
+	if err := s.tasks.Wait(); err != nil {
+		return fmt.Errorf("failed to await deposit subscriber %w", err)
+	}
+	return nil
+}
+
+// Start 连接断开后等待一段时间重新连接并重新订阅
+func (s *DepositSubscriber) Start() error {
+	log.Info("start deposit subscriber......", "url", s.wsUrl)
+	s.tasks.Go(func() error {
+		for {
+			if err := s.subscribe(); err != nil {
+				log.Error("deposit subscription interrupted", "err", err)
+			}
+			select {
+			case <-s.resourceCtx.Done():
+				return nil
+			case <-time.After(subscribeReconnectInterval):
+			}
+		}
+	})
+	return nil
+}
+
+func (s *DepositSubscriber) subscribe() error {
+	ws, err := node.DialWs(s.resourceCtx, s.wsUrl)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	if err := s.subscribeAddresses(ws); err != nil {
+		return err
+	}
+
+	refreshTicker := time.NewTicker(subscribeRefreshInterval)
+	defer refreshTicker.Stop()
+	for {
+		select {
+		case <-s.resourceCtx.Done():
+			return nil
+		case <-ws.Done():
+			return ws.Err()
+		case <-refreshTicker.C:
+			// 订阅期间新生成的地址
+			if err := s.subscribeAddresses(ws); err != nil {
+				return err
+			}
+		case notification := <-ws.Notifications():
+			if err := s.handleNotification(notification); err != nil {
+				log.Error("handle logs notification fail", "signature", notification.Signature, "err", err)
+			}
+		}
+	}
+}
+
+// subscribeAddresses 订阅还没有订阅的用户地址、热钱包地址和它们的关联 token 账户，订阅请求连续发出不等待响应，
+// 订阅失败的地址在下一次刷新时重新订阅
+func (s *DepositSubscriber) subscribeAddresses(ws *node.WsClient) error {
+	addressList, err := s.db.Addresses.QueryAddressListByType(0, 1)
+	if err != nil {
+		log.Error("query address list fail", "err", err)
+		return err
+	}
+	tokenList, err := s.db.Tokens.QueryTokenList()
+	if err != nil {
+		log.Error("query token list fail", "err", err)
+		return err
+	}
+	total, requested := 0, 0
+	for _, address := range addressList {
+		for _, account := range append([]string{address.Address}, associatedTokenAccounts(address.Address, tokenList)...) {
+			total++
+			if ws.Subscribed(account) {
+				continue
+			}
+			if err := ws.LogsSubscribe(account, scanCommitment); err != nil {
+				log.Error("logs subscribe fail", "address", address.Address, "account", account, "err", err)
+				return err
+			}
+			requested++
+		}
+	}
+	log.Info("deposit subscriber subscribed addresses", "addresses", len(addressList), "accounts", total, "requested", requested)
+	return nil
+}
+
+// associatedTokenAccounts 推导 address 在 tokens 表中每个 SPL token 的关联 token 账户，已经关闭的账户也能推导出来
+func associatedTokenAccounts(address string, tokenList []database.Tokens) []string {
+	var accounts []string
+	for _, token := range tokenList {
+		if token.TokenAddress == "" {
+			continue
+		}
+		tokenAccounts, err := node.AssociatedTokenAccounts(address, token.TokenAddress)
+		if err != nil {
+			log.Warn("derive associated token account fail", "address", address, "mint", token.TokenAddress, "err", err)
+			continue
+		}
+		accounts = append(accounts, tokenAccounts...)
+	}
+	return accounts
+}
+
+// handleNotification 扫链已经处理过的 slot、执行失败的交易以及已经入库的交易不再处理
+func (s *DepositSubscriber) handleNotification(notification node.LogsNotification) error {
+	if notification.Err != nil {
+		return nil
+	}
+	syncState, err := s.db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	if err != nil {
+		return err
+	}
+	if syncState != nil && notification.Slot <= syncState.Slot {
+		return nil
+	}
+	exist, err := s.db.Deposits.ExistDepositsByHash(notification.Signature)
+	if err != nil || exist {
+		return err
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: 500 * time.Millisecond, Max: 5 * time.Second, MaxJitter: 250 * time.Millisecond}
+	txList, err := retry.Do[[]node.TransactionDetail](s.resourceCtx, 3, retryStrategy, func() ([]node.TransactionDetail, error) {
+		txList, err := s.client.GetTransaction(notification.Signature, rpc.CommitmentConfirmed)
+		if err != nil {
+			return nil, err
+		}
+		if txList == nil {
+			return nil, errors.New("transaction not found")
+		}
+		return txList, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var depositList []database.Deposits
+	for _, txDetail := range txList {
+		if txDetail.Err != "" {
+			continue
+		}
+		fromAddress, err := s.db.Addresses.QueryAddressesByToAddress(txDetail.Source)
+		if err != nil {
+			return err
+		}
+		toAddress, err := s.db.Addresses.QueryAddressesByToAddress(txDetail.Destination)
+		if err != nil {
+			return err
+		}
+		if fromAddress != nil || toAddress == nil {
+			continue
+		}
+		depositList = append(depositList, database.Deposits{
+			GUID:             uuid.New(),
+			BlockNumber:      txDetail.BlockHeight,
+			Hash:             txDetail.TxHash,
+			FromAddress:      txDetail.Source,
+			ToAddress:        txDetail.Destination,
+			TokenAddress:     txDetail.TokenAddress,
+			Fee:              txDetail.Fee,
+			Amount:           txDetail.Lamports,
+			Status:           0,
+			TransactionIndex: txDetail.InstructionIndex,
+			FromSubscription: true,
+			Timestamp:        uint64(time.Now().Unix()),
+		})
+	}
+	if len(depositList) == 0 {
+		return nil
+	}
+	log.Info("store subscribed deposits", "signature", notification.Signature, "slot", notification.Slot, "total", len(depositList))
+	_, err = s.db.Deposits.StoreDeposits(depositList)
+	return err
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestDepositSubscriber_PendingDepositReplacedByScan(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	chain.SetFinalizedSlot(9)
+
+	server := node.NewFakeWsServer()
+	defer server.Close()
+	cfg := newTestConfig()
+	cfg.Chain.WsUrl = server.URL()
+
+	subscriber, err := NewDepositSubscriber(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, subscriber.Start())
+	require.Eventually(t, func() bool {
+		return server.Subscribed(testUserAddress) && server.Subscribed(testHotAddress)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, server.Subscribed(testColdAddress))
+
+	// 推送之后充值以确认中写入，但不计入余额
+	require.NoError(t, server.NotifyLogs(testUserAddress, 10, "deposit-signature-1", nil))
+	require.Eventually(t, func() bool {
+		deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+		return err == nil && len(deposits) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, subscriber.Close())
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.True(t, deposits[0].FromSubscription)
+	require.Equal(t, uint8(0), deposits[0].Status)
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, balance.Balance.Sign())
+
+	// 扫链处理到 slot 10 后由扫链结果替换，只入账一次
+	deposit, err := NewDeposit(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.False(t, deposits[0].FromSubscription)
+	balance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1_000_000), balance.Balance)
+}
+
+func TestDepositSubscriber_TokenAccountSubscribed(t *testing.T) {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:  usdcMint,
+		Unit:          6,
+		TokenName:     "USDC",
+		CollectAmount: big.NewInt(1_000_000),
+	}))
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:       "spl-deposit-signature-1",
+		Source:       testExternalAddress,
+		Destination:  testUserAddress,
+		TokenAddress: usdcMint,
+		Lamports:     big.NewInt(2_500_000),
+		Type:         "transfer",
+	})
+	chain.SetFinalizedSlot(9)
+
+	server := node.NewFakeWsServer()
+	defer server.Close()
+	cfg := newTestConfig()
+	cfg.Chain.WsUrl = server.URL()
+
+	// token 转账只提到 token 账户，用户还没有开 token 账户时也要订阅推导出来的关联 token 账户
+	tokenAccount := node.FakeTokenAccount(testUserAddress, usdcMint)
+	subscriber, err := NewDepositSubscriber(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, subscriber.Start())
+	require.Eventually(t, func() bool {
+		return server.Subscribed(testUserAddress) && server.Subscribed(tokenAccount)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, server.NotifyLogs(tokenAccount, 10, "spl-deposit-signature-1", nil))
+	require.Eventually(t, func() bool {
+		deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+		return err == nil && len(deposits) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, subscriber.Close())
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Equal(t, testUserAddress, deposits[0].ToAddress)
+	require.Equal(t, usdcMint, deposits[0].TokenAddress)
+	require.Equal(t, uint8(0), deposits[0].Status)
+}
@@ -0,0 +1,315 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestDeposit_ScanConfirmAndRollback(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10)
+	chain.AddBlock(11, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	chain.AddBlock(12)
+	chain.SetFinalizedSlot(10)
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+
+	// slot 11 已 confirmed 但还没有 finalized，充值以确认中入库并先记入余额
+	require.NoError(t, deposit.processBatch())
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, "deposit-signature-1", deposits[0].Hash)
+	require.Equal(t, uint8(0), deposits[0].Status)
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1_000_000), balance.Balance)
+
+	// 重复扫描不会重复入账
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, uint8(0), deposits[0].Status)
+
+	// slot 11、12 分叉成不包含充值的块，回滚到 slot 10，充值被删除并扣回余额
+	chain.AddBlock(11)
+	chain.AddBlock(12)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+	balance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, balance.Balance.Sign())
+
+	// slot 13 finalized 之后充值更新为已到账
+	chain.AddBlock(13, node.TransactionDetail{
+		TxHash:      "deposit-signature-2",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(2_000_000),
+		Type:        "transfer",
+	})
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, uint8(1), deposits[0].Status)
+
+	// finalized 的区块不会分叉，节点返回的区块和保存的不一致时不回滚已经到账的充值
+	chain.AddBlock(13)
+	require.ErrorIs(t, deposit.processBatch(), errFinalizedReorg)
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	balance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2_000_000), balance.Balance)
+}
+
+func TestDeposit_ReorgBeyondOldestStoredBlock(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	// 最早保存的区块也已经分叉，找不到分叉点时报错，不清空账本
+	chain.AddBlock(10)
+	chain.SetFinalizedSlot(9)
+	require.ErrorIs(t, deposit.processBatch(), errNoForkBlock)
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1_000_000), balance.Balance)
+}
+
+func TestDeposit_SplTokenDepositPerMint(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:       "spl-deposit-signature-1",
+		Source:       testExternalAddress,
+		Destination:  testUserAddress,
+		TokenAddress: usdcMint,
+		Lamports:     big.NewInt(2_500_000),
+		Type:         "transfer",
+	})
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, usdcMint, deposits[0].TokenAddress)
+
+	tokenBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, usdcMint)
+	require.NoError(t, err)
+	require.NotNil(t, tokenBalance)
+	require.Equal(t, big.NewInt(2_500_000), tokenBalance.Balance)
+
+	solBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, solBalance.Balance.Sign())
+}
+
+func TestDeposit_SkipFailedTransaction(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "failed-deposit-signature",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+		Err:         `{"InstructionError":[0,{"Custom":1}]}`,
+	})
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, balance.Balance.Sign())
+}
+
+// flakyChain 在 down 为 true 时获取 failSlot 返回暂时性错误
+type flakyChain struct {
+	*node.FakeChain
+	failSlot uint64
+	down     atomic.Bool
+}
+
+func (c *flakyChain) GetBlock(slot uint64, commitment rpc.Commitment) ([]node.TransactionDetail, error) {
+	if slot == c.failSlot && c.down.Load() {
+		return nil, errors.New("429 Too Many Requests")
+	}
+	return c.FakeChain.GetBlock(slot, commitment)
+}
+
+func TestDeposit_SkippedSlotAndTransientError(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := &flakyChain{FakeChain: node.NewFakeChain(), failSlot: 12}
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	// slot 11 没有出块
+	chain.AddBlock(12, node.TransactionDetail{
+		TxHash:      "deposit-signature-2",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(2_000_000),
+		Type:        "transfer",
+	})
+	chain.SkipSlot(11)
+	chain.down.Store(true)
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+
+	// slot 12 重试后仍然失败，游标停在 slot 12 之前，跳过的 slot 11 被记录
+	require.NoError(t, deposit.processBatch())
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	latest, err := db.Blocks.LatestBlocks()
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10), latest.Number)
+	skipped, err := db.SkippedSlots.QuerySkippedSlots(0, 100)
+	require.NoError(t, err)
+	require.Len(t, skipped, 1)
+	require.Equal(t, uint64(11), skipped[0].Slot)
+	syncState, err := db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), syncState.Slot)
+
+	// 节点恢复后从 slot 11 继续扫描，slot 12 的充值不会丢失
+	chain.down.Store(false)
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 2)
+	skipped, err = db.SkippedSlots.QuerySkippedSlots(0, 100)
+	require.NoError(t, err)
+	require.Len(t, skipped, 1)
+}
+
+func TestDeposit_SyncStateAdvancesOverEmptySlots(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	for slot := uint64(10); slot <= 12; slot++ {
+		chain.AddBlock(slot)
+	}
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+
+	// 没有相关交易的 slot 不会写入 blocks 表，但游标仍然推进，不会重复扫描
+	require.NoError(t, deposit.processBatch())
+	latest, err := db.Blocks.LatestBlocks()
+	require.NoError(t, err)
+	require.Nil(t, latest)
+	syncState, err := db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), syncState.Slot)
+
+	chain.AddBlock(13, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	require.NoError(t, deposit.processBatch())
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	syncState, err = db.SyncState.QuerySyncState(database.DepositSyncWorker)
+	require.NoError(t, err)
+	require.Equal(t, uint64(13), syncState.Slot)
+}
+
+func TestDeposit_RescanDoesNotDoubleCredit(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	// 同一笔交易里给同一个地址转了两笔相同金额，按指令序号区分
+	chain := node.NewFakeChain()
+	var transfers []node.TransactionDetail
+	for _, index := range []uint64{0, 1} {
+		transfers = append(transfers, node.TransactionDetail{
+			TxHash:           "deposit-signature-1",
+			Source:           testExternalAddress,
+			Destination:      testUserAddress,
+			Lamports:         big.NewInt(1_000_000),
+			Type:             "transfer",
+			InstructionIndex: index,
+		})
+	}
+	chain.AddBlock(10, transfers...)
+
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	// 游标回退后重新扫描同一段 slot，充值和余额都不变
+	require.NoError(t, db.SyncState.UpdateSyncState(database.DepositSyncWorker, 9))
+	require.NoError(t, deposit.processBatch())
+
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 2)
+	balance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testUserAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2_000_000), balance.Balance)
+}
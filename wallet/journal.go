@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/the-web3/sol-wallet/database"
+)
+
+// depositJournal 充值入账：付款方转入收款地址的可用余额
+func depositJournal(deposits []database.Deposits) []database.BalanceJournal {
+	var journalList []database.BalanceJournal
+	for _, deposit := range deposits {
+		journalList = append(journalList, database.NewJournalEntries(deposit.GUID, database.JournalDeposit, deposit.TokenAddress, deposit.Amount,
+			database.JournalAccount{Address: deposit.FromAddress, Account: database.JournalAccountExternal},
+			database.JournalAccount{Address: deposit.ToAddress, Account: database.JournalAccountBalance})...)
+	}
+	return journalList
+}
+
+// lockJournal 发送交易时把 address 的可用余额转入锁定余额
+func lockJournal(txGuid uuid.UUID, entryType, address, tokenAddress string, amount *big.Int) []database.BalanceJournal {
+	return database.NewJournalEntries(txGuid, entryType, tokenAddress, amount,
+		database.JournalAccount{Address: address, Account: database.JournalAccountBalance},
+		database.JournalAccount{Address: address, Account: database.JournalAccountLock})
+}
+
+// settleJournal 交易上链后从 fromAddress 转出 amount 到 to，优先扣发送时锁定的余额，不足的部分扣可用余额
+func settleJournal(tx *database.DB, txGuid uuid.UUID, entryType, fromAddress, tokenAddress string, amount *big.Int, to database.JournalAccount) ([]database.BalanceJournal, error) {
+	locked, err := tx.Balances.QueryTxLockBalance(txGuid, fromAddress, tokenAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+	if locked.Cmp(amount) > 0 {
+		locked = new(big.Int).Set(amount)
+	}
+	var journalList []database.BalanceJournal
+	if locked.Sign() > 0 {
+		journalList = append(journalList, database.NewJournalEntries(txGuid, entryType, tokenAddress, locked,
+			database.JournalAccount{Address: fromAddress, Account: database.JournalAccountLock}, to)...)
+	}
+	if rest := new(big.Int).Sub(amount, locked); rest.Sign() > 0 {
+		journalList = append(journalList, database.NewJournalEntries(txGuid, entryType, tokenAddress, rest,
+			database.JournalAccount{Address: fromAddress, Account: database.JournalAccountBalance}, to)...)
+	}
+	return journalList, nil
+}
+
+// releaseJournal 交易上链失败后把还没有结算的锁定余额退回可用余额
+func releaseJournal(tx *database.DB, txGuid uuid.UUID, address, tokenAddress string, amount *big.Int) ([]database.BalanceJournal, error) {
+	locked, err := tx.Balances.QueryTxLockBalance(txGuid, address, tokenAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+	if locked.Sign() == 0 {
+		return nil, nil
+	}
+	return database.NewJournalEntries(txGuid, database.JournalRelease, tokenAddress, locked,
+		database.JournalAccount{Address: address, Account: database.JournalAccountLock},
+		database.JournalAccount{Address: address, Account: database.JournalAccountBalance}), nil
+}
+
+// feeJournal 交易上链后 payer 支付的 SOL 手续费
+func feeJournal(txGuid uuid.UUID, entryType, payer string, fee *big.Int) []database.BalanceJournal {
+	if fee == nil || fee.Sign() <= 0 {
+		return nil
+	}
+	return database.NewJournalEntries(txGuid, entryType, "", fee,
+		database.JournalAccount{Address: payer, Account: database.JournalAccountBalance},
+		database.JournalAccount{Address: payer, Account: database.JournalAccountExternal})
+}
+
+// outgoingJournal 扫到我们发出的提现、归集、热转冷和冷转热上链后，结算发送时锁定的余额并记提现的手续费。
+// 手续费没有在发送时锁定，热钱包余额不足时不足的部分按 boundJournal 记到 external，不影响扫链入账
+func outgoingJournal(tx *database.DB, withdraws []database.Withdraws, transactions []database.Transactions) ([]database.BalanceJournal, error) {
+	var journalList, feeList []database.BalanceJournal
+	feePosted := make(map[uuid.UUID]bool)
+	for _, item := range withdraws {
+		withdraw, err := tx.Withdraws.QueryWithdrawsByHash(item.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if withdraw == nil {
+			log.Warn("withdraw not found, skip balance settle", "hash", item.Hash)
+			continue
+		}
+		entries, err := settleJournal(tx, withdraw.GUID, database.JournalWithdrawSettle, item.FromAddress, withdraw.TokenAddress, withdraw.Amount,
+			database.JournalAccount{Address: withdraw.ToAddress, Account: database.JournalAccountExternal})
+		if err != nil {
+			return nil, err
+		}
+		journalList = append(journalList, entries...)
+		// 同一笔交易的多条指令只记一次手续费
+		if !feePosted[withdraw.GUID] {
+			feePosted[withdraw.GUID] = true
+			feeList = append(feeList, feeJournal(withdraw.GUID, database.JournalWithdrawFee, item.FromAddress, item.Fee)...)
+		}
+	}
+	feeList, err := boundJournal(tx, feeList)
+	if err != nil {
+		return nil, err
+	}
+	journalList = append(journalList, feeList...)
+
+	for _, item := range transactions {
+		var entryType string
+		switch item.TxType {
+		case 2:
+			entryType = database.JournalCollectionSettle
+		case 3:
+			entryType = database.JournalColdSettle
+		case 4:
+			entryType = database.JournalColdHotSettle
+		default:
+			continue
+		}
+		transaction, err := tx.Transactions.QueryTransactionByHash(item.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if transaction == nil {
+			log.Warn("transaction not found, skip balance settle", "hash", item.Hash)
+			continue
+		}
+		entries, err := settleJournal(tx, transaction.GUID, entryType, transaction.FromAddress, transaction.TokenAddress, transaction.Amount,
+			database.JournalAccount{Address: transaction.ToAddress, Account: database.JournalAccountBalance})
+		if err != nil {
+			return nil, err
+		}
+		journalList = append(journalList, entries...)
+		// token 归集关闭了用户 token 账户时，租金随归集上链退回热钱包
+		if transaction.RentRefund != nil && transaction.RentRefund.Sign() > 0 {
+			journalList = append(journalList, database.NewJournalEntries(transaction.GUID, database.JournalCollectionRent, "", transaction.RentRefund,
+				database.JournalAccount{Address: transaction.ToAddress, Account: database.JournalAccountExternal},
+				database.JournalAccount{Address: transaction.ToAddress, Account: database.JournalAccountBalance})...)
+		}
+	}
+	return journalList, nil
+}
+
+// reverseJournal 冲正 txGuid 下 entryType 还没有冲正的流水，分叉回滚时使用，已经冲正的流水不会重复冲正
+func reverseJournal(tx *database.DB, txGuid uuid.UUID, entryType string) ([]database.BalanceJournal, error) {
+	sumList, err := tx.BalanceJournal.SumTxBalanceJournal(txGuid, entryType)
+	if err != nil {
+		return nil, err
+	}
+	now := uint64(time.Now().Unix())
+	var journalList []database.BalanceJournal
+	for _, sum := range sumList {
+		if sum.Amount.Sign() == 0 {
+			continue
+		}
+		direction := database.JournalDebit
+		if sum.Amount.Sign() < 0 {
+			direction = database.JournalCredit
+		}
+		journalList = append(journalList, database.BalanceJournal{
+			GUID:         uuid.New(),
+			TxGUID:       txGuid,
+			EntryType:    database.JournalRollback(entryType),
+			Address:      sum.Address,
+			TokenAddress: sum.TokenAddress,
+			Account:      sum.Account,
+			Direction:    direction,
+			Amount:       new(big.Int).Abs(sum.Amount),
+			Timestamp:    now,
+		})
+	}
+	return journalList, nil
+}
+
+// boundJournal 借记超过当前余额的部分改为从 external 记账并告警，用于记账时余额可能已经被转走的场景，例如分叉回滚时
+// 被冲正的充值已经被归集或者提现，记账不会因为余额不足失败，由对账发现链上和钱包余额的差异
+func boundJournal(tx *database.DB, journalList []database.BalanceJournal) ([]database.BalanceJournal, error) {
+	balances := make(map[string]*big.Int)
+	var boundList []database.BalanceJournal
+	for _, journal := range journalList {
+		if journal.Account == database.JournalAccountExternal {
+			boundList = append(boundList, journal)
+			continue
+		}
+		key := journal.Address + "/" + journal.TokenAddress + "/" + journal.Account
+		balance, ok := balances[key]
+		if !ok {
+			balanceEntry, err := tx.Balances.QueryWalletBalanceByTokenAndAddress(journal.Address, journal.TokenAddress)
+			if err != nil {
+				return nil, err
+			}
+			balance = big.NewInt(0)
+			if balanceEntry != nil {
+				balance.Set(balanceEntry.Balance)
+				if journal.Account == database.JournalAccountLock {
+					balance.Set(balanceEntry.LockBalance)
+				}
+			}
+			balances[key] = balance
+		}
+		if journal.Direction == database.JournalCredit {
+			balance.Add(balance, journal.Amount)
+			boundList = append(boundList, journal)
+			continue
+		}
+		if balance.Cmp(journal.Amount) >= 0 {
+			balance.Sub(balance, journal.Amount)
+			boundList = append(boundList, journal)
+			continue
+		}
+
+		shortfall := new(big.Int).Sub(journal.Amount, balance)
+		log.Error("journal exceeds balance, post the shortfall to external", "txGuid", journal.TxGUID, "entryType", journal.EntryType,
+			"address", journal.Address, "tokenAddress", journal.TokenAddress, "account", journal.Account, "balance", balance, "amount", journal.Amount)
+		if balance.Sign() > 0 {
+			partial := journal
+			partial.Amount = new(big.Int).Set(balance)
+			boundList = append(boundList, partial)
+		}
+		external := journal
+		external.GUID = uuid.New()
+		external.Account = database.JournalAccountExternal
+		external.Amount = shortfall
+		boundList = append(boundList, external)
+		balance.SetInt64(0)
+	}
+	return boundList, nil
+}
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestJournal_WithdrawSettleAndRebuild(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testHotAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(400_000)))
+	withdraw, err := NewWithdraw(newTestConfig(), db, chain, &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+
+	chain.AddBlock(11, node.TransactionDetail{
+		TxHash:      "fake-signature-1",
+		Source:      testHotAddress,
+		Destination: testExternalAddress,
+		Lamports:    big.NewInt(400_000),
+		Type:        "transfer",
+		Fee:         big.NewInt(5000),
+	})
+	require.NoError(t, deposit.processBatch())
+
+	// 热钱包支付的手续费在提现上链时一起扣除
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(595_000), hotBalance.Balance)
+	require.Equal(t, 0, hotBalance.LockBalance.Sign())
+
+	// 提现的锁定、结算和手续费各记一对借贷分录
+	withdrawRecord, err := db.Withdraws.QueryWithdrawsByHash("fake-signature-1")
+	require.NoError(t, err)
+	journalList, err := db.BalanceJournal.QueryBalanceJournal(withdrawRecord.GUID)
+	require.NoError(t, err)
+	require.Len(t, journalList, 6)
+
+	// 由流水重建的余额和逐笔记账的结果一致
+	require.NoError(t, db.Balances.RebuildBalances())
+	hotBalance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(595_000), hotBalance.Balance)
+	require.Equal(t, 0, hotBalance.LockBalance.Sign())
+}
+
+func TestJournal_RejectNegativeBalance(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 1_000_000)
+
+	// 借记超过余额时整批流水不记账，余额不会被截断成 0
+	err := db.Transaction(func(tx *database.DB) error {
+		return tx.Balances.PostJournal(lockJournal(uuid.New(), database.JournalWithdrawLock, testHotAddress, "", big.NewInt(2_000_000)))
+	})
+	require.ErrorIs(t, err, database.ErrNegativeBalance)
+	requireBalance(t, db, testHotAddress, 1_000_000, 0)
+
+	// 同一批提现依次占用热钱包余额，超出余额的提现留到下一轮
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(600_000)))
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(600_000)))
+	withdraw, err := NewWithdraw(newTestConfig(), db, node.NewFakeChain(), &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+	requireBalance(t, db, testHotAddress, 400_000, 600_000)
+	unSendList, err := db.Withdraws.UnSendWithdrawsList()
+	require.NoError(t, err)
+	require.Len(t, unSendList, 1)
+}
@@ -1,15 +1,59 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"strconv"
 
 	"github.com/blocto/solana-go-sdk/client"
 	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
 )
 
+const (
+	slotSkippedErrCode                = -32007
+	longTermStorageSlotSkippedErrCode = -32009
+
+	// maxSignatureStatusesBatch getSignatureStatuses 单次最多查询的签名数量
+	maxSignatureStatusesBatch = 256
+)
+
+var (
+	// ErrSlotSkipped 节点明确返回该 slot 没有出块（被跳过）
+	ErrSlotSkipped = errors.New("slot was skipped")
+	// ErrSlotUnavailable 节点的长期存储里没有该 slot，不代表 slot 被跳过，换一个节点可能查得到
+	ErrSlotUnavailable = errors.New("slot is not available on this node")
+	// ErrTransactionRejected 节点拒绝了广播的交易(预执行失败、签名或参数错误)，交易没有进入网络，重发同一笔交易不会成功
+	ErrTransactionRejected = errors.New("transaction rejected")
+)
+
+// SolanaChain 钱包各个任务依赖的链上接口，SolanaClient 为基于 RPC 的实现，FakeChain 为测试用的内存实现
+type SolanaChain interface {
+	GetCurrentSlot(commitment rpc.Commitment) (uint64, error)
+	GetBlock(slot uint64, commitment rpc.Commitment) ([]TransactionDetail, error)
+	GetTransaction(signature string, commitment rpc.Commitment) ([]TransactionDetail, error)
+	GetSignaturesForAddress(address string, before string, limit int) ([]SignatureInfo, error)
+	GetBlockHeader(slot uint64, commitment rpc.Commitment) (*BlockHeader, error)
+	GetBalance(address string) (string, error)
+	GetLamports(address string) (uint64, error)
+	GetMultipleLamports(addresses []string) ([]uint64, error)
+	GetTokenBalances(owner string) (map[string]*big.Int, error)
+	GetMultipleTokenAmounts(accounts []string) ([]*TokenAmount, error)
+	GetTokenAccounts(owner string) ([]string, error)
+	GetRecentBlockHash() (*RecentBlockhash, error)
+	GetLatestBlockHeight(commitment rpc.Commitment) (uint64, error)
+	GetSignatureStatuses(signatures []string) ([]*SignatureStatus, error)
+	SendRawTransaction(rawTx string) (string, error)
+	GetNonce(nonceAccount string) (string, error)
+	GetMinRent(dataLen uint64) (string, error)
+}
+
+var _ SolanaChain = (*SolanaClient)(nil)
+
 type SolanaClient struct {
 	RpcClient rpc.RpcClient
 	Client    *client.Client
@@ -24,91 +68,169 @@ func NewSolanaClient(url string) (*SolanaClient, error) {
 	}, nil
 }
 
-// GetRecentBlockHash 获取最新的区块链
-func (sol *SolanaClient) GetRecentBlockHash() (string, error) {
-	return "", nil
+// GetRecentBlockHash 获取 confirmed 级别最新的 blockhash 以及它失效前最后的区块高度
+func (sol *SolanaClient) GetRecentBlockHash() (*RecentBlockhash, error) {
+	res, err := sol.RpcClient.GetLatestBlockhashWithConfig(context.Background(), rpc.GetLatestBlockhashConfig{
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	return &RecentBlockhash{
+		Blockhash:            res.Result.Value.Blockhash,
+		LastValidBlockHeight: res.Result.Value.LatestValidBlockHeight,
+	}, nil
 }
 
-// GetCurrentSlot 获取最新的区块链
-func (sol *SolanaClient) GetCurrentSlot() (uint64, error) {
-	res, err := sol.RpcClient.GetSlot(context.Background())
+// GetCurrentSlot 获取指定确认级别下最新的 slot
+func (sol *SolanaClient) GetCurrentSlot(commitment rpc.Commitment) (uint64, error) {
+	res, err := sol.RpcClient.GetSlotWithConfig(context.Background(), rpc.GetSlotConfig{
+		Commitment: commitment,
+	})
 	if err != nil {
 		return 0, err
 	}
+	if res.Error != nil {
+		return 0, rpcError(res.Error)
+	}
 	return res.Result, nil
 }
 
-// GetLatestBlockHeight 获取最新的区块链
-func (sol *SolanaClient) GetLatestBlockHeight() (uint64, error) {
-	res, err := sol.RpcClient.GetBlockHeight(context.Background())
+// GetLatestBlockHeight 获取指定确认级别下最新的区块高度，blockhash 在区块高度超过 lastValidBlockHeight 后过期
+func (sol *SolanaClient) GetLatestBlockHeight(commitment rpc.Commitment) (uint64, error) {
+	res, err := sol.RpcClient.GetBlockHeightWithConfig(context.Background(), rpc.GetBlockHeightConfig{
+		Commitment: commitment,
+	})
 	if err != nil {
 		return 0, err
 	}
+	if res.Error != nil {
+		return 0, rpcError(res.Error)
+	}
 	return res.Result, nil
 }
 
-// GetBlock 根据区块号获取里面的交易
-func (sol *SolanaClient) GetBlock(slot uint64) ([]TransactionDetail, error) {
+// GetSignatureStatuses 批量查询交易签名的状态，链上查不到的签名对应位置为 nil。
+// 开启 searchTransactionHistory，避免已上链较久的交易被当成未上链
+func (sol *SolanaClient) GetSignatureStatuses(signatures []string) ([]*SignatureStatus, error) {
+	statuses := make([]*SignatureStatus, 0, len(signatures))
+	for start := 0; start < len(signatures); start += maxSignatureStatusesBatch {
+		end := start + maxSignatureStatusesBatch
+		if end > len(signatures) {
+			end = len(signatures)
+		}
+		res, err := sol.RpcClient.GetSignatureStatusesWithConfig(context.Background(), signatures[start:end], rpc.GetSignatureStatusesConfig{
+			SearchTransactionHistory: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, rpcError(res.Error)
+		}
+		batch := make([]*SignatureStatus, end-start)
+		for i, status := range res.Result.Value {
+			if i >= len(batch) || status == nil {
+				continue
+			}
+			signatureStatus := &SignatureStatus{Slot: status.Slot, Err: status.Err}
+			if status.ConfirmationStatus != nil {
+				signatureStatus.ConfirmationStatus = *status.ConfirmationStatus
+			}
+			batch[i] = signatureStatus
+		}
+		statuses = append(statuses, batch...)
+	}
+	return statuses, nil
+}
+
+// GetBlock 根据区块号获取里面的交易，commitment 不支持 processed
+func (sol *SolanaClient) GetBlock(slot uint64, commitment rpc.Commitment) ([]TransactionDetail, error) {
 	rewards := false
 	var MaxSupportedTransactionVersion uint8 = 0
-	res, err := sol.RpcClient.GetBlockWithConfig(context.Background(), slot, rpc.GetBlockConfig{
+	res, err := callUseNumber[rpc.JsonRpcResponse[*rpc.GetBlock]](&sol.RpcClient, context.Background(), "getBlock", slot, rpc.GetBlockConfig{
 		Encoding:                       rpc.GetBlockConfigEncodingJsonParsed,
 		TransactionDetails:             rpc.GetBlockConfigTransactionDetailsFull,
 		Rewards:                        &rewards,
+		Commitment:                     commitment,
 		MaxSupportedTransactionVersion: &MaxSupportedTransactionVersion,
 	})
 	if err != nil {
 		return nil, err
 	}
-	var txDetailList []TransactionDetail
-	if res.Result.Transactions != nil {
-		for _, value := range res.Result.Transactions {
-			if convertedMap, ok := value.Transaction.(map[string]interface{}); ok {
-				message := convertedMap["message"].(map[string]interface{})
-				instructions := message["instructions"].([]interface{})
-				for _, instruction := range instructions {
-					instructionItem := instruction.(map[string]interface{})
-					if instructionItem["program"] == "spl-token" || instructionItem["program"] == "system" { // token transfer
-						txType := instructionItem["parsed"].(map[string]interface{})["type"]
-						if txType != "transfer" {
-							continue
-						} else {
-							var fromAddres, toAddress string
-							amount := new(big.Int)
-							information := instructionItem["parsed"].(map[string]interface{})["info"].(map[string]interface{})
-							fromAddres = information["source"].(string)
-							toAddress = information["destination"].(string)
-							if instructionItem["program"] == "spl-token" {
-								amountStr := information["amount"].(string)
-								amount.SetString(amountStr, 10)
-							} else {
-								amountStr := fmt.Sprintf("%.0f", information["lamports"].(float64))
-								amount.SetString(amountStr, 10)
-							}
-							fee := value.Meta.Fee
-							signatures := convertedMap["signatures"].([]interface{})
-							blockHeight := res.Result.ParentSlot + 1
-							txDetail := TransactionDetail{
-								PreviousBlockhash: res.Result.PreviousBlockhash,
-								BlockHash:         res.Result.Blockhash,
-								BlockHeight:       big.NewInt(int64(blockHeight)),
-								TxHash:            signatures[0].(string),
-								Destination:       toAddress,
-								Source:            fromAddres,
-								Lamports:          amount,
-								Type:              txType.(string),
-								Fee:               big.NewInt(int64(fee)),
-							}
-							txDetailList = append(txDetailList, txDetail)
-						}
-					} else {
-						continue
-					}
-				}
-			}
-		}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
 	}
-	return txDetailList, err
+	return parseBlock(slot, res.Result), nil
+}
+
+// GetTransaction 根据签名获取交易中的转账，交易还没有达到 commitment 级别时返回 nil，
+// 返回的转账不包含区块哈希
+func (sol *SolanaClient) GetTransaction(signature string, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	var MaxSupportedTransactionVersion uint8 = 0
+	res, err := callUseNumber[rpc.JsonRpcResponse[*rpc.GetTransaction]](&sol.RpcClient, context.Background(), "getTransaction", signature, rpc.GetTransactionConfig{
+		Encoding:                       rpc.TransactionEncodingJsonParsed,
+		Commitment:                     commitment,
+		MaxSupportedTransactionVersion: &MaxSupportedTransactionVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	if res.Result == nil {
+		return nil, nil
+	}
+	return parseTransaction(res.Result.Slot, res.Result.Transaction, res.Result.Meta), nil
+}
+
+// GetSignaturesForAddress 按时间倒序返回 finalized 级别下提到 address 的交易签名，before 不为空时从该签名之前开始
+func (sol *SolanaClient) GetSignaturesForAddress(address string, before string, limit int) ([]SignatureInfo, error) {
+	res, err := sol.RpcClient.GetSignaturesForAddressWithConfig(context.Background(), address, rpc.GetSignaturesForAddressConfig{
+		Limit:      limit,
+		Before:     before,
+		Commitment: rpc.CommitmentFinalized,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	signatures := make([]SignatureInfo, 0, len(res.Result))
+	for _, item := range res.Result {
+		signatures = append(signatures, SignatureInfo{Signature: item.Signature, Slot: item.Slot, Err: item.Err})
+	}
+	return signatures, nil
+}
+
+// GetBlockHeader 根据 slot 获取区块哈希和父区块哈希，不拉取交易
+func (sol *SolanaClient) GetBlockHeader(slot uint64, commitment rpc.Commitment) (*BlockHeader, error) {
+	rewards := false
+	res, err := sol.RpcClient.GetBlockWithConfig(context.Background(), slot, rpc.GetBlockConfig{
+		TransactionDetails: rpc.GetBlockConfigTransactionDetailsNone,
+		Rewards:            &rewards,
+		Commitment:         commitment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	if res.Result == nil {
+		return nil, fmt.Errorf("block %d not found", slot)
+	}
+	return &BlockHeader{
+		Slot:              slot,
+		ParentSlot:        res.Result.ParentSlot,
+		BlockHash:         res.Result.Blockhash,
+		PreviousBlockhash: res.Result.PreviousBlockhash,
+	}, nil
 }
 
 func (sol *SolanaClient) GetBalance(address string) (string, error) {
@@ -130,6 +252,134 @@ func (sol *SolanaClient) GetBalance(address string) (string, error) {
 	return solBalance.String(), nil
 }
 
+// GetLamports 查询地址 finalized 状态下的 SOL 余额，单位 lamports
+func (sol *SolanaClient) GetLamports(address string) (uint64, error) {
+	res, err := sol.RpcClient.GetBalanceWithConfig(context.Background(), address, rpc.GetBalanceConfig{
+		Commitment: rpc.CommitmentFinalized,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if res.Error != nil {
+		return 0, rpcError(res.Error)
+	}
+	return res.Result.Value, nil
+}
+
+// GetMultipleLamports 用一次 getMultipleAccounts 查询多个地址 finalized 状态下的 lamports，顺序和 addresses 一致，
+// 不存在的账户为 0。一次最多查询 MaxMultipleAccounts 个地址
+func (sol *SolanaClient) GetMultipleLamports(addresses []string) ([]uint64, error) {
+	if len(addresses) > MaxMultipleAccounts {
+		return nil, fmt.Errorf("too many accounts %d, max %d", len(addresses), MaxMultipleAccounts)
+	}
+	res, err := sol.RpcClient.GetMultipleAccountsWithConfig(context.Background(), addresses, rpc.GetMultipleAccountsConfig{
+		Commitment: rpc.CommitmentFinalized,
+		Encoding:   rpc.AccountEncodingBase64,
+		DataSlice:  &rpc.DataSlice{Offset: 0, Length: 0},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	if len(res.Result.Value) != len(addresses) {
+		return nil, fmt.Errorf("get multiple accounts returned %d accounts, want %d", len(res.Result.Value), len(addresses))
+	}
+	lamports := make([]uint64, len(addresses))
+	for i, account := range res.Result.Value {
+		lamports[i] = account.Lamports
+	}
+	return lamports, nil
+}
+
+// GetTokenBalances 查询 owner 在 Token 和 Token-2022 程序下所有 token 账户 finalized 状态下的余额，按 mint 汇总
+func (sol *SolanaClient) GetTokenBalances(owner string) (map[string]*big.Int, error) {
+	accounts, err := sol.tokenAccountsByOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]*big.Int)
+	for _, account := range accounts {
+		mint, amount, ok := parseTokenAccountAmount(account.Account.Data)
+		if !ok {
+			log.Warn("parse token account fail", "owner", owner, "account", account.Pubkey)
+			continue
+		}
+		if balance, exist := balances[mint]; exist {
+			balance.Add(balance, amount)
+		} else {
+			balances[mint] = amount
+		}
+	}
+	return balances, nil
+}
+
+// GetMultipleTokenAmounts 用一次 getMultipleAccounts 查询多个 token 账户 finalized 状态下的 mint 和余额，顺序和 accounts 一致，
+// 账户不存在或者不是 token 账户时为 nil。一次最多查询 MaxMultipleAccounts 个账户
+func (sol *SolanaClient) GetMultipleTokenAmounts(accounts []string) ([]*TokenAmount, error) {
+	if len(accounts) > MaxMultipleAccounts {
+		return nil, fmt.Errorf("too many accounts %d, max %d", len(accounts), MaxMultipleAccounts)
+	}
+	res, err := sol.RpcClient.GetMultipleAccountsWithConfig(context.Background(), accounts, rpc.GetMultipleAccountsConfig{
+		Commitment: rpc.CommitmentFinalized,
+		Encoding:   rpc.AccountEncodingJsonParsed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, rpcError(res.Error)
+	}
+	if len(res.Result.Value) != len(accounts) {
+		return nil, fmt.Errorf("get multiple accounts returned %d accounts, want %d", len(res.Result.Value), len(accounts))
+	}
+	amounts := make([]*TokenAmount, len(accounts))
+	for i, account := range res.Result.Value {
+		if account.Owner != tokenProgramId && account.Owner != token2022ProgramId {
+			continue
+		}
+		mint, amount, ok := parseTokenAccountAmount(account.Data)
+		if !ok {
+			log.Warn("parse token account fail", "account", accounts[i])
+			continue
+		}
+		amounts[i] = &TokenAmount{Mint: mint, Amount: amount}
+	}
+	return amounts, nil
+}
+
+// GetTokenAccounts 返回 owner 在 Token 和 Token-2022 程序下的所有 token 账户地址
+func (sol *SolanaClient) GetTokenAccounts(owner string) ([]string, error) {
+	accounts, err := sol.tokenAccountsByOwner(owner)
+	if err != nil {
+		return nil, err
+	}
+	tokenAccounts := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		tokenAccounts = append(tokenAccounts, account.Pubkey)
+	}
+	return tokenAccounts, nil
+}
+
+func (sol *SolanaClient) tokenAccountsByOwner(owner string) (rpc.GetProgramAccounts, error) {
+	var accounts rpc.GetProgramAccounts
+	for _, programId := range []string{tokenProgramId, token2022ProgramId} {
+		res, err := sol.RpcClient.GetTokenAccountsByOwnerWithConfig(context.Background(), owner,
+			rpc.GetTokenAccountsByOwnerConfigFilter{ProgramId: programId},
+			rpc.GetTokenAccountsByOwnerConfig{Commitment: rpc.CommitmentFinalized, Encoding: rpc.AccountEncodingJsonParsed},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, rpcError(res.Error)
+		}
+		accounts = append(accounts, res.Result.Value...)
+	}
+	return accounts, nil
+}
+
 func (sol *SolanaClient) GetNonce(nonceAccount string) (string, error) {
 	nonce, err := sol.Client.GetNonceFromNonceAccount(context.Background(), nonceAccount)
 	if err != nil {
@@ -138,11 +388,15 @@ func (sol *SolanaClient) GetNonce(nonceAccount string) (string, error) {
 	return nonce, nil
 }
 
-func (sol *SolanaClient) GetMinRent() (string, error) {
-	bal, err := sol.RpcClient.GetMinimumBalanceForRentExemption(context.Background(), 100)
+// GetMinRent 查询数据长度为 dataLen 的账户免租金的最低余额(lamports)，普通系统账户传 SystemAccountSize
+func (sol *SolanaClient) GetMinRent(dataLen uint64) (string, error) {
+	bal, err := sol.RpcClient.GetMinimumBalanceForRentExemption(context.Background(), dataLen)
 	if err != nil {
 		return "", err
 	}
+	if bal.Error != nil {
+		return "", rpcError(bal.Error)
+	}
 	return strconv.FormatUint(bal.Result, 10), nil
 }
 
@@ -151,5 +405,37 @@ func (sol *SolanaClient) SendRawTransaction(rawTx string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if bal.Error != nil {
+		return "", fmt.Errorf("%w: %w", ErrTransactionRejected, rpcError(bal.Error))
+	}
+	if bal.Result == "" {
+		return "", fmt.Errorf("%w: empty signature returned", ErrTransactionRejected)
+	}
 	return bal.Result, nil
 }
+
+// callUseNumber 和 rpc 包内部的 call 一样发起请求，解析时数字保留为 json.Number，
+// jsonParsed 指令里的金额不经过 float64，超过 2^53 的金额不会丢失精度
+func callUseNumber[T any](client *rpc.RpcClient, ctx context.Context, params ...any) (T, error) {
+	var output T
+	body, err := client.Call(ctx, params...)
+	if err != nil {
+		return output, fmt.Errorf("rpc: call error, err: %v, body: %v", err, string(body))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&output); err != nil {
+		return output, fmt.Errorf("rpc: failed to json decode body, err: %v", err)
+	}
+	return output, nil
+}
+
+func rpcError(err *rpc.JsonRpcError) error {
+	switch err.Code {
+	case slotSkippedErrCode:
+		return fmt.Errorf("%w: %s", ErrSlotSkipped, err.Message)
+	case longTermStorageSlotSkippedErrCode:
+		return fmt.Errorf("%w: %s", ErrSlotUnavailable, err.Message)
+	}
+	return err
+}
@@ -1,8 +1,17 @@
 package node
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
 )
 
 func newTestClient() *SolanaClient {
@@ -12,19 +21,19 @@ func newTestClient() *SolanaClient {
 
 func TestSolanaClient_GetCurrentSlot(t *testing.T) {
 	client := newTestClient()
-	result, _ := client.GetCurrentSlot()
+	result, _ := client.GetCurrentSlot(rpc.CommitmentFinalized)
 	fmt.Println("result======", result)
 }
 
 func TestSolanaClient_GetLatestBlockHeight(t *testing.T) {
 	client := newTestClient()
-	result, _ := client.GetLatestBlockHeight()
+	result, _ := client.GetLatestBlockHeight(rpc.CommitmentFinalized)
 	fmt.Println("result======", result)
 }
 
 func TestSolanaClient_GetBlock(t *testing.T) {
 	client := newTestClient()
-	result, _ := client.GetBlock(258030759)
+	result, _ := client.GetBlock(258030759, rpc.CommitmentFinalized)
 	for _, v := range result {
 		fmt.Println("BlockHeight", v.BlockHeight)
 		fmt.Println("BlockHash", v.BlockHash)
@@ -50,6 +59,311 @@ func TestSolanaClient_GetNonce(t *testing.T) {
 
 func TestSolanaClient_GetMinRent(t *testing.T) {
 	client := newTestClient()
-	minRent, _ := client.GetMinRent()
+	minRent, _ := client.GetMinRent(SystemAccountSize)
 	fmt.Println("minRent==", minRent)
 }
+
+// newFakeRpcClient 启动一个本地 JSON-RPC 服务，handler 根据请求的 method 和 params 返回 result 或 error
+func newFakeRpcClient(t *testing.T, handler func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError)) *SolanaClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		result, rpcErr := handler(req.Method, req.Params)
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewSolanaClient(server.URL)
+	require.NoError(t, err)
+	return client
+}
+
+func requestCommitment(t *testing.T, param json.RawMessage) rpc.Commitment {
+	var cfg struct {
+		Commitment rpc.Commitment `json:"commitment"`
+	}
+	require.NoError(t, json.Unmarshal(param, &cfg))
+	return cfg.Commitment
+}
+
+func TestSolanaClient_GetCurrentSlotCommitment(t *testing.T) {
+	slots := map[rpc.Commitment]uint64{
+		rpc.CommitmentConfirmed: 120,
+		rpc.CommitmentFinalized: 88,
+	}
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getSlot", method)
+		require.Len(t, params, 1)
+		return slots[requestCommitment(t, params[0])], nil
+	})
+
+	for commitment, want := range slots {
+		slot, err := client.GetCurrentSlot(commitment)
+		require.NoError(t, err)
+		require.Equal(t, want, slot)
+	}
+}
+
+func TestSolanaClient_GetBlockHeaderSkipped(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getBlock", method)
+		require.Len(t, params, 2)
+		require.Equal(t, rpc.CommitmentConfirmed, requestCommitment(t, params[1]))
+		var slot uint64
+		require.NoError(t, json.Unmarshal(params[0], &slot))
+		if slot == 101 {
+			return nil, &rpc.JsonRpcError{Code: slotSkippedErrCode, Message: "Slot 101 was skipped"}
+		}
+		if slot == 102 {
+			return nil, &rpc.JsonRpcError{Code: longTermStorageSlotSkippedErrCode, Message: "Slot 102 was skipped, or missing in long-term storage"}
+		}
+		return map[string]interface{}{
+			"blockhash":         "hash-100",
+			"previousBlockhash": "hash-99",
+			"parentSlot":        99,
+		}, nil
+	})
+
+	header, err := client.GetBlockHeader(100, rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, "hash-100", header.BlockHash)
+	require.Equal(t, "hash-99", header.PreviousBlockhash)
+	require.Equal(t, uint64(99), header.ParentSlot)
+
+	_, err = client.GetBlockHeader(101, rpc.CommitmentConfirmed)
+	require.True(t, errors.Is(err, ErrSlotSkipped))
+
+	// 长期存储缺失不能当成跳过的 slot，需要换节点重试
+	_, err = client.GetBlockHeader(102, rpc.CommitmentConfirmed)
+	require.True(t, errors.Is(err, ErrSlotUnavailable))
+	require.False(t, errors.Is(err, ErrSlotSkipped))
+}
+
+func TestSolanaClient_GetRecentBlockHash(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getLatestBlockhash", method)
+		require.Len(t, params, 1)
+		require.Equal(t, rpc.CommitmentConfirmed, requestCommitment(t, params[0]))
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 300},
+			"value": map[string]interface{}{
+				"blockhash":            "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N",
+				"lastValidBlockHeight": 3090,
+			},
+		}, nil
+	})
+
+	recentBlockhash, err := client.GetRecentBlockHash()
+	require.NoError(t, err)
+	require.Equal(t, "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N", recentBlockhash.Blockhash)
+	require.Equal(t, uint64(3090), recentBlockhash.LastValidBlockHeight)
+}
+
+func TestSolanaClient_GetMinRentRpcError(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getMinimumBalanceForRentExemption", method)
+		return nil, &rpc.JsonRpcError{Code: -32005, Message: "Node is behind"}
+	})
+
+	// 节点返回错误时不能把空的 result 当成 0 租金
+	_, err := client.GetMinRent(TokenAccountSize)
+	var rpcErr *rpc.JsonRpcError
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, -32005, rpcErr.Code)
+}
+
+func TestSolanaClient_GetSignatureStatuses(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getSignatureStatuses", method)
+		require.Len(t, params, 2)
+		var cfg rpc.GetSignatureStatusesConfig
+		require.NoError(t, json.Unmarshal(params[1], &cfg))
+		require.True(t, cfg.SearchTransactionHistory)
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 300},
+			"value": []interface{}{
+				map[string]interface{}{"slot": 280, "confirmations": nil, "confirmationStatus": "finalized", "err": nil},
+				nil,
+			},
+		}, nil
+	})
+
+	statuses, err := client.GetSignatureStatuses([]string{"landed", "dropped"})
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, uint64(280), statuses[0].Slot)
+	require.Equal(t, rpc.CommitmentFinalized, statuses[0].ConfirmationStatus)
+	require.Nil(t, statuses[1])
+}
+
+func TestSolanaClient_GetBlockSplTokenTransfer(t *testing.T) {
+	const (
+		mint             = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+		senderWallet     = "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU"
+		senderTokenAcc   = "3emsAVdmGKERbHjmGfQ6oZ1e35dkf5iYcS6U4CPKFVaa"
+		receiverWallet   = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+		receiverTokenAcc = "GJRs4FwHtemZ5ZE9x3FNvJ8TMwitKTh21yxdRPqn7npE"
+	)
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getBlock", method)
+		return map[string]interface{}{
+			"blockhash":         "hash-100",
+			"previousBlockhash": "hash-99",
+			"parentSlot":        99,
+			"transactions": []interface{}{map[string]interface{}{
+				"meta": map[string]interface{}{
+					"fee": 5000,
+					"preTokenBalances": []interface{}{
+						map[string]interface{}{"accountIndex": 1, "mint": mint, "owner": senderWallet, "uiTokenAmount": map[string]interface{}{"amount": "5000000", "decimals": 6}},
+					},
+					"postTokenBalances": []interface{}{
+						map[string]interface{}{"accountIndex": 1, "mint": mint, "owner": senderWallet, "uiTokenAmount": map[string]interface{}{"amount": "4000000", "decimals": 6}},
+						map[string]interface{}{"accountIndex": 2, "mint": mint, "owner": receiverWallet, "uiTokenAmount": map[string]interface{}{"amount": "1000000", "decimals": 6}},
+					},
+				},
+				"transaction": map[string]interface{}{
+					"signatures": []interface{}{"spl-signature"},
+					"message": map[string]interface{}{
+						"accountKeys": []interface{}{
+							map[string]interface{}{"pubkey": senderWallet, "signer": true, "writable": true},
+							map[string]interface{}{"pubkey": senderTokenAcc, "signer": false, "writable": true},
+							map[string]interface{}{"pubkey": receiverTokenAcc, "signer": false, "writable": true},
+						},
+						"instructions": []interface{}{map[string]interface{}{
+							"program": "spl-token",
+							"parsed": map[string]interface{}{
+								"type": "transfer",
+								"info": map[string]interface{}{
+									"source":      senderTokenAcc,
+									"destination": receiverTokenAcc,
+									"authority":   senderWallet,
+									"amount":      "1000000",
+								},
+							},
+						}},
+					},
+				},
+			}},
+		}, nil
+	})
+
+	txList, err := client.GetBlock(100, rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Len(t, txList, 1)
+	require.Equal(t, senderWallet, txList[0].Source)
+	require.Equal(t, receiverWallet, txList[0].Destination)
+	require.Equal(t, mint, txList[0].TokenAddress)
+	require.Equal(t, big.NewInt(1_000_000), txList[0].Lamports)
+}
+
+func TestSolanaClient_GetTokenBalances(t *testing.T) {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	tokenAccount := func(mint, amount string) map[string]interface{} {
+		return map[string]interface{}{
+			"pubkey": "TokenAccount" + amount,
+			"account": map[string]interface{}{
+				"lamports": 2039280,
+				"owner":    tokenProgramId,
+				"data": map[string]interface{}{
+					"program": "spl-token",
+					"parsed": map[string]interface{}{
+						"type": "account",
+						"info": map[string]interface{}{
+							"mint":        mint,
+							"owner":       "OwnerAddress",
+							"tokenAmount": map[string]interface{}{"amount": amount, "decimals": 6},
+						},
+					},
+				},
+			},
+		}
+	}
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getTokenAccountsByOwner", method)
+		require.Len(t, params, 3)
+		require.Equal(t, rpc.CommitmentFinalized, requestCommitment(t, params[2]))
+		var filter rpc.GetTokenAccountsByOwnerConfigFilter
+		require.NoError(t, json.Unmarshal(params[1], &filter))
+		value := []interface{}{}
+		if filter.ProgramId == tokenProgramId {
+			// 同一个 mint 的多个 token 账户按 mint 汇总
+			value = append(value, tokenAccount(usdcMint, "1500000"), tokenAccount(usdcMint, "500000"))
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 300}, "value": value}, nil
+	})
+
+	balances, err := client.GetTokenBalances("OwnerAddress")
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	require.Equal(t, big.NewInt(2_000_000), balances[usdcMint])
+}
+
+func TestSolanaClient_GetMultipleLamports(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getMultipleAccounts", method)
+		require.Len(t, params, 2)
+		require.Equal(t, rpc.CommitmentFinalized, requestCommitment(t, params[1]))
+		var addresses []string
+		require.NoError(t, json.Unmarshal(params[0], &addresses))
+		require.Equal(t, []string{"AddressA", "AddressB"}, addresses)
+		// 不存在的账户返回 null
+		value := []interface{}{
+			map[string]interface{}{"lamports": 1_500_000, "owner": "11111111111111111111111111111111", "data": []string{"", "base64"}},
+			nil,
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 300}, "value": value}, nil
+	})
+
+	lamports, err := client.GetMultipleLamports([]string{"AddressA", "AddressB"})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1_500_000, 0}, lamports)
+}
+
+func TestAssociatedTokenAccounts(t *testing.T) {
+	const owner = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	const mint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	accounts, err := AssociatedTokenAccounts(owner, mint)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	expected, _, err := common.FindAssociatedTokenAddress(common.PublicKeyFromString(owner), common.PublicKeyFromString(mint))
+	require.NoError(t, err)
+	require.Equal(t, expected.ToBase58(), accounts[0])
+	require.NotEqual(t, accounts[0], accounts[1])
+
+	_, err = AssociatedTokenAccounts("InvalidOwner0l", mint)
+	require.Error(t, err)
+}
+
+func TestSolanaClient_GetMultipleTokenAmounts(t *testing.T) {
+	client := newFakeRpcClient(t, func(method string, params []json.RawMessage) (interface{}, *rpc.JsonRpcError) {
+		require.Equal(t, "getMultipleAccounts", method)
+		require.Len(t, params, 2)
+		require.Equal(t, rpc.CommitmentFinalized, requestCommitment(t, params[1]))
+		// 不存在的账户返回 null，不是 token 程序的账户忽略
+		value := []interface{}{
+			map[string]interface{}{"lamports": 2_039_280, "owner": tokenProgramId, "data": map[string]interface{}{
+				"program": "spl-token",
+				"parsed": map[string]interface{}{"info": map[string]interface{}{
+					"mint":        "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v",
+					"tokenAmount": map[string]interface{}{"amount": "2500000", "decimals": 6},
+				}},
+			}},
+			nil,
+			map[string]interface{}{"lamports": 1_500_000, "owner": "11111111111111111111111111111111", "data": []string{"", "base64"}},
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 300}, "value": value}, nil
+	})
+
+	amounts, err := client.GetMultipleTokenAmounts([]string{"AccountA", "AccountB", "AccountC"})
+	require.NoError(t, err)
+	require.Equal(t, []*TokenAmount{{Mint: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Amount: big.NewInt(2_500_000)}, nil, nil}, amounts)
+}
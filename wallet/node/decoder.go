@@ -0,0 +1,255 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DecodedTransfer 指令解析出来的一笔转账，Source/Destination 为钱包地址，TokenAddress 为 mint，SOL 转账为空
+type DecodedTransfer struct {
+	Source       string
+	Destination  string
+	TokenAddress string
+	Amount       *big.Int
+}
+
+// InstructionContext 解析指令时可用的交易上下文
+type InstructionContext struct {
+	TokenAccounts map[string]TokenAccount
+}
+
+// InstructionDecoder 解析 jsonParsed 编码下指令的 parsed.info，返回 nil 表示该指令不产生转账
+type InstructionDecoder func(info map[string]interface{}, ctx *InstructionContext) (*DecodedTransfer, error)
+
+var (
+	decodersLock        sync.RWMutex
+	instructionDecoders = make(map[string]InstructionDecoder)
+)
+
+func decoderKey(program, instructionType string) string {
+	return program + "/" + instructionType
+}
+
+// RegisterInstructionDecoder 注册 program 下 instructionType 类型指令的解析器，重复注册会覆盖
+func RegisterInstructionDecoder(program, instructionType string, decoder InstructionDecoder) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	instructionDecoders[decoderKey(program, instructionType)] = decoder
+}
+
+func lookupInstructionDecoder(program, instructionType string) (InstructionDecoder, bool) {
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	decoder, ok := instructionDecoders[decoderKey(program, instructionType)]
+	return decoder, ok
+}
+
+func init() {
+	RegisterInstructionDecoder("system", "transfer", decodeSystemTransfer("source", "destination"))
+	RegisterInstructionDecoder("system", "transferWithSeed", decodeSystemTransfer("source", "destination"))
+	RegisterInstructionDecoder("system", "createAccount", decodeSystemTransfer("source", "newAccount"))
+	RegisterInstructionDecoder("system", "createAccountWithSeed", decodeSystemTransfer("source", "newAccount"))
+	for _, program := range []string{"spl-token", "spl-token-2022"} {
+		RegisterInstructionDecoder(program, "transfer", decodeTokenTransfer)
+		RegisterInstructionDecoder(program, "transferChecked", decodeTokenTransfer)
+	}
+}
+
+// decodeSystemTransfer 系统程序中带 lamports 的指令，sourceKey/destinationKey 为 info 中转出和转入地址的字段名
+func decodeSystemTransfer(sourceKey, destinationKey string) InstructionDecoder {
+	return func(info map[string]interface{}, ctx *InstructionContext) (*DecodedTransfer, error) {
+		source, _ := info[sourceKey].(string)
+		destination, _ := info[destinationKey].(string)
+		if source == "" || destination == "" {
+			return nil, fmt.Errorf("missing %s or %s", sourceKey, destinationKey)
+		}
+		amount, err := parseAmount(info["lamports"])
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedTransfer{Source: source, Destination: destination, Amount: amount}, nil
+	}
+}
+
+// decodeTokenTransfer 解析 transfer 和 transferChecked，token 账户转换为 owner 钱包地址。
+// source 查不到 owner 时使用签名的 authority；destination 查不到 owner 时保留 token 账户地址
+func decodeTokenTransfer(info map[string]interface{}, ctx *InstructionContext) (*DecodedTransfer, error) {
+	source, _ := info["source"].(string)
+	destination, _ := info["destination"].(string)
+	if source == "" || destination == "" {
+		return nil, fmt.Errorf("missing source or destination")
+	}
+
+	var amount *big.Int
+	var err error
+	if tokenAmount, ok := info["tokenAmount"].(map[string]interface{}); ok {
+		amount, err = parseAmount(tokenAmount["amount"])
+	} else {
+		amount, err = parseAmount(info["amount"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &DecodedTransfer{Source: source, Destination: destination, Amount: amount}
+	authority, _ := info["authority"].(string)
+	if authority == "" {
+		authority, _ = info["multisigAuthority"].(string)
+	}
+	if account, ok := ctx.TokenAccounts[source]; ok && account.Owner != "" {
+		transfer.Source = account.Owner
+		transfer.TokenAddress = account.Mint
+	} else if authority != "" {
+		transfer.Source = authority
+	}
+	if account, ok := ctx.TokenAccounts[destination]; ok {
+		if account.Owner != "" {
+			transfer.Destination = account.Owner
+		}
+		if transfer.TokenAddress == "" {
+			transfer.TokenAddress = account.Mint
+		}
+	}
+	if mint, ok := info["mint"].(string); ok && mint != "" {
+		transfer.TokenAddress = mint
+	}
+	return transfer, nil
+}
+
+// parseAmount 解析 json.Number 或者字符串形式的整数金额。float64 表示解析 JSON 时没有使用 UseNumber，
+// 大金额已经丢失精度，直接返回错误
+func parseAmount(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case float64:
+		return nil, fmt.Errorf("amount %v decoded as float64, decode json with UseNumber", v)
+	case json.Number:
+		amount, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %s", v)
+		}
+		return amount, nil
+	case string:
+		amount, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %s", v)
+		}
+		return amount, nil
+	}
+	return nil, fmt.Errorf("invalid amount %v", value)
+}
+
+// parseBlock 按顺序解析区块中每笔交易的顶层指令以及 meta.innerInstructions 中 CPI 产生的指令，
+// 执行失败的交易同样返回，Err 为失败原因，由调用方决定跳过还是标记失败
+func parseBlock(slot uint64, block *rpc.GetBlock) []TransactionDetail {
+	var txDetailList []TransactionDetail
+	if block == nil {
+		return txDetailList
+	}
+	for _, value := range block.Transactions {
+		for _, txDetail := range parseTransaction(slot, value.Transaction, value.Meta) {
+			txDetail.PreviousBlockhash = block.PreviousBlockhash
+			txDetail.BlockHash = block.Blockhash
+			txDetailList = append(txDetailList, txDetail)
+		}
+	}
+	return txDetailList
+}
+
+// parseTransaction 解析单笔交易中的转账，不包含区块哈希
+func parseTransaction(slot uint64, value interface{}, meta *rpc.TransactionMeta) []TransactionDetail {
+	var txDetailList []TransactionDetail
+	transaction, ok := value.(map[string]interface{})
+	if !ok {
+		return txDetailList
+	}
+	message, _ := transaction["message"].(map[string]interface{})
+	signatures, _ := transaction["signatures"].([]interface{})
+	if message == nil || len(signatures) == 0 {
+		return txDetailList
+	}
+	txHash, _ := signatures[0].(string)
+	instructions, _ := message["instructions"].([]interface{})
+	accountKeys, _ := message["accountKeys"].([]interface{})
+	ctx := &InstructionContext{TokenAccounts: parseTokenAccounts(accountKeys, meta)}
+
+	innerInstructions := make(map[uint64][]interface{})
+	fee := big.NewInt(0)
+	var txErr string
+	if meta != nil {
+		txErr = transactionError(meta.Err)
+		for _, inner := range meta.InnerInstructions {
+			innerInstructions[inner.Index] = append(innerInstructions[inner.Index], inner.Instructions...)
+		}
+		fee = new(big.Int).SetUint64(meta.Fee)
+	}
+
+	var instructionIndex uint64
+	for index, instruction := range instructions {
+		instructionList := append([]interface{}{instruction}, innerInstructions[uint64(index)]...)
+		for _, item := range instructionList {
+			itemIndex := instructionIndex
+			instructionIndex++
+			instructionType, transfer := decodeInstruction(txHash, item, ctx)
+			if transfer == nil {
+				continue
+			}
+			txDetailList = append(txDetailList, TransactionDetail{
+				BlockHeight:      new(big.Int).SetUint64(slot),
+				TxHash:           txHash,
+				Destination:      transfer.Destination,
+				Source:           transfer.Source,
+				TokenAddress:     transfer.TokenAddress,
+				Lamports:         transfer.Amount,
+				Type:             instructionType,
+				Fee:              new(big.Int).Set(fee),
+				Err:              txErr,
+				InstructionIndex: itemIndex,
+			})
+		}
+	}
+	return txDetailList
+}
+
+// transactionError 把 meta.err 转换为字符串，例如 "BlockhashNotFound" 或 {"InstructionError":[0,{"Custom":1}]}
+func transactionError(metaErr interface{}) string {
+	if metaErr == nil {
+		return ""
+	}
+	if errStr, ok := metaErr.(string); ok {
+		return errStr
+	}
+	errBytes, err := json.Marshal(metaErr)
+	if err != nil {
+		return fmt.Sprintf("%v", metaErr)
+	}
+	return string(errBytes)
+}
+
+func decodeInstruction(txHash string, instruction interface{}, ctx *InstructionContext) (string, *DecodedTransfer) {
+	instructionItem, ok := instruction.(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	program, _ := instructionItem["program"].(string)
+	parsed, _ := instructionItem["parsed"].(map[string]interface{})
+	if program == "" || parsed == nil {
+		return "", nil
+	}
+	instructionType, _ := parsed["type"].(string)
+	decoder, ok := lookupInstructionDecoder(program, instructionType)
+	if !ok {
+		return "", nil
+	}
+	info, _ := parsed["info"].(map[string]interface{})
+	transfer, err := decoder(info, ctx)
+	if err != nil {
+		log.Warn("decode instruction fail", "txHash", txHash, "program", program, "type", instructionType, "err", err)
+		return "", nil
+	}
+	return instructionType, transfer
+}
@@ -0,0 +1,158 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func loadBlockFixture(t *testing.T, name string) *rpc.GetBlock {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	var block rpc.GetBlock
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&block))
+	return &block
+}
+
+func TestParseBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    []TransactionDetail
+	}{
+		{
+			name:    "system transfer, unparsed instructions are ignored",
+			fixture: "system_transfer.json",
+			want: []TransactionDetail{{
+				TxHash:      "4jN8Jq8oE4xR3QvPSCjYXNm6AL2LrJ9JzQG5mRcTE6t5tT6tH8H1k2z8s9dMwhkLxqXhQZWfV9oYw8rXPvGw7mNd",
+				Source:      "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+				Destination: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+				Lamports:    big.NewInt(1_000_000_000),
+				Type:        "transfer",
+			}},
+		},
+		{
+			name:    "spl-token transferChecked",
+			fixture: "transfer_checked.json",
+			want: []TransactionDetail{{
+				TxHash:       "3yZe7d8HkCgJYRnHbb1ZkQxG4jEV5NuAj1QvH5X7f1gX6yS9sQ6JfG3nYYrj6Y5r9mQf2w1K4fW5Yh3kZtJuP8uN",
+				Source:       "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+				Destination:  "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+				TokenAddress: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v",
+				Lamports:     big.NewInt(2_500_000),
+				Type:         "transferChecked",
+			}},
+		},
+		{
+			name:    "system createAccount with lamports",
+			fixture: "create_account.json",
+			want: []TransactionDetail{{
+				TxHash:      "5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW",
+				Source:      "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+				Destination: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+				Lamports:    big.NewInt(2_000_000_000),
+				Type:        "createAccount",
+			}},
+		},
+		{
+			name:    "transfers made through CPI in inner instructions",
+			fixture: "inner_instructions.json",
+			want: []TransactionDetail{
+				{
+					TxHash:           "61Eo1LaU5uDtDahrKdfnHnb3KnvZmJPwLpAH2bFLmVKDJZ8rxfXoNyyTAmBC6UyNrYSjSUzByvi5cq6w4QRMZxNR",
+					Source:           "2ojv9BAiHUrvsm9gxDe7fJSzbNZSJcxZvf8dqmWGHG8S",
+					Destination:      "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+					Lamports:         big.NewInt(300_000_000),
+					Type:             "transfer",
+					InstructionIndex: 2,
+				},
+				{
+					TxHash:           "61Eo1LaU5uDtDahrKdfnHnb3KnvZmJPwLpAH2bFLmVKDJZ8rxfXoNyyTAmBC6UyNrYSjSUzByvi5cq6w4QRMZxNR",
+					Source:           "2ojv9BAiHUrvsm9gxDe7fJSzbNZSJcxZvf8dqmWGHG8S",
+					Destination:      "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+					TokenAddress:     "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB",
+					Lamports:         big.NewInt(1_200_000),
+					Type:             "transfer",
+					InstructionIndex: 3,
+				},
+			},
+		},
+		{
+			name:    "failed transaction keeps meta.err",
+			fixture: "failed_transfer.json",
+			want: []TransactionDetail{{
+				TxHash:      "2nBhEBYYvfaAe16UMNqRHre4YNSskvuYgx3M6E4JP1oDYvZEJHvoPzyUJ2xFLHhbZBCCTgGyobSCNhMJF6AJeWep",
+				Source:      "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+				Destination: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+				Lamports:    big.NewInt(5_000_000_000),
+				Type:        "transfer",
+				Err:         `{"InstructionError":[0,{"Custom":1}]}`,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := loadBlockFixture(t, tt.fixture)
+			got := parseBlock(279212290, block)
+			require.Len(t, got, len(tt.want))
+			for i, want := range tt.want {
+				require.Equal(t, want.TxHash, got[i].TxHash)
+				require.Equal(t, want.Source, got[i].Source)
+				require.Equal(t, want.Destination, got[i].Destination)
+				require.Equal(t, want.TokenAddress, got[i].TokenAddress)
+				require.Equal(t, want.Lamports, got[i].Lamports)
+				require.Equal(t, want.Type, got[i].Type)
+				require.Equal(t, want.Err, got[i].Err)
+				require.Equal(t, want.InstructionIndex, got[i].InstructionIndex)
+				require.Equal(t, block.Blockhash, got[i].BlockHash)
+				require.Equal(t, block.PreviousBlockhash, got[i].PreviousBlockhash)
+				require.Equal(t, big.NewInt(279212290), got[i].BlockHeight)
+			}
+		})
+	}
+}
+
+func TestRegisterInstructionDecoder(t *testing.T) {
+	RegisterInstructionDecoder("memo-router", "payout", func(info map[string]interface{}, ctx *InstructionContext) (*DecodedTransfer, error) {
+		amount, err := parseAmount(info["amount"])
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedTransfer{Source: info["from"].(string), Destination: info["to"].(string), Amount: amount}, nil
+	})
+
+	instructionType, transfer := decodeInstruction("signature", map[string]interface{}{
+		"program": "memo-router",
+		"parsed": map[string]interface{}{
+			"type": "payout",
+			"info": map[string]interface{}{"from": "a", "to": "b", "amount": "42"},
+		},
+	}, &InstructionContext{})
+	require.Equal(t, "payout", instructionType)
+	require.Equal(t, &DecodedTransfer{Source: "a", Destination: "b", Amount: big.NewInt(42)}, transfer)
+}
+
+func TestParseAmount(t *testing.T) {
+	// 超过 2^53 的金额按 json.Number 解析不丢失精度
+	amount, err := parseAmount(json.Number("18446744073709551615"))
+	require.NoError(t, err)
+	require.Equal(t, "18446744073709551615", amount.String())
+
+	amount, err = parseAmount("9007199254740993")
+	require.NoError(t, err)
+	require.Equal(t, "9007199254740993", amount.String())
+
+	_, err = parseAmount(float64(9007199254740993))
+	require.Error(t, err)
+	_, err = parseAmount(json.Number("1.5"))
+	require.Error(t, err)
+}
@@ -0,0 +1,381 @@
+package node
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+var _ SolanaChain = (*FakeChain)(nil)
+
+type fakeBlock struct {
+	header BlockHeader
+	txs    []TransactionDetail
+}
+
+// FakeChain 内存中的 Solana 链，实现 SolanaChain 接口，用于在 go test 中跑通充值扫描、提现发送和归集流程，
+// 没有出块的 slot 按照被跳过处理
+type FakeChain struct {
+	mu sync.Mutex
+
+	blocks         map[uint64]*fakeBlock
+	versions       map[uint64]int
+	latestSlot     uint64
+	confirmedSlot  uint64
+	finalizedSlot  uint64
+	balances       map[string]uint64
+	tokenBalances  map[string]map[string]*big.Int
+	nonces         map[string]string
+	blockhashIndex uint64
+	sentTxs        []string
+}
+
+func NewFakeChain() *FakeChain {
+	return &FakeChain{
+		blocks:        make(map[uint64]*fakeBlock),
+		versions:      make(map[uint64]int),
+		balances:      make(map[string]uint64),
+		tokenBalances: make(map[string]map[string]*big.Int),
+		nonces:        make(map[string]string),
+	}
+}
+
+// AddBlock 在 slot 上出一个块，父区块为 slot 之前最近的一个块，交易的区块信息会被自动填充。
+// 已经存在的块会被替换（模拟分叉），新块的 slot 同时成为 confirmed 和 finalized 的最新 slot，可以通过 SetFinalizedSlot 回退
+func (fc *FakeChain) AddBlock(slot uint64, txs ...TransactionDetail) BlockHeader {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	var parentSlot uint64
+	previousBlockhash := "11111111111111111111111111111111"
+	for s := slot; s > 0; s-- {
+		if parent, ok := fc.blocks[s-1]; ok {
+			parentSlot = s - 1
+			previousBlockhash = parent.header.BlockHash
+			break
+		}
+	}
+
+	fc.versions[slot]++
+	header := BlockHeader{
+		Slot:              slot,
+		ParentSlot:        parentSlot,
+		BlockHash:         fmt.Sprintf("fake-block-%d-%d", slot, fc.versions[slot]),
+		PreviousBlockhash: previousBlockhash,
+	}
+
+	blockTxs := make([]TransactionDetail, 0, len(txs))
+	for _, tx := range txs {
+		tx.BlockHash = header.BlockHash
+		tx.PreviousBlockhash = header.PreviousBlockhash
+		tx.BlockHeight = new(big.Int).SetUint64(slot)
+		if tx.Fee == nil {
+			tx.Fee = big.NewInt(5000)
+		}
+		blockTxs = append(blockTxs, tx)
+	}
+	fc.blocks[slot] = &fakeBlock{header: header, txs: blockTxs}
+
+	if slot > fc.latestSlot {
+		fc.latestSlot = slot
+	}
+	fc.confirmedSlot = fc.latestSlot
+	fc.finalizedSlot = fc.latestSlot
+	return header
+}
+
+// SkipSlot 删除 slot 上的块，模拟该 slot 在分叉后被跳过
+func (fc *FakeChain) SkipSlot(slot uint64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	delete(fc.blocks, slot)
+}
+
+// SetFinalizedSlot 设置 finalized 级别的最新 slot
+func (fc *FakeChain) SetFinalizedSlot(slot uint64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.finalizedSlot = slot
+}
+
+func (fc *FakeChain) SetBalance(address string, lamports uint64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.balances[address] = lamports
+}
+
+// SetTokenBalance 设置 owner 持有的 mint 余额，同时为 owner 开一个该 mint 的 token 账户，地址见 FakeTokenAccount
+func (fc *FakeChain) SetTokenBalance(owner, mint string, amount *big.Int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.tokenBalances[owner] == nil {
+		fc.tokenBalances[owner] = make(map[string]*big.Int)
+	}
+	fc.tokenBalances[owner][mint] = new(big.Int).Set(amount)
+}
+
+// FakeTokenAccount 假链上 owner 持有 mint 的 token 账户地址，地址合法时为 Token 程序下的关联 token 账户
+func FakeTokenAccount(owner, mint string) string {
+	if accounts, err := AssociatedTokenAccounts(owner, mint); err == nil {
+		return accounts[0]
+	}
+	return fmt.Sprintf("fake-token-account-%s-%s", owner, mint)
+}
+
+func (fc *FakeChain) SetNonce(nonceAccount string, nonce string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.nonces[nonceAccount] = nonce
+}
+
+// SentTransactions 返回通过 SendRawTransaction 广播的原始交易
+func (fc *FakeChain) SentTransactions() []string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return append([]string(nil), fc.sentTxs...)
+}
+
+func (fc *FakeChain) GetCurrentSlot(commitment rpc.Commitment) (uint64, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	switch commitment {
+	case rpc.CommitmentFinalized:
+		return fc.finalizedSlot, nil
+	case rpc.CommitmentConfirmed:
+		return fc.confirmedSlot, nil
+	default:
+		return fc.latestSlot, nil
+	}
+}
+
+func (fc *FakeChain) GetBlock(slot uint64, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	block, err := fc.blockAt(slot, commitment)
+	if err != nil {
+		return nil, err
+	}
+	return append([]TransactionDetail(nil), block.txs...), nil
+}
+
+// GetTransaction 在已出块的交易中按签名查找，返回的转账不包含区块哈希
+func (fc *FakeChain) GetTransaction(signature string, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	var txList []TransactionDetail
+	for slot := range fc.blocks {
+		block, err := fc.blockAt(slot, commitment)
+		if err != nil {
+			continue
+		}
+		for _, tx := range block.txs {
+			if tx.TxHash != signature {
+				continue
+			}
+			tx.BlockHash = ""
+			tx.PreviousBlockhash = ""
+			txList = append(txList, tx)
+		}
+	}
+	return txList, nil
+}
+
+// GetSignaturesForAddress 按 slot 倒序返回 finalized 块中涉及 address 的交易签名。和链上一样，
+// SOL 转账按 Source 或 Destination 匹配钱包地址，token 转账只能通过双方的 token 账户查到
+func (fc *FakeChain) GetSignaturesForAddress(address string, before string, limit int) ([]SignatureInfo, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	var slots []uint64
+	for slot := range fc.blocks {
+		if slot <= fc.finalizedSlot {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] > slots[j] })
+
+	var signatures []SignatureInfo
+	seen := make(map[string]bool)
+	started := before == ""
+	for _, slot := range slots {
+		for _, tx := range fc.blocks[slot].txs {
+			if seen[tx.TxHash] || !fakeTxMentions(tx, address) {
+				continue
+			}
+			seen[tx.TxHash] = true
+			if !started {
+				started = tx.TxHash == before
+				continue
+			}
+			if len(signatures) == limit {
+				return signatures, nil
+			}
+			var txErr interface{}
+			if tx.Err != "" {
+				txErr = tx.Err
+			}
+			signatures = append(signatures, SignatureInfo{Signature: tx.TxHash, Slot: slot, Err: txErr})
+		}
+	}
+	return signatures, nil
+}
+
+func fakeTxMentions(tx TransactionDetail, address string) bool {
+	if tx.TokenAddress == "" {
+		return tx.Source == address || tx.Destination == address
+	}
+	return FakeTokenAccount(tx.Source, tx.TokenAddress) == address || FakeTokenAccount(tx.Destination, tx.TokenAddress) == address
+}
+
+func (fc *FakeChain) GetBlockHeader(slot uint64, commitment rpc.Commitment) (*BlockHeader, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	block, err := fc.blockAt(slot, commitment)
+	if err != nil {
+		return nil, err
+	}
+	header := block.header
+	return &header, nil
+}
+
+func (fc *FakeChain) GetBalance(address string) (string, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	lamportsOnAccount := new(big.Float).SetUint64(fc.balances[address])
+	solBalance := new(big.Float).Quo(lamportsOnAccount, new(big.Float).SetUint64(1000000000))
+	return solBalance.String(), nil
+}
+
+func (fc *FakeChain) GetLamports(address string) (uint64, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.balances[address], nil
+}
+
+func (fc *FakeChain) GetMultipleLamports(addresses []string) ([]uint64, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	lamports := make([]uint64, len(addresses))
+	for i, address := range addresses {
+		lamports[i] = fc.balances[address]
+	}
+	return lamports, nil
+}
+
+func (fc *FakeChain) GetTokenBalances(owner string) (map[string]*big.Int, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	balances := make(map[string]*big.Int)
+	for mint, amount := range fc.tokenBalances[owner] {
+		balances[mint] = new(big.Int).Set(amount)
+	}
+	return balances, nil
+}
+
+func (fc *FakeChain) GetMultipleTokenAmounts(accounts []string) ([]*TokenAmount, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	amounts := make([]*TokenAmount, len(accounts))
+	for i, account := range accounts {
+		for owner, balances := range fc.tokenBalances {
+			for mint, amount := range balances {
+				if FakeTokenAccount(owner, mint) == account {
+					amounts[i] = &TokenAmount{Mint: mint, Amount: new(big.Int).Set(amount)}
+				}
+			}
+		}
+	}
+	return amounts, nil
+}
+
+func (fc *FakeChain) GetTokenAccounts(owner string) ([]string, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	tokenAccounts := make([]string, 0, len(fc.tokenBalances[owner]))
+	for mint := range fc.tokenBalances[owner] {
+		tokenAccounts = append(tokenAccounts, FakeTokenAccount(owner, mint))
+	}
+	sort.Strings(tokenAccounts)
+	return tokenAccounts, nil
+}
+
+// GetRecentBlockHash 每次返回一个新的 blockhash，有效期为当前 slot 之后 150 个块
+func (fc *FakeChain) GetRecentBlockHash() (*RecentBlockhash, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.blockhashIndex++
+	return &RecentBlockhash{
+		Blockhash:            fmt.Sprintf("fake-blockhash-%d", fc.blockhashIndex),
+		LastValidBlockHeight: fc.latestSlot + 150,
+	}, nil
+}
+
+// GetLatestBlockHeight 假链上区块高度与 slot 相同
+func (fc *FakeChain) GetLatestBlockHeight(commitment rpc.Commitment) (uint64, error) {
+	return fc.GetCurrentSlot(commitment)
+}
+
+// GetSignatureStatuses 只有通过 AddBlock 打包进块的交易才能查到状态，发送过但没有出块的交易返回 nil
+func (fc *FakeChain) GetSignatureStatuses(signatures []string) ([]*SignatureStatus, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	statuses := make([]*SignatureStatus, len(signatures))
+	for i, signature := range signatures {
+		for slot, block := range fc.blocks {
+			for _, tx := range block.txs {
+				if tx.TxHash != signature {
+					continue
+				}
+				confirmationStatus := rpc.CommitmentConfirmed
+				if slot <= fc.finalizedSlot {
+					confirmationStatus = rpc.CommitmentFinalized
+				}
+				statuses[i] = &SignatureStatus{Slot: slot, ConfirmationStatus: confirmationStatus}
+				if tx.Err != "" {
+					statuses[i].Err = tx.Err
+				}
+			}
+		}
+	}
+	return statuses, nil
+}
+
+func (fc *FakeChain) SendRawTransaction(rawTx string) (string, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.sentTxs = append(fc.sentTxs, rawTx)
+	return fmt.Sprintf("fake-signature-%d", len(fc.sentTxs)), nil
+}
+
+func (fc *FakeChain) GetNonce(nonceAccount string) (string, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	nonce, ok := fc.nonces[nonceAccount]
+	if !ok {
+		return "", fmt.Errorf("nonce account %s not found", nonceAccount)
+	}
+	return nonce, nil
+}
+
+// GetMinRent 和主网一样按 (128 + dataLen) * 6960 计算免租金最低余额
+func (fc *FakeChain) GetMinRent(dataLen uint64) (string, error) {
+	return strconv.FormatUint((128+dataLen)*6960, 10), nil
+}
+
+func (fc *FakeChain) blockAt(slot uint64, commitment rpc.Commitment) (*fakeBlock, error) {
+	tip := fc.confirmedSlot
+	if commitment == rpc.CommitmentFinalized {
+		tip = fc.finalizedSlot
+	}
+	if slot > tip {
+		return nil, fmt.Errorf("block %d not available for commitment %s", slot, commitment)
+	}
+	block, ok := fc.blocks[slot]
+	if !ok {
+		return nil, fmt.Errorf("%w: slot %d", ErrSlotSkipped, slot)
+	}
+	return block, nil
+}
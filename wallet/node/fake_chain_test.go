@@ -0,0 +1,51 @@
+package node
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeChain_Blocks(t *testing.T) {
+	chain := NewFakeChain()
+	first := chain.AddBlock(10, TransactionDetail{TxHash: "tx-1", Lamports: big.NewInt(1)})
+	second := chain.AddBlock(12)
+	require.Equal(t, uint64(10), second.ParentSlot)
+	require.Equal(t, first.BlockHash, second.PreviousBlockhash)
+
+	txs, err := chain.GetBlock(10, rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, first.BlockHash, txs[0].BlockHash)
+	require.Equal(t, uint64(10), txs[0].BlockHeight.Uint64())
+
+	_, err = chain.GetBlock(11, rpc.CommitmentConfirmed)
+	require.True(t, errors.Is(err, ErrSlotSkipped))
+
+	// 替换 slot 10 模拟分叉，区块哈希发生变化
+	forked := chain.AddBlock(10)
+	require.NotEqual(t, first.BlockHash, forked.BlockHash)
+	header, err := chain.GetBlockHeader(10, rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, forked.BlockHash, header.BlockHash)
+}
+
+func TestFakeChain_Commitment(t *testing.T) {
+	chain := NewFakeChain()
+	chain.AddBlock(20)
+	chain.SetFinalizedSlot(18)
+
+	confirmed, err := chain.GetCurrentSlot(rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), confirmed)
+	finalized, err := chain.GetCurrentSlot(rpc.CommitmentFinalized)
+	require.NoError(t, err)
+	require.Equal(t, uint64(18), finalized)
+
+	_, err = chain.GetBlock(20, rpc.CommitmentFinalized)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrSlotSkipped))
+}
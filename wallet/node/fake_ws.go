@@ -0,0 +1,131 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// FakeWsServer 本地的 Solana WebSocket 服务，只实现 logsSubscribe，用于测试订阅充值。
+// 同一时间只保留最后一个连接，CloseConnections 可以模拟连接断开
+type FakeWsServer struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu            sync.Mutex
+	conn          *websocket.Conn
+	nextSub       uint64
+	subscriptions map[string]uint64
+}
+
+func NewFakeWsServer() *FakeWsServer {
+	s := &FakeWsServer{subscriptions: make(map[string]uint64)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *FakeWsServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+// Subscribed 当前连接上是否已经订阅了 address
+func (s *FakeWsServer) Subscribed(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.subscriptions[address]
+	return ok
+}
+
+// NotifyLogs 向订阅了 address 的连接推送一条 logsNotification
+func (s *FakeWsServer) NotifyLogs(address string, slot uint64, signature string, txErr interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subscription, ok := s.subscriptions[address]
+	if !ok || s.conn == nil {
+		return errors.New("address not subscribed")
+	}
+	return s.conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "logsNotification",
+		"params": map[string]interface{}{
+			"subscription": subscription,
+			"result": map[string]interface{}{
+				"context": map[string]interface{}{"slot": slot},
+				"value": map[string]interface{}{
+					"signature": signature,
+					"err":       txErr,
+					"logs":      []string{},
+				},
+			},
+		},
+	})
+}
+
+// CloseConnections 断开当前连接并清空订阅
+func (s *FakeWsServer) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	s.subscriptions = make(map[string]uint64)
+}
+
+func (s *FakeWsServer) Close() {
+	s.CloseConnections()
+	s.server.Close()
+}
+
+func (s *FakeWsServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	s.conn = conn
+	s.subscriptions = make(map[string]uint64)
+	s.mu.Unlock()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req struct {
+			Id     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			continue
+		}
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		var filter struct {
+			Mentions []string `json:"mentions"`
+		}
+		if req.Method != "logsSubscribe" || len(req.Params) == 0 || json.Unmarshal(req.Params[0], &filter) != nil || len(filter.Mentions) != 1 {
+			response["error"] = map[string]interface{}{"code": -32602, "message": "Invalid params"}
+		} else {
+			s.mu.Lock()
+			s.nextSub++
+			s.subscriptions[filter.Mentions[0]] = s.nextSub
+			response["result"] = s.nextSub
+			s.mu.Unlock()
+		}
+		s.mu.Lock()
+		err = conn.WriteJSON(response)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+const (
+	healthCheckInterval = 10 * time.Second
+	poolMaxAttempts     = 5
+	httpStatusErrPrefix = "get status code: "
+)
+
+// endpointErrCodes 节点自身状态导致的 JSON-RPC 错误，换一个节点可能成功：
+// -32603 节点内部错误，-32005 节点落后不健康，-32004 和 -32014 节点还没有该 slot 的区块或状态，-32016 节点没有到达 minContextSlot
+var endpointErrCodes = map[int]bool{-32603: true, -32005: true, -32004: true, -32014: true, -32016: true}
+
+var _ SolanaChain = (*ClientPool)(nil)
+
+var ErrNoEndpoint = errors.New("no rpc endpoint configured")
+
+// EndpointStatus 节点的健康状态，Lag 为落后于所有节点中最高 confirmed slot 的数量
+type EndpointStatus struct {
+	Url       string    `json:"url"`
+	Active    bool      `json:"active"`
+	Healthy   bool      `json:"healthy"`
+	Slot      uint64    `json:"slot"`
+	Lag       uint64    `json:"lag"`
+	Failures  uint64    `json:"failures"`
+	LastError string    `json:"last_error"`
+	LastCheck time.Time `json:"last_check"`
+}
+
+type endpoint struct {
+	url    string
+	chain  SolanaChain
+	status EndpointStatus
+}
+
+// ClientPool 多个 RPC 节点组成的连接池，实现 SolanaChain 接口。
+// 请求发往当前活跃节点，网络错误、HTTP 5xx 和节点状态类错误时标记该节点不健康，按指数退避切换到下一个节点重试；
+// 交易被拒绝等确定性的 RPC 错误直接返回。
+// 定时健康检查按照 slot 落后程度给节点打分，活跃节点落后超过 maxSlotLag 时切换到落后最少的节点
+type ClientPool struct {
+	mu            sync.RWMutex
+	ctx           context.Context
+	endpoints     []*endpoint
+	active        int
+	maxSlotLag    uint64
+	retryStrategy retry.Strategy
+}
+
+func NewClientPool(urls []string, maxSlotLag uint64) (*ClientPool, error) {
+	var endpoints []*endpoint
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		client, err := NewSolanaClient(url)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &endpoint{url: url, chain: client})
+	}
+	return newClientPool(endpoints, maxSlotLag)
+}
+
+func newClientPool(endpoints []*endpoint, maxSlotLag uint64) (*ClientPool, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	for _, e := range endpoints {
+		e.status = EndpointStatus{Url: e.url, Healthy: true}
+	}
+	return &ClientPool{
+		ctx:           context.Background(),
+		endpoints:     endpoints,
+		maxSlotLag:    maxSlotLag,
+		retryStrategy: &retry.ExponentialStrategy{Min: 200 * time.Millisecond, Max: 5 * time.Second, MaxJitter: 250 * time.Millisecond},
+	}, nil
+}
+
+// Start 后台定时做健康检查，ctx 取消后退出，之后的请求不再切换节点重试
+func (p *ClientPool) Start(ctx context.Context) {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+	p.CheckHealth()
+	go func() {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.CheckHealth()
+			}
+		}
+	}()
+}
+
+// CheckHealth 查询每个节点 confirmed 级别的 slot，计算落后程度并重新选择活跃节点
+func (p *ClientPool) CheckHealth() {
+	p.mu.RLock()
+	endpoints := append([]*endpoint(nil), p.endpoints...)
+	p.mu.RUnlock()
+
+	slots := make([]uint64, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+			slots[i], errs[i] = e.chain.GetCurrentSlot(rpc.CommitmentConfirmed)
+		}(i, e)
+	}
+	wg.Wait()
+
+	var bestSlot uint64
+	for i := range endpoints {
+		if errs[i] == nil && slots[i] > bestSlot {
+			bestSlot = slots[i]
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for i, e := range endpoints {
+		e.status.LastCheck = now
+		if errs[i] != nil {
+			e.status.Healthy = false
+			e.status.Failures++
+			e.status.LastError = errs[i].Error()
+			continue
+		}
+		e.status.Slot = slots[i]
+		e.status.Lag = bestSlot - slots[i]
+		e.status.Healthy = e.status.Lag <= p.maxSlotLag
+		e.status.LastError = ""
+	}
+
+	current := p.endpoints[p.active]
+	if current.status.Healthy {
+		return
+	}
+	best := -1
+	for i, e := range p.endpoints {
+		if e.status.Healthy && (best < 0 || e.status.Lag < p.endpoints[best].status.Lag) {
+			best = i
+		}
+	}
+	if best >= 0 && best != p.active {
+		log.Warn("switch rpc endpoint", "from", current.url, "to", p.endpoints[best].url, "lag", current.status.Lag)
+		p.active = best
+	}
+}
+
+// Endpoints 返回所有节点的状态，供 metrics 接口展示
+func (p *ClientPool) Endpoints() []EndpointStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for i, e := range p.endpoints {
+		status := e.status
+		status.Active = i == p.active
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (p *ClientPool) activeEndpoint() *endpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.endpoints[p.active]
+}
+
+// markFailed 请求失败后把节点标记为不健康，并切换到下一个健康节点，没有健康节点时按顺序轮换
+func (p *ClientPool) markFailed(failed *endpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failed.status.Healthy = false
+	failed.status.Failures++
+	failed.status.LastError = err.Error()
+	if p.endpoints[p.active] != failed {
+		return
+	}
+	next := (p.active + 1) % len(p.endpoints)
+	for step := 1; step < len(p.endpoints); step++ {
+		if candidate := (p.active + step) % len(p.endpoints); p.endpoints[candidate].status.Healthy {
+			next = candidate
+			break
+		}
+	}
+	if next != p.active {
+		log.Warn("rpc endpoint failed, failover", "from", failed.url, "to", p.endpoints[next].url, "err", err)
+	}
+	p.active = next
+}
+
+// poolCall 在活跃节点上执行请求，节点不可用时标记失败并切换节点，按指数退避重试；其他错误是链上确定的结果，不重试直接返回
+func poolCall[T any](p *ClientPool, op func(chain SolanaChain) (T, error)) (T, error) {
+	p.mu.RLock()
+	ctx := p.ctx
+	p.mu.RUnlock()
+
+	var resultErr error
+	result, err := retry.Do[T](ctx, poolMaxAttempts, p.retryStrategy, func() (T, error) {
+		e := p.activeEndpoint()
+		result, err := op(e.chain)
+		if err == nil {
+			return result, nil
+		}
+		if !isEndpointError(err) {
+			resultErr = err
+			return result, nil
+		}
+		p.markFailed(e, err)
+		return result, fmt.Errorf("%s: %w", e.url, err)
+	})
+	if resultErr != nil {
+		return result, resultErr
+	}
+	return result, err
+}
+
+// isEndpointError 判断错误是否由节点本身导致：网络错误、HTTP 5xx 和 429、节点状态类的 JSON-RPC 错误以及节点长期存储缺失的 slot
+func isEndpointError(err error) bool {
+	if errors.Is(err, ErrSlotUnavailable) {
+		return true
+	}
+	if errors.Is(err, ErrSlotSkipped) || errors.Is(err, ErrTransactionRejected) {
+		return false
+	}
+	var rpcErr *rpc.JsonRpcError
+	if errors.As(err, &rpcErr) {
+		return endpointErrCodes[rpcErr.Code]
+	}
+	// RPC 库把非 2xx 的响应包装成 "rpc: call error, err: get status code: 503, body: ..."
+	var statusCode int
+	if i := strings.Index(err.Error(), httpStatusErrPrefix); i >= 0 {
+		if _, scanErr := fmt.Sscanf(err.Error()[i+len(httpStatusErrPrefix):], "%d", &statusCode); scanErr == nil {
+			return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+		}
+	}
+	return true
+}
+
+func (p *ClientPool) GetCurrentSlot(commitment rpc.Commitment) (uint64, error) {
+	return poolCall(p, func(chain SolanaChain) (uint64, error) {
+		return chain.GetCurrentSlot(commitment)
+	})
+}
+
+func (p *ClientPool) GetBlock(slot uint64, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	return poolCall(p, func(chain SolanaChain) ([]TransactionDetail, error) {
+		return chain.GetBlock(slot, commitment)
+	})
+}
+
+func (p *ClientPool) GetTransaction(signature string, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	return poolCall(p, func(chain SolanaChain) ([]TransactionDetail, error) {
+		return chain.GetTransaction(signature, commitment)
+	})
+}
+
+func (p *ClientPool) GetSignaturesForAddress(address string, before string, limit int) ([]SignatureInfo, error) {
+	return poolCall(p, func(chain SolanaChain) ([]SignatureInfo, error) {
+		return chain.GetSignaturesForAddress(address, before, limit)
+	})
+}
+
+func (p *ClientPool) GetBlockHeader(slot uint64, commitment rpc.Commitment) (*BlockHeader, error) {
+	return poolCall(p, func(chain SolanaChain) (*BlockHeader, error) {
+		return chain.GetBlockHeader(slot, commitment)
+	})
+}
+
+func (p *ClientPool) GetBalance(address string) (string, error) {
+	return poolCall(p, func(chain SolanaChain) (string, error) {
+		return chain.GetBalance(address)
+	})
+}
+
+func (p *ClientPool) GetLamports(address string) (uint64, error) {
+	return poolCall(p, func(chain SolanaChain) (uint64, error) {
+		return chain.GetLamports(address)
+	})
+}
+
+func (p *ClientPool) GetMultipleLamports(addresses []string) ([]uint64, error) {
+	return poolCall(p, func(chain SolanaChain) ([]uint64, error) {
+		return chain.GetMultipleLamports(addresses)
+	})
+}
+
+func (p *ClientPool) GetTokenBalances(owner string) (map[string]*big.Int, error) {
+	return poolCall(p, func(chain SolanaChain) (map[string]*big.Int, error) {
+		return chain.GetTokenBalances(owner)
+	})
+}
+
+func (p *ClientPool) GetMultipleTokenAmounts(accounts []string) ([]*TokenAmount, error) {
+	return poolCall(p, func(chain SolanaChain) ([]*TokenAmount, error) {
+		return chain.GetMultipleTokenAmounts(accounts)
+	})
+}
+
+func (p *ClientPool) GetTokenAccounts(owner string) ([]string, error) {
+	return poolCall(p, func(chain SolanaChain) ([]string, error) {
+		return chain.GetTokenAccounts(owner)
+	})
+}
+
+func (p *ClientPool) GetRecentBlockHash() (*RecentBlockhash, error) {
+	return poolCall(p, func(chain SolanaChain) (*RecentBlockhash, error) {
+		return chain.GetRecentBlockHash()
+	})
+}
+
+func (p *ClientPool) GetLatestBlockHeight(commitment rpc.Commitment) (uint64, error) {
+	return poolCall(p, func(chain SolanaChain) (uint64, error) {
+		return chain.GetLatestBlockHeight(commitment)
+	})
+}
+
+func (p *ClientPool) GetSignatureStatuses(signatures []string) ([]*SignatureStatus, error) {
+	return poolCall(p, func(chain SolanaChain) ([]*SignatureStatus, error) {
+		return chain.GetSignatureStatuses(signatures)
+	})
+}
+
+// SendRawTransaction 同一笔签名交易重复广播不会重复上链，失败后可以安全地换节点重发
+func (p *ClientPool) SendRawTransaction(rawTx string) (string, error) {
+	return poolCall(p, func(chain SolanaChain) (string, error) {
+		return chain.SendRawTransaction(rawTx)
+	})
+}
+
+func (p *ClientPool) GetNonce(nonceAccount string) (string, error) {
+	return poolCall(p, func(chain SolanaChain) (string, error) {
+		return chain.GetNonce(nonceAccount)
+	})
+}
+
+func (p *ClientPool) GetMinRent(dataLen uint64) (string, error) {
+	return poolCall(p, func(chain SolanaChain) (string, error) {
+		return chain.GetMinRent(dataLen)
+	})
+}
@@ -0,0 +1,206 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+// downChain 在 down 为 true 时所有获取 slot 和区块的请求都返回 err，err 为空时返回 connection refused
+type downChain struct {
+	*FakeChain
+	down  atomic.Bool
+	err   error
+	calls atomic.Int64
+}
+
+func (c *downChain) downErr() error {
+	if c.err != nil {
+		return c.err
+	}
+	return errors.New("connection refused")
+}
+
+func (c *downChain) GetCurrentSlot(commitment rpc.Commitment) (uint64, error) {
+	c.calls.Add(1)
+	if c.down.Load() {
+		return 0, c.downErr()
+	}
+	return c.FakeChain.GetCurrentSlot(commitment)
+}
+
+func (c *downChain) GetBlock(slot uint64, commitment rpc.Commitment) ([]TransactionDetail, error) {
+	c.calls.Add(1)
+	if c.down.Load() {
+		return nil, c.downErr()
+	}
+	return c.FakeChain.GetBlock(slot, commitment)
+}
+
+func newTestPool(t *testing.T, chains ...*downChain) *ClientPool {
+	var endpoints []*endpoint
+	for i, chain := range chains {
+		endpoints = append(endpoints, &endpoint{url: string(rune('a' + i)), chain: chain})
+	}
+	pool, err := newClientPool(endpoints, 10)
+	require.NoError(t, err)
+	pool.retryStrategy = retry.Fixed(0)
+	return pool
+}
+
+func TestClientPool_Failover(t *testing.T) {
+	primary := &downChain{FakeChain: NewFakeChain()}
+	backup := &downChain{FakeChain: NewFakeChain()}
+	primary.AddBlock(100)
+	backup.AddBlock(100)
+	pool := newTestPool(t, primary, backup)
+
+	primary.down.Store(true)
+	slot, err := pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), slot)
+
+	endpoints := pool.Endpoints()
+	require.False(t, endpoints[0].Active)
+	require.False(t, endpoints[0].Healthy)
+	require.Equal(t, uint64(1), endpoints[0].Failures)
+	require.Equal(t, "connection refused", endpoints[0].LastError)
+	require.True(t, endpoints[1].Active)
+
+	// 所有节点都不可用时返回错误
+	backup.down.Store(true)
+	_, err = pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+	require.Error(t, err)
+}
+
+func TestClientPool_SkippedSlotNotRetried(t *testing.T) {
+	primary := &downChain{FakeChain: NewFakeChain()}
+	backup := &downChain{FakeChain: NewFakeChain()}
+	primary.AddBlock(100)
+	pool := newTestPool(t, primary, backup)
+
+	_, err := pool.GetBlock(99, rpc.CommitmentConfirmed)
+	require.True(t, errors.Is(err, ErrSlotSkipped))
+	require.Equal(t, int64(1), primary.calls.Load())
+	require.Equal(t, int64(0), backup.calls.Load())
+	require.True(t, pool.Endpoints()[0].Active)
+}
+
+func TestClientPool_SlotUnavailableFailover(t *testing.T) {
+	primary := &downChain{FakeChain: NewFakeChain(), err: fmt.Errorf("%w: Slot 99 missing in long-term storage", ErrSlotUnavailable)}
+	backup := &downChain{FakeChain: NewFakeChain()}
+	primary.AddBlock(100)
+	backup.AddBlock(99)
+	backup.AddBlock(100)
+	pool := newTestPool(t, primary, backup)
+
+	// 节点的长期存储缺失时换节点重试，不当成跳过的 slot
+	primary.down.Store(true)
+	_, err := pool.GetBlock(99, rpc.CommitmentConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), primary.calls.Load())
+	require.True(t, pool.Endpoints()[1].Active)
+}
+
+func TestClientPool_DeterministicErrorNotRetried(t *testing.T) {
+	for name, err := range map[string]error{
+		"rejected":       fmt.Errorf("%w: %w", ErrTransactionRejected, &rpc.JsonRpcError{Code: -32002, Message: "Transaction simulation failed"}),
+		"invalid params": &rpc.JsonRpcError{Code: -32602, Message: "Invalid params"},
+		"bad request":    errors.New("rpc: call error, err: get status code: 400, body: "),
+	} {
+		t.Run(name, func(t *testing.T) {
+			primary := &downChain{FakeChain: NewFakeChain(), err: err}
+			backup := &downChain{FakeChain: NewFakeChain()}
+			primary.AddBlock(100)
+			backup.AddBlock(100)
+			pool := newTestPool(t, primary, backup)
+
+			// 确定性的错误换节点也不会成功，不切换节点也不标记不健康
+			primary.down.Store(true)
+			_, callErr := pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+			require.ErrorIs(t, callErr, err)
+			require.Equal(t, int64(1), primary.calls.Load())
+			require.Equal(t, int64(0), backup.calls.Load())
+			require.True(t, pool.Endpoints()[0].Active)
+			require.True(t, pool.Endpoints()[0].Healthy)
+		})
+	}
+}
+
+func TestClientPool_EndpointErrorFailover(t *testing.T) {
+	for name, err := range map[string]error{
+		"server error":  errors.New("rpc: call error, err: get status code: 503, body: "),
+		"rate limited":  errors.New("rpc: call error, err: get status code: 429, body: "),
+		"node unhealth": &rpc.JsonRpcError{Code: -32005, Message: "Node is behind by 120 slots"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			primary := &downChain{FakeChain: NewFakeChain(), err: err}
+			backup := &downChain{FakeChain: NewFakeChain()}
+			primary.AddBlock(100)
+			backup.AddBlock(100)
+			pool := newTestPool(t, primary, backup)
+
+			primary.down.Store(true)
+			slot, callErr := pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+			require.NoError(t, callErr)
+			require.Equal(t, uint64(100), slot)
+			require.True(t, pool.Endpoints()[1].Active)
+		})
+	}
+}
+
+func TestClientPool_RetryWithBackoff(t *testing.T) {
+	primary := &downChain{FakeChain: NewFakeChain()}
+	backup := &downChain{FakeChain: NewFakeChain()}
+	pool := newTestPool(t, primary, backup)
+
+	// 所有节点都不可用时轮流重试，次数用完后返回最后一次的错误
+	primary.down.Store(true)
+	backup.down.Store(true)
+	_, err := pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+	var failedErr *retry.ErrFailedPermanently
+	require.ErrorAs(t, err, &failedErr)
+	require.ErrorContains(t, err, "connection refused")
+	require.Equal(t, int64(poolMaxAttempts), primary.calls.Load()+backup.calls.Load())
+	require.Equal(t, int64(poolMaxAttempts/2+1), primary.calls.Load())
+
+	// Start 的 ctx 取消后不再发请求
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+	cancel()
+	primary.calls.Store(0)
+	backup.calls.Store(0)
+	_, err = pool.GetCurrentSlot(rpc.CommitmentConfirmed)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(0), primary.calls.Load()+backup.calls.Load())
+}
+
+func TestClientPool_CheckHealthSlotLag(t *testing.T) {
+	lagging := &downChain{FakeChain: NewFakeChain()}
+	best := &downChain{FakeChain: NewFakeChain()}
+	lagging.AddBlock(100)
+	best.AddBlock(150)
+	pool := newTestPool(t, lagging, best)
+
+	pool.CheckHealth()
+	endpoints := pool.Endpoints()
+	require.Equal(t, uint64(50), endpoints[0].Lag)
+	require.False(t, endpoints[0].Healthy)
+	require.False(t, endpoints[0].Active)
+	require.Equal(t, uint64(0), endpoints[1].Lag)
+	require.True(t, endpoints[1].Active)
+
+	// 落后的节点追上之后恢复健康，但不会抢回活跃节点
+	lagging.AddBlock(150)
+	pool.CheckHealth()
+	endpoints = pool.Endpoints()
+	require.True(t, endpoints[0].Healthy)
+	require.True(t, endpoints[1].Active)
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/mr-tron/base58"
+)
+
+const (
+	tokenProgramId     = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	token2022ProgramId = "TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb"
+)
+
+// MaxMultipleAccounts getMultipleAccounts 一次最多查询的账户数量
+const MaxMultipleAccounts = 100
+
+// 计算免租金最低余额时使用的账户数据长度
+const (
+	SystemAccountSize = 0   // 只存 SOL 的系统账户没有数据
+	NonceAccountSize  = 80  // durable nonce 账户
+	TokenAccountSize  = 165 // 没有扩展的 token 账户
+)
+
+// TokenAccount token 账户对应的钱包地址（owner）和 mint
+type TokenAccount struct {
+	Owner string
+	Mint  string
+}
+
+// AssociatedTokenAccounts 推导 owner 持有 mint 的关联 token 账户（ATA）地址，不依赖账户是否还在链上。
+// 不查链无法知道 mint 属于哪个 token 程序，所以同时返回 Token 和 Token-2022 程序下的地址
+func AssociatedTokenAccounts(owner, mint string) ([]string, error) {
+	for _, address := range []string{owner, mint} {
+		if _, err := base58.Decode(address); err != nil {
+			return nil, fmt.Errorf("invalid address %s: %w", address, err)
+		}
+	}
+	ownerKey, mintKey := common.PublicKeyFromString(owner), common.PublicKeyFromString(mint)
+	accounts := make([]string, 0, 2)
+	for _, programId := range []common.PublicKey{common.TokenProgramID, common.Token2022ProgramID} {
+		account, _, err := common.FindProgramAddress([][]byte{ownerKey.Bytes(), programId.Bytes(), mintKey.Bytes()}, common.SPLAssociatedTokenAccountProgramID)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account.ToBase58())
+	}
+	return accounts, nil
+}
+
+// parseTokenAccounts 根据交易 meta 中的 preTokenBalances/postTokenBalances 解析出交易涉及的 token 账户，
+// accountIndex 对应 jsonParsed 编码下 message.accountKeys 的下标（已包含地址查找表加载的地址）
+func parseTokenAccounts(accountKeys []interface{}, meta *rpc.TransactionMeta) map[string]TokenAccount {
+	tokenAccounts := make(map[string]TokenAccount)
+	if meta == nil {
+		return tokenAccounts
+	}
+	balances := append(append([]rpc.TransactionMetaTokenBalance(nil), meta.PreTokenBalances...), meta.PostTokenBalances...)
+	for _, balance := range balances {
+		if balance.AccountIndex >= uint64(len(accountKeys)) {
+			continue
+		}
+		address := accountKeyAddress(accountKeys[balance.AccountIndex])
+		if address == "" {
+			continue
+		}
+		account := tokenAccounts[address]
+		if balance.Owner != "" {
+			account.Owner = balance.Owner
+		}
+		if balance.Mint != "" {
+			account.Mint = balance.Mint
+		}
+		tokenAccounts[address] = account
+	}
+	return tokenAccounts
+}
+
+// accountKeyAddress jsonParsed 编码下 accountKey 为 {"pubkey": ...} 对象，json 编码下为字符串
+func accountKeyAddress(accountKey interface{}) string {
+	switch key := accountKey.(type) {
+	case string:
+		return key
+	case map[string]interface{}:
+		pubkey, _ := key["pubkey"].(string)
+		return pubkey
+	}
+	return ""
+}
+
+// parseTokenAccountAmount 从 jsonParsed 编码的 token 账户数据中取出 mint 和余额
+func parseTokenAccountAmount(data interface{}) (string, *big.Int, bool) {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	parsed, _ := dataMap["parsed"].(map[string]interface{})
+	info, _ := parsed["info"].(map[string]interface{})
+	tokenAmount, _ := info["tokenAmount"].(map[string]interface{})
+	mint, _ := info["mint"].(string)
+	amountStr, _ := tokenAmount["amount"].(string)
+	if mint == "" || amountStr == "" {
+		return "", nil, false
+	}
+	amount, ok := new(big.Int).SetString(amountStr, 10)
+	if !ok {
+		return "", nil, false
+	}
+	return mint, amount, true
+}
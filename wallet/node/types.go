@@ -1,6 +1,10 @@
 package node
 
-import "math/big"
+import (
+	"math/big"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+)
 
 type TransactionDetail struct {
 	PreviousBlockhash string   `json:"previous_blockhash"`
@@ -9,7 +13,42 @@ type TransactionDetail struct {
 	TxHash            string   `json:"tx_hash"`
 	Destination       string   `json:"destination"`
 	Source            string   `json:"source"`
+	TokenAddress      string   `json:"token_address"` // spl-token 转账的 mint，SOL 转账为空
 	Lamports          *big.Int `json:"lamports"`
 	Type              string   `json:"type"`
 	Fee               *big.Int `json:"fee"`
+	Err               string   `json:"err"` // 交易执行失败时为 meta.err，成功为空
+	// InstructionIndex 转账所在指令的序号，顶层指令和它的内部指令按顺序展开后从 0 开始计数，和 TxHash 一起唯一确定一笔转账
+	InstructionIndex uint64 `json:"instruction_index"`
+}
+
+type BlockHeader struct {
+	Slot              uint64 `json:"slot"`
+	ParentSlot        uint64 `json:"parent_slot"`
+	BlockHash         string `json:"block_hash"`
+	PreviousBlockhash string `json:"previous_blockhash"`
+}
+
+type RecentBlockhash struct {
+	Blockhash            string `json:"blockhash"`
+	LastValidBlockHeight uint64 `json:"last_valid_block_height"`
+}
+
+type SignatureStatus struct {
+	Slot               uint64         `json:"slot"`
+	ConfirmationStatus rpc.Commitment `json:"confirmation_status"`
+	Err                interface{}    `json:"err"`
+}
+
+// TokenAmount token 账户的 mint 和余额
+type TokenAmount struct {
+	Mint   string   `json:"mint"`
+	Amount *big.Int `json:"amount"`
+}
+
+// SignatureInfo getSignaturesForAddress 返回的一笔交易签名
+type SignatureInfo struct {
+	Signature string      `json:"signature"`
+	Slot      uint64      `json:"slot"`
+	Err       interface{} `json:"err"`
 }
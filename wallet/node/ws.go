@@ -0,0 +1,238 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsRequestTimeout = 10 * time.Second
+	wsPingInterval   = 30 * time.Second
+)
+
+var ErrWsClosed = errors.New("websocket connection closed")
+
+// LogsNotification logsSubscribe 推送的一笔交易，Address 为订阅时 mentions 的地址
+type LogsNotification struct {
+	Address   string
+	Slot      uint64
+	Signature string
+	Err       interface{}
+}
+
+type wsRequest struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type wsMessage struct {
+	Id     *uint64           `json:"id"`
+	Result json.RawMessage   `json:"result"`
+	Error  *rpc.JsonRpcError `json:"error"`
+	Method string            `json:"method"`
+	Params *struct {
+		Subscription uint64          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+type logsResult struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value struct {
+		Signature string      `json:"signature"`
+		Err       interface{} `json:"err"`
+	} `json:"value"`
+}
+
+// WsClient Solana RPC 的 WebSocket 订阅连接，目前只支持 logsSubscribe。
+// 订阅请求只发送不等待响应，响应由读协程直接处理，不经过推送的 channel，大量订阅可以连续发出，
+// 推送积压时也不会卡住订阅响应。连接断开后 Done 被关闭，需要调用方重新建立连接并重新订阅
+type WsClient struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	nextId        uint64
+	pending       map[uint64]string
+	requested     map[string]bool
+	subscriptions map[uint64]string
+
+	notifications chan LogsNotification
+	done          chan struct{}
+	closeOnce     sync.Once
+	err           error
+}
+
+func DialWs(ctx context.Context, url string) (*WsClient, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &WsClient{
+		conn:          conn,
+		pending:       make(map[uint64]string),
+		requested:     make(map[string]bool),
+		subscriptions: make(map[uint64]string),
+		notifications: make(chan LogsNotification, 1024),
+		done:          make(chan struct{}),
+	}
+	go c.readLoop()
+	go c.pingLoop()
+	return c, nil
+}
+
+// LogsSubscribe 发送订阅提到 address 的交易的请求，commitment 为推送时交易需要达到的确认级别。
+// 不等待订阅结果，订阅失败时记录日志，之后可以重新订阅；已经订阅过的地址不重复发送
+func (c *WsClient) LogsSubscribe(address string, commitment rpc.Commitment) error {
+	c.mu.Lock()
+	if c.requested[address] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = address
+	c.requested[address] = true
+	c.mu.Unlock()
+
+	params := []interface{}{map[string]interface{}{"mentions": []string{address}}, map[string]interface{}{"commitment": commitment}}
+	if err := c.write(wsRequest{JsonRpc: "2.0", Id: id, Method: "logsSubscribe", Params: params}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		delete(c.requested, address)
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Subscribed address 是否已经发出订阅请求并且没有失败
+func (c *WsClient) Subscribed(address string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requested[address]
+}
+
+func (c *WsClient) Notifications() <-chan LogsNotification {
+	return c.notifications
+}
+
+func (c *WsClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 返回连接断开的原因
+func (c *WsClient) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *WsClient) Close() error {
+	c.fail(ErrWsClosed)
+	return nil
+}
+
+func (c *WsClient) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+func (c *WsClient) readLoop() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if msg.Id != nil {
+			c.handleResponse(*msg.Id, msg)
+			continue
+		}
+		if msg.Method != "logsNotification" || msg.Params == nil {
+			continue
+		}
+		var result logsResult
+		if err := json.Unmarshal(msg.Params.Result, &result); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		address := c.subscriptions[msg.Params.Subscription]
+		c.mu.Unlock()
+		notification := LogsNotification{
+			Address:   address,
+			Slot:      result.Context.Slot,
+			Signature: result.Value.Signature,
+			Err:       result.Value.Err,
+		}
+		select {
+		case c.notifications <- notification:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// handleResponse 订阅成功时记录订阅 id 对应的地址，读协程先处理响应再处理之后的消息，保证推送能找到地址；
+// 订阅失败时清除请求记录，调用方下次可以重新订阅
+func (c *WsClient) handleResponse(id uint64, msg wsMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	address, ok := c.pending[id]
+	if !ok {
+		return
+	}
+	delete(c.pending, id)
+	var subscription uint64
+	if msg.Error != nil || json.Unmarshal(msg.Result, &subscription) != nil {
+		log.Warn("logs subscribe fail", "address", address, "err", msg.Error)
+		delete(c.requested, address)
+		return
+	}
+	c.subscriptions[subscription] = address
+}
+
+func (c *WsClient) pingLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsRequestTimeout))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *WsClient) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWsClient_LogsSubscribe(t *testing.T) {
+	server := NewFakeWsServer()
+	defer server.Close()
+
+	client, err := DialWs(context.Background(), server.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	const address = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	require.NoError(t, client.LogsSubscribe(address, rpc.CommitmentConfirmed))
+	require.True(t, client.Subscribed(address))
+	require.Eventually(t, func() bool { return server.Subscribed(address) }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, server.NotifyLogs(address, 100, "signature-1", nil))
+	select {
+	case notification := <-client.Notifications():
+		require.Equal(t, LogsNotification{Address: address, Slot: 100, Signature: "signature-1"}, notification)
+	case <-time.After(time.Second):
+		t.Fatal("notification not received")
+	}
+
+	// 服务端断开连接后 Done 被关闭
+	server.CloseConnections()
+	select {
+	case <-client.Done():
+		require.Error(t, client.Err())
+	case <-time.After(time.Second):
+		t.Fatal("connection close not detected")
+	}
+}
+
+func TestWsClient_SubscribeWhileNotificationsPending(t *testing.T) {
+	server := NewFakeWsServer()
+	defer server.Close()
+
+	client, err := DialWs(context.Background(), server.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	const address = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	require.NoError(t, client.LogsSubscribe(address, rpc.CommitmentConfirmed))
+	require.Eventually(t, func() bool { return server.Subscribed(address) }, time.Second, 10*time.Millisecond)
+
+	// 推送积压到 channel 写满、读协程阻塞时继续订阅，订阅请求不等待响应，不会卡住调用方
+	for i := 0; i < cap(client.notifications)+10; i++ {
+		require.NoError(t, server.NotifyLogs(address, uint64(i), "signature", nil))
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- client.LogsSubscribe("4Nd1mBQtrMJVYVfKf2PJy9NZUZdTAsp7D4xWLs4gDB4T", rpc.CommitmentConfirmed)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("subscribe blocked by pending notifications")
+	}
+}
@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// nonceReserveTimeout 占用 nonce 的交易正常几秒内就会上链推进 nonce，超过这个时间还没有推进说明交易已经丢失或者
+// 占用后没有广播出去(进程退出)，占用失效。即使原交易之后上链，同一个 nonce 只有一笔交易能上链，没有上链的一方由跟踪任务处理
+const nonceReserveTimeout = 2 * time.Minute
+
+// txNonce 签名交易时使用的 nonce，NonceAccount 为地址自身时 Nonce 是最近的 blockhash，
+// 交易在 LastValidBlockHeight 之后过期；使用 durable nonce 账户时交易不会过期，LastValidBlockHeight 为 0
+type txNonce struct {
+	NonceAccount         string
+	Nonce                string
+	LastValidBlockHeight uint64
+}
+
+// durable 是否使用 durable nonce 账户签名
+func (n *txNonce) durable() bool {
+	return n.LastValidBlockHeight == 0
+}
+
+// queryTxNonce 开启 durable nonce 并且地址已经创建 nonce 账户时读取链上 nonce 并占用，否则使用最近的 blockhash。
+// 同一个 nonce 账户同时只能有一笔交易在途，上一笔交易还没有上链(链上 nonce 还是占用的值)并且占用还没有超时，
+// 或者被其他任务抢先占用时返回 nil，调用方跳过本轮发送
+func queryTxNonce(db *database.DB, client node.SolanaChain, durableNonce bool, address string) (*txNonce, error) {
+	if durableNonce {
+		nonceAccount, err := db.NonceAccounts.QueryNonceAccountByAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if nonceAccount != nil {
+			nonce, err := client.GetNonce(nonceAccount.NonceAddress)
+			if err != nil {
+				return nil, err
+			}
+			now := uint64(time.Now().Unix())
+			if nonce == nonceAccount.ReservedNonce && now < nonceAccount.ReservedAt+uint64(nonceReserveTimeout.Seconds()) {
+				log.Info("nonce account is in use by an unconfirmed transaction", "address", address, "nonceAddress", nonceAccount.NonceAddress)
+				return nil, nil
+			}
+			reserved, err := db.NonceAccounts.ReserveNonce(nonceAccount.NonceAddress, nonceAccount.ReservedNonce, nonce, now)
+			if err != nil {
+				return nil, err
+			}
+			if !reserved {
+				log.Info("nonce account reserved by another task", "address", address, "nonceAddress", nonceAccount.NonceAddress)
+				return nil, nil
+			}
+			return &txNonce{NonceAccount: nonceAccount.NonceAddress, Nonce: nonce}, nil
+		}
+	}
+
+	recentBlockhash, err := client.GetRecentBlockHash()
+	if err != nil {
+		return nil, err
+	}
+	return &txNonce{
+		NonceAccount:         address,
+		Nonce:                recentBlockhash.Blockhash,
+		LastValidBlockHeight: recentBlockhash.LastValidBlockHeight,
+	}, nil
+}
+
+// releaseTxNonce 签名失败或者节点拒绝交易时释放占用的 durable nonce
+func releaseTxNonce(db *database.DB, nonce *txNonce) error {
+	if !nonce.durable() {
+		return nil
+	}
+	if err := db.NonceAccounts.ReleaseNonce(nonce.NonceAccount, nonce.Nonce); err != nil {
+		log.Error("release nonce fail", "nonceAddress", nonce.NonceAccount, "err", err)
+		return err
+	}
+	return nil
+}
+
+// durableNonceAdvanced 检查使用 durable nonce 签名且还没有上链的交易，nonce 账户仍然是签名时的 nonce 时重新广播原交易并返回 false，
+// nonce 已经推进并且再次查询签名仍然不在链上时返回 true，原交易不可能再上链
+func durableNonceAdvanced(client node.SolanaChain, nonceAddress, usedNonce, hash, rawTx string) (bool, error) {
+	currentNonce, err := client.GetNonce(nonceAddress)
+	if err != nil {
+		log.Error("get nonce fail", "err", err)
+		return false, err
+	}
+	if currentNonce == usedNonce {
+		if _, err := client.SendRawTransaction(rawTx); err != nil {
+			log.Warn("rebroadcast durable nonce transaction fail", "hash", hash, "err", err)
+		}
+		return false, nil
+	}
+
+	// 查询签名状态和读取 nonce 之间交易可能刚好上链，nonce 推进后再查一次签名
+	statuses, err := client.GetSignatureStatuses([]string{hash})
+	if err != nil {
+		log.Error("get signature statuses fail", "err", err)
+		return false, err
+	}
+	return statuses[0] == nil, nil
+}
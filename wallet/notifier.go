@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/the-web3/sol-wallet/common/tasks"
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/notify"
+)
+
+const notifyBatchSize = 100
+
+// Notifier 把已到账的充值和已上链的提现推送给业务层，业务层确认后充值更新为 2(已通知)，提现更新为 4(已通知)。
+// 每次投递都记录到 notify_attempts，业务层不可达时等下一轮再投递，同一条通知被业务层拒绝 maxAttempts 次后写入 notify_dead_letters，等人工重新投递
+type Notifier struct {
+	db          *database.DB
+	client      notify.Client
+	chainId     uint
+	interval    time.Duration
+	maxAttempts int
+
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+}
+
+func NewNotifier(cfg *config.Config, db *database.DB, client notify.Client, shutdown context.CancelCauseFunc) (*Notifier, error) {
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &Notifier{
+		db:          db,
+		client:      client,
+		chainId:     cfg.Chain.ChainID,
+		interval:    cfg.Notify.Interval,
+		maxAttempts: cfg.Notify.MaxAttempts,
+
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in notifier: %w", err))
+		}},
+	}, nil
+}
+
+func (n *Notifier) Close() error {
+	n.resourceCancel()
+	if err := n.tasks.Wait(); err != nil {
+		return fmt.Errorf("failed to await notifier %w", err)
+	}
+	return nil
+}
+
+// Start 查询或更新数据库失败只记录日志，等下一轮重试
+func (n *Notifier) Start() error {
+	log.Info("start notifier......", "endpoint", n.client.Endpoint(), "interval", n.interval)
+	ticker := time.NewTicker(n.interval)
+	n.tasks.Go(func() error {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-n.resourceCtx.Done():
+				return nil
+			case <-ticker.C:
+				if err := n.notify(); err != nil {
+					log.Error("notify business fail", "err", err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+func (n *Notifier) notify() error {
+	depositList, err := n.db.Deposits.UnNotifiedDepositsList(notifyBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range depositList {
+		deposit := &depositList[i]
+		delivered, err := n.deliver(deposit.GUID, notify.DepositEvent(n.chainId, deposit))
+		if err != nil {
+			return err
+		}
+		if !delivered {
+			continue
+		}
+		if err := n.db.Deposits.MarkDepositNotified(deposit.GUID); err != nil {
+			return err
+		}
+	}
+
+	withdrawList, err := n.db.Withdraws.UnNotifiedWithdrawsList(notifyBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range withdrawList {
+		withdraw := &withdrawList[i]
+		delivered, err := n.deliver(withdraw.GUID, notify.WithdrawEvent(n.chainId, withdraw))
+		if err != nil {
+			return err
+		}
+		if !delivered {
+			continue
+		}
+		if err := n.db.Withdraws.MarkWithdrawNotified(withdraw.GUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver 投递一次并记录到 notify_attempts，返回 true 表示业务层已确认。业务层不可达时返回错误结束这一轮，
+// 剩下的记录留到下一轮投递；业务层拒绝确认累计 maxAttempts 次后写入死信并返回 false
+func (n *Notifier) deliver(bizGuid uuid.UUID, event *notify.Event) (bool, error) {
+	notifyErr := n.client.Notify(event)
+	attempt := &database.NotifyAttempts{
+		BizType:  event.Type,
+		BizGuid:  bizGuid,
+		Endpoint: n.client.Endpoint(),
+		Status:   database.NotifyAttemptAcked,
+	}
+	if notifyErr != nil {
+		log.Warn("notify business attempt fail", "type", event.Type, "guid", bizGuid, "err", notifyErr)
+		attempt.Status = database.NotifyAttemptFailed
+		// 业务方没有密钥只影响这一条通知，和业务层拒绝一样累计次数后进入死信
+		if errors.Is(notifyErr, notify.ErrNotAcked) || errors.Is(notifyErr, notify.ErrNoWebhookSecret) {
+			attempt.Status = database.NotifyAttemptRejected
+		}
+		attempt.ErrMsg = notifyErr.Error()
+	}
+	if err := n.db.NotifyAttempts.StoreNotifyAttempt(attempt); err != nil {
+		log.Error("store notify attempt fail", "guid", bizGuid, "err", err)
+	}
+	if notifyErr == nil {
+		return true, nil
+	}
+	if attempt.Status != database.NotifyAttemptRejected {
+		return false, fmt.Errorf("notify endpoint %s unavailable: %w", n.client.Endpoint(), notifyErr)
+	}
+
+	rejected, err := n.db.NotifyAttempts.CountRejectedAttempts(bizGuid)
+	if err != nil {
+		return false, err
+	}
+	if rejected < int64(n.maxAttempts) {
+		return false, nil
+	}
+	log.Error("notify business rejected, move to dead letter", "type", event.Type, "guid", bizGuid, "rejected", rejected, "err", notifyErr)
+	deadLetter := &database.NotifyDeadLetters{
+		EventId:  event.EventId,
+		BizType:  event.Type,
+		BizGuid:  bizGuid,
+		Endpoint: n.client.Endpoint(),
+		ErrMsg:   notifyErr.Error(),
+	}
+	if err := n.db.DeadLetters.StoreDeadLetter(deadLetter); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/notify"
+)
+
+// fakeNotifyClient 前 failures 次投递返回 err(默认业务层拒绝确认)，之后确认
+type fakeNotifyClient struct {
+	failures int
+	err      error
+	events   []*notify.Event
+}
+
+func (f *fakeNotifyClient) Endpoint() string {
+	return "fake://business"
+}
+
+func (f *fakeNotifyClient) Notify(event *notify.Event) error {
+	f.events = append(f.events, event)
+	if f.failures > 0 {
+		f.failures--
+		if f.err != nil {
+			return f.err
+		}
+		return fmt.Errorf("%w: invalid event", notify.ErrNotAcked)
+	}
+	return nil
+}
+
+func TestNotifier_AdvanceStatusOnAckAndDeadLetter(t *testing.T) {
+	db := newTestDB(t)
+	deposit := database.Deposits{
+		GUID:         uuid.New(),
+		BlockNumber:  big.NewInt(100),
+		Hash:         "deposit-hash",
+		FromAddress:  testExternalAddress,
+		ToAddress:    testUserAddress,
+		TokenAddress: "",
+		Fee:          big.NewInt(5000),
+		Amount:       big.NewInt(1_000_000),
+		Status:       1,
+		Timestamp:    uint64(time.Now().Unix()),
+	}
+	_, err := db.Deposits.StoreDeposits([]database.Deposits{deposit})
+	require.NoError(t, err)
+	withdraw := database.Withdraws{
+		GUID:         uuid.New(),
+		BlockNumber:  big.NewInt(101),
+		Hash:         "withdraw-hash",
+		FromAddress:  testHotAddress,
+		ToAddress:    testExternalAddress,
+		TokenAddress: "",
+		Fee:          big.NewInt(5000),
+		Amount:       big.NewInt(2_000_000),
+		Status:       2,
+		Timestamp:    uint64(time.Now().Unix()),
+	}
+	require.NoError(t, db.Withdraws.StoreWithdraws([]database.Withdraws{withdraw}, 1))
+
+	cfg := newTestConfig()
+	cfg.Notify.MaxAttempts = 2
+	client := &fakeNotifyClient{failures: 2}
+	notifier, err := NewNotifier(cfg, db, client, testShutdown(t))
+	require.NoError(t, err)
+
+	// 充值第一次被拒绝，不影响提现投递
+	require.NoError(t, notifier.notify())
+	require.Len(t, client.events, 2)
+	require.Equal(t, notify.EventWithdraw, client.events[1].Type)
+	require.Equal(t, "withdraw-hash", client.events[1].Hash)
+	notified, err := db.Withdraws.QueryWithdrawsByHash("withdraw-hash")
+	require.NoError(t, err)
+	require.Equal(t, uint8(4), notified.Status)
+	_, total := db.DeadLetters.ApiDeadLetterList(int(database.NotifyDeadLetterOpen), 1, 10, "asc")
+	require.Equal(t, int64(0), total)
+
+	// 下一轮累计拒绝次数用完写入死信
+	require.NoError(t, notifier.notify())
+	require.Len(t, client.events, 3)
+	deadLetters, total := db.DeadLetters.ApiDeadLetterList(int(database.NotifyDeadLetterOpen), 1, 10, "asc")
+	require.Equal(t, int64(1), total)
+	require.Equal(t, deposit.GUID, deadLetters[0].BizGuid)
+	require.Equal(t, client.events[0].EventId, deadLetters[0].EventId)
+	unNotified, err := db.Deposits.UnNotifiedDepositsList(10)
+	require.NoError(t, err)
+	require.Empty(t, unNotified)
+
+	// 死信未处理时不再自动投递，人工重新投递后下一轮推送
+	require.NoError(t, notifier.notify())
+	require.Len(t, client.events, 3)
+	require.NoError(t, db.DeadLetters.RequeueDeadLetter(deadLetters[0].GUID))
+	require.NoError(t, notifier.notify())
+	require.Len(t, client.events, 4)
+	require.Equal(t, notify.EventDeposit, client.events[3].Type)
+	require.Equal(t, client.events[0].EventId, client.events[3].EventId)
+
+	unNotified, err = db.Deposits.UnNotifiedDepositsList(10)
+	require.NoError(t, err)
+	require.Empty(t, unNotified)
+	unNotifiedWithdraws, err := db.Withdraws.UnNotifiedWithdrawsList(10)
+	require.NoError(t, err)
+	require.Empty(t, unNotifiedWithdraws)
+
+	attempts, err := db.NotifyAttempts.QueryNotifyAttempts(deposit.GUID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	require.Equal(t, database.NotifyAttemptAcked, attempts[2].Status)
+}
+
+func TestNotifier_StopRoundWhenEndpointUnavailable(t *testing.T) {
+	db := newTestDB(t)
+	var deposits []database.Deposits
+	for i := 0; i < 2; i++ {
+		deposits = append(deposits, database.Deposits{
+			GUID:         uuid.New(),
+			BlockNumber:  big.NewInt(100),
+			Hash:         fmt.Sprintf("deposit-hash-%d", i),
+			FromAddress:  testExternalAddress,
+			ToAddress:    testUserAddress,
+			TokenAddress: "",
+			Fee:          big.NewInt(5000),
+			Amount:       big.NewInt(1_000_000),
+			Status:       1,
+			Timestamp:    uint64(time.Now().Unix()),
+		})
+	}
+	_, err := db.Deposits.StoreDeposits(deposits)
+	require.NoError(t, err)
+
+	cfg := newTestConfig()
+	cfg.Notify.MaxAttempts = 1
+	client := &fakeNotifyClient{failures: 3, err: errors.New("connection refused")}
+	notifier, err := NewNotifier(cfg, db, client, testShutdown(t))
+	require.NoError(t, err)
+
+	// 业务层不可达时结束这一轮，不写死信，记录留到下一轮
+	for i := 1; i <= 3; i++ {
+		require.Error(t, notifier.notify())
+		require.Len(t, client.events, i)
+	}
+	_, total := db.DeadLetters.ApiDeadLetterList(-1, 1, 10, "asc")
+	require.Equal(t, int64(0), total)
+
+	require.NoError(t, notifier.notify())
+	require.Len(t, client.events, 5)
+	unNotified, err := db.Deposits.UnNotifiedDepositsList(10)
+	require.NoError(t, err)
+	require.Empty(t, unNotified)
+}
@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	gresty "github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/the-web3/sol-wallet/common/webhook"
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/proto/wallet"
+)
+
+const (
+	EventDeposit  = database.NotifyBizDeposit
+	EventWithdraw = database.NotifyBizWithdraw
+
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNotAcked 业务层收到了通知但是拒绝确认，只和这一条通知有关
+	ErrNotAcked = errors.New("notification is not acknowledged by business")
+	// ErrEndpointUnavailable 业务层服务不可用(5xx、429)，和具体通知无关
+	ErrEndpointUnavailable = errors.New("notify endpoint is unavailable")
+	// ErrNoWebhookSecret 通知所属的业务方不存在、已撤销或者没有配置 webhook 密钥，只和这一条通知有关
+	ErrNoWebhookSecret = errors.New("consumer has no webhook secret")
+)
+
+// Event 推送给业务层的充值或提现，Status 1 表示充值到账或提现成功。
+// EventId 由类型和记录 guid 生成，重试和人工重新投递时不变，业务方用来去重；重放校验使用每次投递的 nonce。
+// Consumer 为记录所属的业务方，webhook 用它的密钥签名，为空时使用配置的默认业务方
+type Event struct {
+	EventId      string `json:"event_id"`
+	Type         string `json:"type"`
+	Guid         string `json:"guid"`
+	ChainId      string `json:"chain_id"`
+	Hash         string `json:"hash"`
+	FromAddress  string `json:"from_address"`
+	ToAddress    string `json:"to_address"`
+	TokenAddress string `json:"token_address"`
+	Amount       string `json:"amount"`
+	Fee          string `json:"fee"`
+	Block        uint64 `json:"block"`
+	Status       uint32 `json:"status"`
+	Consumer     string `json:"-"`
+}
+
+func DepositEvent(chainId uint, deposit *database.Deposits) *Event {
+	return &Event{
+		EventId:      eventId(EventDeposit, deposit.GUID),
+		Type:         EventDeposit,
+		Guid:         deposit.GUID.String(),
+		ChainId:      strconv.FormatUint(uint64(chainId), 10),
+		Hash:         deposit.Hash,
+		FromAddress:  deposit.FromAddress,
+		ToAddress:    deposit.ToAddress,
+		TokenAddress: deposit.TokenAddress,
+		Amount:       deposit.Amount.String(),
+		Fee:          deposit.Fee.String(),
+		Block:        deposit.BlockNumber.Uint64(),
+		Status:       1,
+	}
+}
+
+func WithdrawEvent(chainId uint, withdraw *database.Withdraws) *Event {
+	return &Event{
+		EventId:      eventId(EventWithdraw, withdraw.GUID),
+		Type:         EventWithdraw,
+		Guid:         withdraw.GUID.String(),
+		ChainId:      strconv.FormatUint(uint64(chainId), 10),
+		Hash:         withdraw.Hash,
+		FromAddress:  withdraw.FromAddress,
+		ToAddress:    withdraw.ToAddress,
+		TokenAddress: withdraw.TokenAddress,
+		Amount:       withdraw.Amount.String(),
+		Fee:          withdraw.Fee.String(),
+		Block:        withdraw.BlockNumber.Uint64(),
+		Status:       1,
+		Consumer:     withdraw.Consumer,
+	}
+}
+
+func eventId(eventType string, guid uuid.UUID) string {
+	return uuid.NewSHA1(guid, []byte(eventType)).String()
+}
+
+// Client 投递通知，返回 nil 表示业务层已确认，ErrNotAcked 表示业务层拒绝了这条通知，其他错误表示业务层不可达
+type Client interface {
+	Notify(event *Event) error
+	Endpoint() string
+}
+
+// GrpcClient 调用业务层实现的 WalletService depositNotify/withdrawNotify，响应 Success 为 true 视为确认
+type GrpcClient struct {
+	url           string
+	consumerToken string
+	client        wallet.WalletServiceClient
+}
+
+func NewGrpcClient(url string, consumerToken string) (*GrpcClient, error) {
+	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &GrpcClient{
+		url:           url,
+		consumerToken: consumerToken,
+		client:        wallet.NewWalletServiceClient(conn),
+	}, nil
+}
+
+func (c *GrpcClient) Endpoint() string {
+	return c.url
+}
+
+func (c *GrpcClient) Notify(event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	switch event.Type {
+	case EventDeposit:
+		rep, err := c.client.DepositNotify(ctx, &wallet.DepositNotifyReq{
+			ConsumerToken: c.consumerToken,
+			ChainId:       event.ChainId,
+			Hash:          event.Hash,
+			FromAddress:   event.FromAddress,
+			ToAddress:     event.ToAddress,
+			Amount:        event.Amount,
+			Fee:           event.Fee,
+			Block:         event.Block,
+			Status:        event.Status,
+		})
+		if err != nil {
+			return err
+		}
+		if !rep.Success {
+			return fmt.Errorf("%w: code %s, msg %s", ErrNotAcked, rep.Code, rep.Msg)
+		}
+	case EventWithdraw:
+		rep, err := c.client.WithdrawNotify(ctx, &wallet.WithdrawNotifyReq{
+			ConsumerToken: c.consumerToken,
+			ChainId:       event.ChainId,
+			Hash:          event.Hash,
+			Status:        event.Status,
+		})
+		if err != nil {
+			return err
+		}
+		if !rep.Success {
+			return fmt.Errorf("%w: code %s, msg %s", ErrNotAcked, rep.Code, rep.Msg)
+		}
+	default:
+		return fmt.Errorf("unknown notify event type %q", event.Type)
+	}
+	return nil
+}
+
+// SecretLookup 按业务方名称查询 webhook 签名密钥
+type SecretLookup func(consumer string) (string, error)
+
+// ConsumerSecrets 从 consumers 表查询业务方的 webhook 密钥，业务方不存在、已撤销或者没有密钥时返回 ErrNoWebhookSecret
+func ConsumerSecrets(consumers database.ConsumersView) SecretLookup {
+	return func(consumer string) (string, error) {
+		record, err := consumers.QueryConsumerByName(consumer)
+		if err != nil {
+			return "", err
+		}
+		if record == nil || record.WebhookSecret == "" {
+			return "", fmt.Errorf("%w: %q", ErrNoWebhookSecret, consumer)
+		}
+		return record.WebhookSecret, nil
+	}
+}
+
+// WebhookClient 以 JSON POST 事件到业务层的 HTTP 地址，请求头带 event id、时间戳和业务方密钥的 HMAC 签名，返回 2xx 视为确认。
+// 每次投递都按事件所属的业务方查询密钥，轮换密钥或者撤销业务方后立即生效
+type WebhookClient struct {
+	url             string
+	defaultConsumer string
+	secrets         SecretLookup
+	client          *gresty.Client
+}
+
+func NewWebhookClient(url string, defaultConsumer string, secrets SecretLookup) *WebhookClient {
+	return &WebhookClient{
+		url:             url,
+		defaultConsumer: defaultConsumer,
+		secrets:         secrets,
+		client:          gresty.New().SetTimeout(requestTimeout),
+	}
+}
+
+func (c *WebhookClient) Endpoint() string {
+	return c.url
+}
+
+func (c *WebhookClient) Notify(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	consumer := event.Consumer
+	if consumer == "" {
+		consumer = c.defaultConsumer
+	}
+	secret, err := c.secrets(consumer)
+	if err != nil {
+		return err
+	}
+	nonce, err := webhook.GenerateNonce()
+	if err != nil {
+		return err
+	}
+	req := c.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(body)
+	webhook.SetHeaders(req.Header, secret, event.EventId, nonce, time.Now().Unix(), body)
+	resp, err := req.Post(c.url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= http.StatusInternalServerError || resp.StatusCode() == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: http status %d", ErrEndpointUnavailable, resp.StatusCode())
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("%w: http status %d", ErrNotAcked, resp.StatusCode())
+	}
+	return nil
+}
+
+// NewClient 按配置创建 gRPC 或 webhook 客户端，优先使用 gRPC，都没有配置时返回 nil。
+// webhook 必须能查到默认业务方的签名密钥
+func NewClient(cfg config.NotifyConfig, secrets SecretLookup) (Client, error) {
+	if cfg.GrpcUrl != "" {
+		return NewGrpcClient(cfg.GrpcUrl, cfg.ConsumerToken)
+	}
+	if cfg.WebhookUrl != "" {
+		if secrets == nil {
+			return nil, errors.New("webhook secret lookup is required for notify webhook")
+		}
+		if _, err := secrets(cfg.WebhookConsumer); err != nil {
+			return nil, fmt.Errorf("webhook consumer %q: %w", cfg.WebhookConsumer, err)
+		}
+		return NewWebhookClient(cfg.WebhookUrl, cfg.WebhookConsumer, secrets), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/common/webhook"
+)
+
+func TestWebhookClient_Notify(t *testing.T) {
+	var received Event
+	status := http.StatusOK
+	verifier := webhook.NewVerifier("secret-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifier.VerifyRequest(r)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+		require.Equal(t, received.EventId, r.Header.Get(webhook.HeaderEventId))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	secrets := map[string]string{"consumer-1": "secret-1"}
+	client := NewWebhookClient(server.URL, "consumer-1", func(consumer string) (string, error) {
+		if secret, ok := secrets[consumer]; ok {
+			return secret, nil
+		}
+		return "", ErrNoWebhookSecret
+	})
+	event := &Event{EventId: eventId(EventDeposit, uuid.New()), Type: EventDeposit, Guid: "guid-1", Hash: "hash-1", Amount: "100", Status: 1}
+	require.NoError(t, client.Notify(event))
+	require.Equal(t, *event, received)
+
+	// 重新投递同一个事件使用新的 nonce，不会被当成重放
+	require.NoError(t, client.Notify(event))
+	require.Equal(t, *event, received)
+
+	status = http.StatusBadRequest
+	err := client.Notify(event)
+	require.True(t, errors.Is(err, ErrNotAcked))
+
+	status = http.StatusInternalServerError
+	err = client.Notify(event)
+	require.True(t, errors.Is(err, ErrEndpointUnavailable))
+
+	// 每次投递时按事件所属的业务方查询密钥，轮换后立即使用新密钥
+	status = http.StatusOK
+	secrets["consumer-1"] = "secret-2"
+	verifier = webhook.NewVerifier("secret-2")
+	require.NoError(t, client.Notify(event))
+
+	withdrawEvent := &Event{EventId: eventId(EventWithdraw, uuid.New()), Type: EventWithdraw, Guid: "guid-2", Hash: "hash-2", Amount: "100", Status: 1, Consumer: "consumer-2"}
+	require.ErrorIs(t, client.Notify(withdrawEvent), ErrNoWebhookSecret)
+	secrets["consumer-2"] = "secret-3"
+	verifier = webhook.NewVerifier("secret-3")
+	require.NoError(t, client.Notify(withdrawEvent))
+	require.Equal(t, "guid-2", received.Guid)
+}
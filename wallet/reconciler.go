@@ -0,0 +1,278 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/the-web3/sol-wallet/common/tasks"
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// ReconcileSummary 最近一次对账的结果，通过 metrics 接口查看
+// Failed 为查询链上余额失败、本轮没有对账的地址数量
+type ReconcileSummary struct {
+	LastRun       uint64                          `json:"last_run"`
+	Addresses     int                             `json:"addresses"`
+	Failed        int                             `json:"failed"`
+	Discrepancies []database.BalanceDiscrepancies `json:"discrepancies"`
+}
+
+// Reconciler 定期查询用户、热钱包和冷钱包地址的链上 SOL 和 token 余额，和 balances 表的 balance + lock_balance 比较，
+// 不一致时记录到 balance_discrepancies。SOL 余额按 getMultipleAccounts 批量查询；token 余额按 tokens 表推导每个地址的关联 token 账户，
+// 同样按 getMultipleAccounts 批量查询，不再逐个地址查询 token 账户。查询失败的地址跳过，等下一轮再对账。
+// 默认只记录不修正，开启 autoCorrect 后连续两次对账差异不变才按链上余额修正可用余额，
+// 避免把扫链还没处理到的交易当成差异
+type Reconciler struct {
+	db          *database.DB
+	client      node.SolanaChain
+	interval    time.Duration
+	autoCorrect bool
+
+	mu      sync.Mutex
+	summary ReconcileSummary
+
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+}
+
+func NewReconciler(cfg *config.Config, db *database.DB, client node.SolanaChain, shutdown context.CancelCauseFunc) (*Reconciler, error) {
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &Reconciler{
+		db:             db,
+		client:         client,
+		interval:       cfg.Chain.ReconcileInterval,
+		autoCorrect:    cfg.Chain.ReconcileAutoCorrect,
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in reconciler: %w", err))
+		}},
+	}, nil
+}
+
+func (r *Reconciler) Close() error {
+	r.resourceCancel()
+	if err := r.tasks.Wait(); err != nil {
+		return fmt.Errorf("failed to await reconciler %w", err)
+	}
+	return nil
+}
+
+// Start 对账失败只记录日志，等下一轮重试
+func (r *Reconciler) Start() error {
+	log.Info("start reconciler......", "interval", r.interval, "autoCorrect", r.autoCorrect)
+	ticker := time.NewTicker(r.interval)
+	r.tasks.Go(func() error {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.resourceCtx.Done():
+				return nil
+			case <-ticker.C:
+				if err := r.reconcile(); err != nil {
+					log.Error("reconcile balances fail", "err", err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// Summary 返回最近一次对账的结果
+func (r *Reconciler) Summary() (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.summary, nil
+}
+
+func (r *Reconciler) reconcile() error {
+	addressList, err := r.db.Addresses.QueryAddressListByType(0, 1, 2)
+	if err != nil {
+		return err
+	}
+	balanceList, err := r.db.Balances.QueryBalanceList()
+	if err != nil {
+		return err
+	}
+	tokenList, err := r.db.Tokens.QueryTokenList()
+	if err != nil {
+		return err
+	}
+	walletBalances := make(map[string]map[string]*database.Balances)
+	for i := range balanceList {
+		balance := &balanceList[i]
+		if walletBalances[balance.Address] == nil {
+			walletBalances[balance.Address] = make(map[string]*database.Balances)
+		}
+		walletBalances[balance.Address][balance.TokenAddress] = balance
+	}
+
+	failed := 0
+	for start := 0; start < len(addressList); start += node.MaxMultipleAccounts {
+		if err := r.resourceCtx.Err(); err != nil {
+			return nil
+		}
+		batch := addressList[start:min(start+node.MaxMultipleAccounts, len(addressList))]
+		batchAddresses := make([]string, 0, len(batch))
+		for _, address := range batch {
+			batchAddresses = append(batchAddresses, address.Address)
+		}
+		lamportsList, err := r.client.GetMultipleLamports(batchAddresses)
+		if err != nil {
+			log.Error("query chain lamports fail, skip batch", "from", batchAddresses[0], "size", len(batchAddresses), "err", err)
+			failed += len(batch)
+			continue
+		}
+		tokenBalances, err := r.chainTokenBalances(batchAddresses, tokenList)
+		if err != nil {
+			log.Error("query chain token balances fail, skip batch", "from", batchAddresses[0], "size", len(batchAddresses), "err", err)
+			failed += len(batch)
+			continue
+		}
+		for i, address := range batch {
+			chainBalances := tokenBalances[i]
+			chainBalances[""] = new(big.Int).SetUint64(lamportsList[i])
+			if err := r.reconcileAddress(address, chainBalances, walletBalances[address.Address]); err != nil {
+				return err
+			}
+		}
+	}
+
+	discrepancyList, err := r.db.Discrepancies.QueryOpenDiscrepancies()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.summary = ReconcileSummary{
+		LastRun:       uint64(time.Now().Unix()),
+		Addresses:     len(addressList),
+		Failed:        failed,
+		Discrepancies: discrepancyList,
+	}
+	r.mu.Unlock()
+	log.Info("reconcile balances finished", "addresses", len(addressList), "failed", failed, "discrepancies", len(discrepancyList))
+	return nil
+}
+
+// chainTokenBalances 批量查询地址在 tokens 表中各个 token 的关联 token 账户余额，按 mint 汇总，顺序和 addresses 一致
+func (r *Reconciler) chainTokenBalances(addresses []string, tokenList []database.Tokens) ([]map[string]*big.Int, error) {
+	balances := make([]map[string]*big.Int, len(addresses))
+	var accounts []string
+	var owners []int
+	for i, address := range addresses {
+		balances[i] = make(map[string]*big.Int)
+		for _, account := range associatedTokenAccounts(address, tokenList) {
+			accounts = append(accounts, account)
+			owners = append(owners, i)
+		}
+	}
+	for start := 0; start < len(accounts); start += node.MaxMultipleAccounts {
+		end := min(start+node.MaxMultipleAccounts, len(accounts))
+		amounts, err := r.client.GetMultipleTokenAmounts(accounts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for i, amount := range amounts {
+			if amount == nil {
+				continue
+			}
+			ownerBalances := balances[owners[start+i]]
+			if balance, ok := ownerBalances[amount.Mint]; ok {
+				balance.Add(balance, amount.Amount)
+			} else {
+				ownerBalances[amount.Mint] = new(big.Int).Set(amount.Amount)
+			}
+		}
+	}
+	return balances, nil
+}
+
+// reconcileAddress 逐个币种比较地址的链上余额和钱包余额，chainBalances 中 SOL 的 token_address 为空
+func (r *Reconciler) reconcileAddress(address database.Addresses, chainBalances map[string]*big.Int, walletBalances map[string]*database.Balances) error {
+	tokenSet := make(map[string]bool)
+	for tokenAddress := range chainBalances {
+		tokenSet[tokenAddress] = true
+	}
+	for tokenAddress := range walletBalances {
+		tokenSet[tokenAddress] = true
+	}
+	for tokenAddress := range tokenSet {
+		chainBalance := chainBalances[tokenAddress]
+		if chainBalance == nil {
+			chainBalance = big.NewInt(0)
+		}
+		if err := r.compare(address, tokenAddress, chainBalance, walletBalances[tokenAddress]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) compare(address database.Addresses, tokenAddress string, chainBalance *big.Int, balance *database.Balances) error {
+	walletBalance := big.NewInt(0)
+	if balance != nil {
+		walletBalance = new(big.Int).Add(balance.Balance, balance.LockBalance)
+	}
+	if chainBalance.Cmp(walletBalance) == 0 {
+		return r.db.Discrepancies.ResolveDiscrepancy(address.Address, tokenAddress)
+	}
+
+	previous, err := r.db.Discrepancies.QueryOpenDiscrepancy(address.Address, tokenAddress)
+	if err != nil {
+		return err
+	}
+	discrepancy := &database.BalanceDiscrepancies{
+		Address:       address.Address,
+		TokenAddress:  tokenAddress,
+		AddressType:   address.AddressType,
+		ChainBalance:  chainBalance,
+		WalletBalance: walletBalance,
+	}
+	if err := r.db.Discrepancies.StoreDiscrepancy(discrepancy); err != nil {
+		return err
+	}
+	log.Warn("balance discrepancy found", "address", address.Address, "tokenAddress", tokenAddress, "chainBalance", chainBalance, "walletBalance", walletBalance)
+
+	if !r.autoCorrect || previous == nil || previous.ChainBalance.Cmp(chainBalance) != 0 || previous.WalletBalance.Cmp(walletBalance) != 0 {
+		return nil
+	}
+	return r.correct(discrepancy, balance)
+}
+
+// correct 按链上余额调整可用余额，锁定余额属于还在途的交易，不做调整
+func (r *Reconciler) correct(discrepancy *database.BalanceDiscrepancies, balance *database.Balances) error {
+	external := database.JournalAccount{Address: discrepancy.Address, Account: database.JournalAccountExternal}
+	available := database.JournalAccount{Address: discrepancy.Address, Account: database.JournalAccountBalance}
+	var journalList []database.BalanceJournal
+	if discrepancy.ChainBalance.Cmp(discrepancy.WalletBalance) > 0 {
+		diff := new(big.Int).Sub(discrepancy.ChainBalance, discrepancy.WalletBalance)
+		journalList = database.NewJournalEntries(uuid.New(), database.JournalReconcileAdjust, discrepancy.TokenAddress, diff, external, available)
+	} else {
+		diff := new(big.Int).Sub(discrepancy.WalletBalance, discrepancy.ChainBalance)
+		if balance != nil && balance.Balance.Cmp(diff) < 0 {
+			diff = new(big.Int).Set(balance.Balance)
+		}
+		if diff.Sign() == 0 {
+			log.Warn("discrepancy is in lock balance, skip auto correct", "address", discrepancy.Address, "tokenAddress", discrepancy.TokenAddress)
+			return nil
+		}
+		journalList = database.NewJournalEntries(uuid.New(), database.JournalReconcileAdjust, discrepancy.TokenAddress, diff, available, external)
+	}
+
+	log.Warn("auto correct balance discrepancy", "address", discrepancy.Address, "tokenAddress", discrepancy.TokenAddress, "chainBalance", discrepancy.ChainBalance, "walletBalance", discrepancy.WalletBalance)
+	return r.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Balances.PostJournal(journalList); err != nil {
+			return err
+		}
+		return tx.Discrepancies.UpdateDiscrepancyStatus(discrepancy.GUID, 2)
+	})
+}
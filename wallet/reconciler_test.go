@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestReconciler_RecordAndAutoCorrect(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 1_000_000, 5_000_000)
+
+	chain := node.NewFakeChain()
+	chain.SetBalance(testUserAddress, 1_000_000)
+	chain.SetBalance(testHotAddress, 4_000_000)
+
+	cfg := newTestConfig()
+	reconciler, err := NewReconciler(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, reconciler.reconcile())
+
+	discrepancy, err := db.Discrepancies.QueryOpenDiscrepancy(testHotAddress, "")
+	require.NoError(t, err)
+	require.NotNil(t, discrepancy)
+	require.Equal(t, big.NewInt(4_000_000), discrepancy.ChainBalance)
+	require.Equal(t, big.NewInt(5_000_000), discrepancy.WalletBalance)
+
+	userDiscrepancy, err := db.Discrepancies.QueryOpenDiscrepancy(testUserAddress, "")
+	require.NoError(t, err)
+	require.Nil(t, userDiscrepancy)
+
+	// 默认不修正余额
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(5_000_000), hotBalance.Balance)
+
+	// 开启自动修正后，差异连续两次不变才修正
+	cfg.Chain.ReconcileAutoCorrect = true
+	reconciler, err = NewReconciler(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, reconciler.reconcile())
+
+	hotBalance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(4_000_000), hotBalance.Balance)
+	discrepancy, err = db.Discrepancies.QueryOpenDiscrepancy(testHotAddress, "")
+	require.NoError(t, err)
+	require.Nil(t, discrepancy)
+}
+
+// tokenFailChain 批量查询 token 余额时返回错误
+type tokenFailChain struct {
+	*node.FakeChain
+}
+
+func (c *tokenFailChain) GetMultipleTokenAmounts(accounts []string) ([]*node.TokenAmount, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReconciler_SkipFailedBatch(t *testing.T) {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	db := newTestDB(t)
+	storeTestWallets(t, db, 1_000_000, 5_000_000)
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:  usdcMint,
+		Unit:          6,
+		TokenName:     "USDC",
+		CollectAmount: big.NewInt(1_000_000),
+	}))
+
+	chain := &tokenFailChain{FakeChain: node.NewFakeChain()}
+	chain.SetBalance(testHotAddress, 4_000_000)
+
+	reconciler, err := NewReconciler(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, reconciler.reconcile())
+
+	// token 余额查询失败时整批地址跳过，不记录差异，等下一轮再对账
+	discrepancy, err := db.Discrepancies.QueryOpenDiscrepancy(testHotAddress, "")
+	require.NoError(t, err)
+	require.Nil(t, discrepancy)
+
+	summary, err := reconciler.Summary()
+	require.NoError(t, err)
+	require.Equal(t, summary.(ReconcileSummary).Addresses, summary.(ReconcileSummary).Failed)
+}
+
+func TestReconciler_TokenBalancesFromAssociatedAccounts(t *testing.T) {
+	const usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	db := newTestDB(t)
+	storeTestWallets(t, db, 1_000_000, 5_000_000)
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:  usdcMint,
+		Unit:          6,
+		TokenName:     "USDC",
+		CollectAmount: big.NewInt(1_000_000),
+	}))
+
+	chain := node.NewFakeChain()
+	chain.SetBalance(testUserAddress, 1_000_000)
+	chain.SetBalance(testHotAddress, 5_000_000)
+	chain.SetTokenBalance(testUserAddress, usdcMint, big.NewInt(2_500_000))
+
+	reconciler, err := NewReconciler(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, reconciler.reconcile())
+
+	// 用户关联 token 账户里的余额没有入账，记录差异
+	discrepancy, err := db.Discrepancies.QueryOpenDiscrepancy(testUserAddress, usdcMint)
+	require.NoError(t, err)
+	require.NotNil(t, discrepancy)
+	require.Equal(t, big.NewInt(2_500_000), discrepancy.ChainBalance)
+	require.Equal(t, 0, discrepancy.WalletBalance.Sign())
+}
@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// maxReorgDepth 回溯区块表查找分叉点时最多检查的区块数
+const maxReorgDepth = 1000
+
+// errNoForkBlock 回溯到最早保存的区块仍然和链上不一致，不能确定分叉点，需要人工处理，不能清空账本重新扫描
+var errNoForkBlock = errors.New("no stored block matches the chain, reorg reaches the oldest stored block")
+
+// errFinalizedReorg finalized 的区块不会分叉，保存的 finalized 区块和链上不一致说明节点数据有问题，需要人工处理，不回滚已经到账的充值
+var errFinalizedReorg = errors.New("stored finalized block does not match the chain")
+
+// detectReorg 检查数据库中最新的区块是否仍在链上（区块哈希和父区块哈希都一致），
+// 不一致或者该 slot 已被跳过时沿 blocks 表往回查找，直到找到和链上一致的区块。
+// 返回值为分叉点（最后一个有效区块）的高度；没有发生回滚时返回 nil。
+// 回溯到最早保存的区块或者超过 maxReorgDepth 仍然没有找到分叉点时返回错误，不会回滚到最早保存的区块之前；
+// 回滚只发生在链上 finalized slot 之后，finalized 的区块不一致时返回 errFinalizedReorg
+func (d *Deposit) detectReorg() (*big.Int, error) {
+	block, err := d.db.Blocks.LatestBlocks()
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, nil
+	}
+	finalizedSlot, err := d.client.GetCurrentSlot(rpc.CommitmentFinalized)
+	if err != nil {
+		return nil, err
+	}
+
+	for depth := 0; depth < maxReorgDepth; depth++ {
+		orphaned := false
+		header, err := d.client.GetBlockHeader(block.Number.Uint64(), scanCommitment)
+		if err != nil {
+			if !errors.Is(err, node.ErrSlotSkipped) {
+				return nil, err
+			}
+			orphaned = true
+		} else if header.BlockHash != block.Hash || header.PreviousBlockhash != block.ParentHash {
+			orphaned = true
+		}
+
+		if !orphaned {
+			if depth == 0 {
+				return nil, nil
+			}
+			return block.Number, nil
+		}
+
+		if block.Number.Uint64() <= finalizedSlot {
+			log.Error("stored finalized block does not match the chain", "number", block.Number, "hash", block.Hash, "finalizedSlot", finalizedSlot)
+			return nil, errFinalizedReorg
+		}
+		log.Warn("detect orphaned block", "number", block.Number, "hash", block.Hash)
+		block, err = d.db.Blocks.QueryBlockBefore(block.Number.Uint64())
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, errNoForkBlock
+		}
+	}
+	return nil, fmt.Errorf("reorg deeper than %d blocks", maxReorgDepth)
+}
+
+// settleEntryTypes 交易类型对应的上链结算流水，上链失败时记 JournalRelease
+var settleEntryTypes = map[uint8]string{
+	2: database.JournalCollectionSettle,
+	3: database.JournalColdSettle,
+	4: database.JournalColdHotSettle,
+}
+
+// rollbackToBlock 删除分叉点之后的区块、跳过的 slot、确认中的充值和充值交易，冲正这些充值的入账；
+// 在这些区块上链或失败的提现、归集、热转冷和冷转热恢复为已发送并冲正结算或释放锁定的流水，游标退回到分叉点
+func (d *Deposit) rollbackToBlock(forkBlock *big.Int) error {
+	return d.db.Transaction(func(tx *database.DB) error {
+		orphanedDeposits, err := tx.Deposits.QueryDepositsAfterBlock(forkBlock.Uint64())
+		if err != nil {
+			return err
+		}
+
+		var journalList []database.BalanceJournal
+		for _, deposit := range orphanedDeposits {
+			if deposit.FromSubscription {
+				continue
+			}
+			// 按确认级别已经到账或者已经通知业务层的充值不删除也不冲正，告警人工处理
+			if deposit.Status != 0 {
+				log.Error("orphaned deposit already credited or notified, keep it for manual handling", "guid", deposit.GUID, "hash", deposit.Hash,
+					"block", deposit.BlockNumber, "to", deposit.ToAddress, "amount", deposit.Amount, "status", deposit.Status)
+				continue
+			}
+			log.Warn("rollback orphaned deposit", "hash", deposit.Hash, "block", deposit.BlockNumber, "to", deposit.ToAddress, "amount", deposit.Amount)
+			entries, err := reverseJournal(tx, deposit.GUID, database.JournalDeposit)
+			if err != nil {
+				return err
+			}
+			journalList = append(journalList, entries...)
+		}
+
+		orphanedWithdraws, err := tx.Withdraws.QuerySettledWithdrawsAfterBlock(forkBlock.Uint64())
+		if err != nil {
+			return err
+		}
+		for _, withdraw := range orphanedWithdraws {
+			log.Warn("rollback orphaned withdraw", "guid", withdraw.GUID, "hash", withdraw.Hash, "block", withdraw.BlockNumber, "status", withdraw.Status)
+			for _, entryType := range []string{database.JournalWithdrawSettle, database.JournalWithdrawFee, database.JournalRelease} {
+				entries, err := reverseJournal(tx, withdraw.GUID, entryType)
+				if err != nil {
+					return err
+				}
+				journalList = append(journalList, entries...)
+			}
+			if err := tx.Withdraws.ResetWithdrawToSent(withdraw.GUID); err != nil {
+				return err
+			}
+		}
+
+		orphanedTransactions, err := tx.Transactions.QuerySettledTransactionsAfterBlock(forkBlock.Uint64())
+		if err != nil {
+			return err
+		}
+		for _, transaction := range orphanedTransactions {
+			log.Warn("rollback orphaned transaction", "guid", transaction.GUID, "hash", transaction.Hash, "block", transaction.BlockNumber, "txType", transaction.TxType)
+			entryTypes := []string{settleEntryTypes[transaction.TxType], database.JournalRelease}
+			if transaction.TxType == 2 {
+				entryTypes = append(entryTypes, database.JournalCollectionRent)
+			}
+			for _, entryType := range entryTypes {
+				entries, err := reverseJournal(tx, transaction.GUID, entryType)
+				if err != nil {
+					return err
+				}
+				journalList = append(journalList, entries...)
+			}
+			if err := tx.Transactions.ResetTransactionToSent(transaction.GUID); err != nil {
+				return err
+			}
+			if transaction.TxType == 4 {
+				if err := tx.ColdHotRequests.ResetColdHotRequestByHash(transaction.Hash); err != nil {
+					return err
+				}
+			}
+		}
+
+		journalList, err = boundJournal(tx, journalList)
+		if err != nil {
+			return err
+		}
+		if len(journalList) > 0 {
+			if err := tx.Balances.PostJournal(journalList); err != nil {
+				return err
+			}
+		}
+		if err := tx.Deposits.DeleteDepositsAfterBlock(forkBlock.Uint64()); err != nil {
+			return err
+		}
+		if err := tx.Transactions.DeleteTransactionsAfterBlock(forkBlock.Uint64(), 0); err != nil {
+			return err
+		}
+		if err := tx.SkippedSlots.DeleteSkippedSlotsAfter(forkBlock.Uint64()); err != nil {
+			return err
+		}
+		// 游标退回到分叉点
+		if err := tx.SyncState.UpdateSyncState(database.DepositSyncWorker, forkBlock.Uint64()); err != nil {
+			return err
+		}
+		return tx.Blocks.DeleteBlocksAfter(forkBlock.Uint64())
+	})
+}
@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestReorg_RollbackWithdrawSettleDepthOne(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+
+	chain := node.NewFakeChain()
+	withdraw, err := NewWithdraw(newTestConfig(), db, chain, &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+
+	withdrawTx := node.TransactionDetail{
+		TxHash:      "fake-signature-1",
+		Source:      testHotAddress,
+		Destination: testExternalAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	}
+	chain.AddBlock(10)
+	chain.AddBlock(11, withdrawTx)
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+	requireWithdrawStatus(t, db, "fake-signature-1", 2)
+	requireBalance(t, db, testHotAddress, 4_000_000, 0)
+
+	// slot 11 分叉成不包含提现的块，提现恢复为已发送，结算冲正后金额回到锁定余额
+	chain.AddBlock(11)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	requireWithdrawStatus(t, db, "fake-signature-1", 1)
+	requireBalance(t, db, testHotAddress, 4_000_000, 1_000_000)
+
+	// 提现在新的分叉上链后重新结算
+	chain.AddBlock(12, withdrawTx)
+	require.NoError(t, deposit.processBatch())
+	requireWithdrawStatus(t, db, "fake-signature-1", 2)
+	requireBalance(t, db, testHotAddress, 4_000_000, 0)
+}
+
+func TestReorg_RollbackCollectionAndDepositDeep(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 2_000_000, 0)
+
+	collectionGuid := uuid.New()
+	require.NoError(t, db.Transactions.StoreTransactions([]database.Transactions{{
+		GUID:        collectionGuid,
+		BlockNumber: big.NewInt(1),
+		Hash:        "collection-signature",
+		FromAddress: testUserAddress,
+		ToAddress:   testHotAddress,
+		Fee:         big.NewInt(5000),
+		Amount:      big.NewInt(1_000_000),
+		Status:      0,
+		TxType:      2,
+		Timestamp:   uint64(time.Now().Unix()),
+	}}, 1))
+	require.NoError(t, db.Balances.PostJournal(lockJournal(collectionGuid, database.JournalCollectionLock, testUserAddress, "", big.NewInt(1_000_000))))
+
+	collectionTx := node.TransactionDetail{
+		TxHash:      "collection-signature",
+		Source:      testUserAddress,
+		Destination: testHotAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	}
+	chain := node.NewFakeChain()
+	chain.AddBlock(10)
+	chain.AddBlock(11, collectionTx)
+	chain.AddBlock(12, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(500_000),
+		Type:        "transfer",
+	})
+	chain.SetFinalizedSlot(10)
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+	requireBalance(t, db, testUserAddress, 1_500_000, 0)
+	requireBalance(t, db, testHotAddress, 1_000_000, 0)
+
+	// slot 11、12 都分叉，归集恢复为确认中并冲正结算，充值删除并冲正入账
+	chain.AddBlock(11)
+	chain.AddBlock(12)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	collection, err := db.Transactions.QueryTransactionByHash("collection-signature")
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), collection.Status)
+	requireBalance(t, db, testUserAddress, 1_000_000, 1_000_000)
+	requireBalance(t, db, testHotAddress, 0, 0)
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+
+	// 归集在新的分叉上链后重新结算
+	chain.AddBlock(13, collectionTx)
+	require.NoError(t, deposit.processBatch())
+	collection, err = db.Transactions.QueryTransactionByHash("collection-signature")
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), collection.Status)
+	requireBalance(t, db, testUserAddress, 1_000_000, 0)
+	requireBalance(t, db, testHotAddress, 1_000_000, 0)
+}
+
+func TestReorg_RollbackCollectedDeposit(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10)
+	chain.AddBlock(11, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	chain.SetFinalizedSlot(10)
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+	requireBalance(t, db, testUserAddress, 1_000_000, 0)
+
+	// 充值已经被归集到热钱包
+	collectionGuid := uuid.New()
+	require.NoError(t, db.Transactions.StoreTransactions([]database.Transactions{{
+		GUID:        collectionGuid,
+		BlockNumber: big.NewInt(1),
+		Hash:        "collection-signature",
+		FromAddress: testUserAddress,
+		ToAddress:   testHotAddress,
+		Fee:         big.NewInt(0),
+		Amount:      big.NewInt(1_000_000),
+		Status:      0,
+		TxType:      2,
+		Timestamp:   uint64(time.Now().Unix()),
+	}}, 1))
+	require.NoError(t, db.Balances.PostJournal(lockJournal(collectionGuid, database.JournalCollectionLock, testUserAddress, "", big.NewInt(1_000_000))))
+	collectionTx := node.TransactionDetail{
+		TxHash:      "collection-signature",
+		Source:      testUserAddress,
+		Destination: testHotAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	}
+	chain.AddBlock(12, collectionTx)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	requireBalance(t, db, testUserAddress, 0, 0)
+	requireBalance(t, db, testHotAddress, 1_000_000, 0)
+
+	// slot 11、12 分叉，充值的金额已经不在用户可用余额里，冲正不足的部分记到 external，回滚不会失败
+	chain.AddBlock(11)
+	chain.AddBlock(12)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Empty(t, deposits)
+	requireBalance(t, db, testUserAddress, 0, 1_000_000)
+	requireBalance(t, db, testHotAddress, 0, 0)
+
+	// 扫链继续，归集在新的分叉上链后重新结算
+	chain.AddBlock(13, collectionTx)
+	require.NoError(t, deposit.processBatch())
+	requireBalance(t, db, testUserAddress, 0, 0)
+	requireBalance(t, db, testHotAddress, 1_000_000, 0)
+}
+
+func TestReorg_KeepNotifiedDeposit(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 0)
+
+	chain := node.NewFakeChain()
+	chain.AddBlock(10)
+	chain.AddBlock(11, node.TransactionDetail{
+		TxHash:      "deposit-signature-1",
+		Source:      testExternalAddress,
+		Destination: testUserAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+	})
+	chain.SetFinalizedSlot(10)
+	cfg := newTestConfig()
+	cfg.Chain.Commitment = "confirmed"
+	deposit, err := NewDeposit(cfg, db, chain, testShutdown(t))
+	require.NoError(t, err)
+
+	// confirmed 级别到账并通知业务层
+	require.NoError(t, deposit.processBatch())
+	deposits, err := db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, uint8(1), deposits[0].Status)
+	require.NoError(t, db.Deposits.MarkDepositNotified(deposits[0].GUID))
+
+	// slot 11 分叉，已经通知的充值不删除也不冲正，其余回滚照常进行
+	chain.AddBlock(11)
+	chain.SetFinalizedSlot(10)
+	require.NoError(t, deposit.processBatch())
+	deposits, err = db.Deposits.QueryDepositsAfterBlock(0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, uint8(2), deposits[0].Status)
+	requireBalance(t, db, testUserAddress, 1_000_000, 0)
+	block, err := db.Blocks.LatestBlocks()
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(11), block.Number)
+	require.Equal(t, "fake-block-11-2", block.Hash)
+}
+
+func requireWithdrawStatus(t *testing.T, db *database.DB, hash string, status uint8) {
+	withdraw, err := db.Withdraws.QueryWithdrawsByHash(hash)
+	require.NoError(t, err)
+	require.NotNil(t, withdraw)
+	require.Equal(t, status, withdraw.Status)
+}
+
+func requireBalance(t *testing.T, db *database.DB, address string, balance, lockBalance int64) {
+	entry, err := db.Balances.QueryWalletBalanceByTokenAndAddress(address, "")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.Equal(t, big.NewInt(balance).String(), entry.Balance.String())
+	require.Equal(t, big.NewInt(lockBalance).String(), entry.LockBalance.String())
+}
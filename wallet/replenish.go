@@ -0,0 +1,203 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"github.com/mr-tron/base58"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+// Replenish 热钱包可用余额低于 hot_low_watermark，或者不够支付待发送的提现时，生成冷转热请求等待离线签名。
+// 补充到待发送提现加上 hot_high_watermark(没有配置时为 hot_low_watermark)，不超过冷钱包可用余额，每个币种同时只有一个未完成的请求
+func (cc *CollectionCold) Replenish() error {
+	tokenList, err := cc.db.Tokens.QueryTokenList()
+	if err != nil {
+		log.Error("query token list fail", "err", err)
+		return err
+	}
+	hotWallet, err := cc.db.Addresses.QueryHotWalletInfo()
+	if err != nil {
+		log.Error("query hot wallet info fail", "err", err)
+		return err
+	}
+	coldWallet, err := cc.db.Addresses.QueryColdWalletInfo()
+	if err != nil {
+		log.Error("query cold wallet info fail", "err", err)
+		return err
+	}
+	unSendList, err := cc.db.Withdraws.UnSendWithdrawsList()
+	if err != nil {
+		log.Error("query unsend withdraws fail", "err", err)
+		return err
+	}
+	pendingWithdraws := make(map[string]*big.Int)
+	for _, withdraw := range unSendList {
+		if withdraw.FromAddress != hotWallet.Address {
+			continue
+		}
+		if pendingWithdraws[withdraw.TokenAddress] == nil {
+			pendingWithdraws[withdraw.TokenAddress] = big.NewInt(0)
+		}
+		pendingWithdraws[withdraw.TokenAddress].Add(pendingWithdraws[withdraw.TokenAddress], withdraw.Amount)
+	}
+
+	for _, token := range tokenList {
+		pending := pendingWithdraws[token.TokenAddress]
+		if pending == nil {
+			pending = big.NewInt(0)
+		}
+		hotBalance, err := cc.availableBalance(hotWallet.Address, token.TokenAddress)
+		if err != nil {
+			return err
+		}
+
+		var reason string
+		if pending.Cmp(hotBalance) > 0 {
+			reason = database.ColdHotReasonPendingWithdraws
+		} else if token.HotLowWatermark.Sign() > 0 && hotBalance.Cmp(token.HotLowWatermark) < 0 {
+			reason = database.ColdHotReasonLowWatermark
+		} else {
+			continue
+		}
+
+		openRequest, err := cc.db.ColdHotRequests.QueryOpenColdHotRequest(token.TokenAddress)
+		if err != nil {
+			log.Error("query open cold to hot request fail", "err", err)
+			return err
+		}
+		if openRequest != nil {
+			continue
+		}
+
+		target := token.HotHighWatermark
+		if target.Sign() == 0 {
+			target = token.HotLowWatermark
+		}
+		amount := new(big.Int).Add(pending, target)
+		amount.Sub(amount, hotBalance)
+		coldBalance, err := cc.availableBalance(coldWallet.Address, token.TokenAddress)
+		if err != nil {
+			return err
+		}
+		if amount.Cmp(coldBalance) > 0 {
+			log.Warn("cold wallet balance not enough for replenishment", "tokenAddress", token.TokenAddress, "need", amount, "cold", coldBalance)
+			amount = coldBalance
+		}
+		if amount.Sign() <= 0 {
+			continue
+		}
+
+		request := &database.ColdHotRequests{
+			FromAddress:  coldWallet.Address,
+			ToAddress:    hotWallet.Address,
+			TokenAddress: token.TokenAddress,
+			Amount:       amount,
+			Reason:       reason,
+		}
+		if err := cc.db.ColdHotRequests.StoreColdHotRequest(request); err != nil {
+			log.Error("store cold to hot request fail", "err", err)
+			return err
+		}
+		log.Info("cold to hot request created, waiting for offline signing", "guid", request.GUID, "tokenAddress", token.TokenAddress, "amount", amount, "reason", reason)
+	}
+	return nil
+}
+
+func (cc *CollectionCold) availableBalance(address, tokenAddress string) (*big.Int, error) {
+	balance, err := cc.db.Balances.QueryWalletBalanceByTokenAndAddress(address, tokenAddress)
+	if err != nil {
+		log.Error("query wallet balance fail", "address", address, "err", err)
+		return nil, err
+	}
+	if balance == nil {
+		return big.NewInt(0), nil
+	}
+	return balance.Balance, nil
+}
+
+// ImportColdHotTx 导入离线签名的冷转热交易并广播，返回交易哈希。
+// rawTx 和签名机返回的原始交易一样为 base58 编码，必须由请求中的冷钱包地址签名
+func ImportColdHotTx(db *database.DB, client node.SolanaChain, guid uuid.UUID, rawTx string) (string, error) {
+	request, err := db.ColdHotRequests.QueryColdHotRequest(guid)
+	if err != nil {
+		return "", err
+	}
+	if request == nil {
+		return "", fmt.Errorf("cold to hot request %s not found", guid)
+	}
+	if request.Status != database.ColdHotAwaitingSign {
+		return "", fmt.Errorf("cold to hot request %s is not waiting for signature, status %d", guid, request.Status)
+	}
+	if err := verifyColdHotTx(request, rawTx); err != nil {
+		return "", err
+	}
+
+	txHash, err := client.SendRawTransaction(rawTx)
+	if err != nil {
+		log.Error("send raw transaction fail", "err", err)
+		return "", err
+	}
+	if err := recordColdHotTx(db, request, txHash); err != nil {
+		log.Error("record cold to hot transaction fail", "hash", txHash, "err", err)
+		return txHash, err
+	}
+	log.Info("cold to hot transaction sent", "guid", guid, "hash", txHash)
+	return txHash, nil
+}
+
+// verifyColdHotTx 检查导入的交易能解析，并且冷钱包地址在签名账户中
+func verifyColdHotTx(request *database.ColdHotRequests, rawTx string) error {
+	data, err := base58.Decode(rawTx)
+	if err != nil {
+		return fmt.Errorf("decode raw transaction: %w", err)
+	}
+	tx, err := types.TransactionDeserialize(data)
+	if err != nil {
+		return fmt.Errorf("deserialize raw transaction: %w", err)
+	}
+	signers := int(tx.Message.Header.NumRequireSignatures)
+	if signers > len(tx.Message.Accounts) {
+		signers = len(tx.Message.Accounts)
+	}
+	for _, account := range tx.Message.Accounts[:signers] {
+		if account.ToBase58() == request.FromAddress {
+			return nil
+		}
+	}
+	return fmt.Errorf("raw transaction is not signed by cold wallet %s", request.FromAddress)
+}
+
+// recordColdHotTx 记录广播后的冷转热交易并锁定冷钱包余额，扫到交易上链后和热转冷一样结算
+func recordColdHotTx(db *database.DB, request *database.ColdHotRequests, txHash string) error {
+	txGuid := uuid.New()
+	transaction := database.Transactions{
+		GUID:         txGuid,
+		BlockHash:    "",
+		BlockNumber:  big.NewInt(1),
+		Hash:         txHash,
+		FromAddress:  request.FromAddress,
+		ToAddress:    request.ToAddress,
+		TokenAddress: request.TokenAddress,
+		Fee:          big.NewInt(0),
+		Amount:       request.Amount,
+		Status:       0,
+		TxType:       4,
+		Timestamp:    uint64(time.Now().Unix()),
+	}
+	return db.Transaction(func(tx *database.DB) error {
+		if err := tx.Transactions.StoreTransactions([]database.Transactions{transaction}, 1); err != nil {
+			return err
+		}
+		if err := tx.Balances.PostJournal(lockJournal(txGuid, database.JournalColdHotLock, request.FromAddress, request.TokenAddress, request.Amount)); err != nil {
+			return err
+		}
+		return tx.ColdHotRequests.MarkColdHotRequestSent(request.GUID, txGuid, txHash)
+	})
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestReplenish_ColdToHotBelowLowWatermark(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 1_000_000_000)
+	require.NoError(t, db.Balances.PostJournal(database.NewJournalEntries(uuid.New(), database.JournalOpening, "", big.NewInt(10_000_000_000),
+		database.JournalAccount{Address: testColdAddress, Account: database.JournalAccountExternal},
+		database.JournalAccount{Address: testColdAddress, Account: database.JournalAccountBalance})))
+	require.NoError(t, db.Tokens.UpsertToken(&database.Tokens{
+		TokenAddress:     "",
+		Unit:             9,
+		TokenName:        "SOL",
+		CollectAmount:    big.NewInt(100_000_000),
+		HotHighWatermark: big.NewInt(3_000_000_000),
+		HotLowWatermark:  big.NewInt(2_000_000_000),
+	}))
+
+	chain := node.NewFakeChain()
+	collection, err := NewCollectionCold(newTestConfig(), db, chain, &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+
+	// 热钱包低于低水位，补充到高水位，未完成的请求不重复生成
+	require.NoError(t, collection.Replenish())
+	require.NoError(t, collection.Replenish())
+	requestList, err := db.ColdHotRequests.QueryColdHotRequestList(-1)
+	require.NoError(t, err)
+	require.Len(t, requestList, 1)
+	request := requestList[0]
+	require.Equal(t, testColdAddress, request.FromAddress)
+	require.Equal(t, testHotAddress, request.ToAddress)
+	require.Equal(t, big.NewInt(2_000_000_000), request.Amount)
+	require.Equal(t, database.ColdHotReasonLowWatermark, request.Reason)
+
+	// 离线签名的交易广播后锁定冷钱包余额，扫到上链后转入热钱包
+	require.NoError(t, recordColdHotTx(db, &request, "cold-hot-signature-1"))
+	coldBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testColdAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2_000_000_000), coldBalance.LockBalance)
+
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "cold-hot-signature-1",
+		Source:      testColdAddress,
+		Destination: testHotAddress,
+		Lamports:    big.NewInt(2_000_000_000),
+		Type:        "transfer",
+	})
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	transaction, err := db.Transactions.QueryTransactionByHash("cold-hot-signature-1")
+	require.NoError(t, err)
+	require.Equal(t, uint8(4), transaction.TxType)
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(3_000_000_000), hotBalance.Balance)
+	coldBalance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testColdAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(8_000_000_000), coldBalance.Balance)
+	require.Equal(t, 0, coldBalance.LockBalance.Sign())
+	confirmed, err := db.ColdHotRequests.QueryColdHotRequest(request.GUID)
+	require.NoError(t, err)
+	require.Equal(t, uint8(database.ColdHotConfirmed), confirmed.Status)
+}
+
+func TestReplenish_RejectInvalidRawTx(t *testing.T) {
+	request := &database.ColdHotRequests{FromAddress: "4wHd9tf4x4FkQ3JtgsMKyiEofEHSaZH5rYzfFKLvtESD"}
+	require.Error(t, verifyColdHotTx(request, "not-base58-0OIl"))
+	require.Error(t, verifyColdHotTx(request, "3yZe7d"))
+}
@@ -40,6 +40,11 @@ type TransactionReq struct {
 	Decimal      uint64 `json:"decimal"`
 	PrivateKey   string `json:"privateKey"`
 	MintAddress  string `json:"mintAddress"`
+	// FeePayer 不为空时由该地址支付手续费，发送方不需要持有 SOL
+	FeePayer           string `json:"feePayer,omitempty"`
+	FeePayerPrivateKey string `json:"feePayerPrivateKey,omitempty"`
+	// CloseAccount 转出全部 token 后关闭发送方的 token 账户，租金退回 FeePayer
+	CloseAccount bool `json:"closeAccount,omitempty"`
 }
 
 type TransactionRep struct {
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/config"
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/sign"
+)
+
+const (
+	testUserAddress     = "UserAddress1111111111111111111111111111111"
+	testHotAddress      = "HotWalletAddress111111111111111111111111111"
+	testColdAddress     = "ColdWalletAddress11111111111111111111111111"
+	testExternalAddress = "ExternalAddress111111111111111111111111111"
+	testNonceAddress    = "NonceAccountAddress111111111111111111111111"
+)
+
+// newTestDB 连接 SOL_WALLET_TEST_DB_* 配置的数据库，清空后重新执行 migrations，未配置时跳过测试
+func newTestDB(t *testing.T) *database.DB {
+	host := os.Getenv("SOL_WALLET_TEST_DB_HOST")
+	if host == "" {
+		t.Skip("SOL_WALLET_TEST_DB_HOST is not set, skip database backed test")
+	}
+	port, _ := strconv.Atoi(os.Getenv("SOL_WALLET_TEST_DB_PORT"))
+	db, err := database.NewDB(context.Background(), config.DBConfig{
+		Host:     host,
+		Port:     port,
+		Name:     os.Getenv("SOL_WALLET_TEST_DB_NAME"),
+		User:     os.Getenv("SOL_WALLET_TEST_DB_USER"),
+		Password: os.Getenv("SOL_WALLET_TEST_DB_PASSWORD"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	resetDir := t.TempDir()
+	resetSQL := []byte("DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public;")
+	require.NoError(t, os.WriteFile(filepath.Join(resetDir, "reset.sql"), resetSQL, 0o644))
+	require.NoError(t, db.ExecuteSQLMigration(resetDir))
+	require.NoError(t, db.ExecuteSQLMigration("../migrations"))
+	return db
+}
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Chain: config.ChainConfig{
+			StartingHeight: 10,
+			BlocksStep:     500,
+			Commitment:     "finalized",
+		},
+	}
+}
+
+func testShutdown(t *testing.T) context.CancelCauseFunc {
+	return func(cause error) {
+		t.Errorf("unexpected shutdown: %v", cause)
+	}
+}
+
+// storeTestWallets 写入用户、热钱包、冷钱包地址以及它们的 SOL 余额
+func storeTestWallets(t *testing.T, db *database.DB, userBalance, hotBalance uint64) {
+	wallets := []struct {
+		address     string
+		addressType uint8
+		balance     uint64
+	}{
+		{testUserAddress, 0, userBalance},
+		{testHotAddress, 1, hotBalance},
+		{testColdAddress, 2, 0},
+	}
+	var addressList []database.Addresses
+	var balanceList []database.Balances
+	for i, wallet := range wallets {
+		addressList = append(addressList, database.Addresses{
+			GUID:        uuid.New(),
+			UserUid:     fmt.Sprintf("uid-%d", i),
+			Address:     wallet.address,
+			AddressType: wallet.addressType,
+			PrivateKey:  "private-key-" + wallet.address,
+			PublicKey:   wallet.address,
+			Timestamp:   uint64(time.Now().Unix()),
+		})
+		balanceList = append(balanceList, database.Balances{
+			GUID:         uuid.New(),
+			Address:      wallet.address,
+			TokenAddress: "",
+			AddressType:  wallet.addressType,
+			Balance:      new(big.Int).SetUint64(wallet.balance),
+			LockBalance:  big.NewInt(0),
+			Timestamp:    uint64(time.Now().Unix()),
+		})
+	}
+	require.NoError(t, db.Addresses.StoreAddressess(addressList, uint64(len(addressList))))
+	require.NoError(t, db.Balances.StoreBalances(balanceList, uint64(len(balanceList))))
+}
+
+// fakeSigner 不连接签名机，直接返回由请求参数拼出来的原始交易
+type fakeSigner struct {
+	signed []*sign.TransactionReq
+}
+
+func (f *fakeSigner) GenerateAddress(uint64) (*sign.AccountInfoRep, error) {
+	return &sign.AccountInfoRep{Code: 2000}, nil
+}
+
+func (f *fakeSigner) PrepareAccount(req *sign.PrepareAccountReq) (*sign.PrepareAccountRep, error) {
+	return &sign.PrepareAccountRep{Code: 2000, RawTx: "prepare-" + req.FromAddress}, nil
+}
+
+func (f *fakeSigner) SignTransaction(req *sign.TransactionReq) (*sign.TransactionRep, error) {
+	f.signed = append(f.signed, req)
+	return &sign.TransactionRep{
+		Code:  2000,
+		RawTx: fmt.Sprintf("raw-%s-%s-%s-%s", req.FromAddress, req.ToAddress, req.Amount, req.Nonce),
+	}, nil
+}
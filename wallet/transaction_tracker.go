@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+// errTransactionExpired 归集和热转冷交易的 blockhash 过期或者 durable nonce 推进后仍然没有上链时记录的失败原因
+const errTransactionExpired = "TransactionExpired"
+
+// trackTransactions 检查已广播还没有上链的归集和热转冷交易。使用 durable nonce 签名并且 nonce 还没有推进时重新广播原交易；
+// 签名在链上查不到并且 blockhash 已经过期或者 nonce 已经推进时，原交易不可能再上链，交易标记失败，释放锁定余额并冲正
+// 归集发送时记的手续费，下一轮归集和热转冷按最新余额重新发送
+func (cc *CollectionCold) trackTransactions() error {
+	sentList, err := cc.db.Transactions.SentTransactionsList()
+	if err != nil {
+		log.Error("get sent transaction list fail", "err", err)
+		return err
+	}
+	if len(sentList) == 0 {
+		return nil
+	}
+
+	signatures := make([]string, 0, len(sentList))
+	for _, transaction := range sentList {
+		signatures = append(signatures, transaction.Hash)
+	}
+	statusList, err := cc.client.GetSignatureStatuses(signatures)
+	if err != nil {
+		log.Error("get signature statuses fail", "err", err)
+		return err
+	}
+	blockHeight, err := cc.client.GetLatestBlockHeight(rpc.CommitmentFinalized)
+	if err != nil {
+		log.Error("get latest block height fail", "err", err)
+		return err
+	}
+
+	var expiredList []database.Transactions
+	for i, transaction := range sentList {
+		// 已经上链的交易由扫链确认或者标记失败
+		if statusList[i] != nil {
+			continue
+		}
+		if transaction.LastValidBlockHeight == 0 {
+			advanced, err := durableNonceAdvanced(cc.client, transaction.NonceAccount, transaction.Nonce, transaction.Hash, transaction.TxSignHex)
+			if err != nil {
+				return err
+			}
+			if !advanced {
+				continue
+			}
+		} else if blockHeight <= transaction.LastValidBlockHeight {
+			continue
+		}
+		log.Warn("transaction expired without landing, release lock", "guid", transaction.GUID, "hash", transaction.Hash, "txType", transaction.TxType)
+		expiredList = append(expiredList, transaction)
+	}
+	if len(expiredList) == 0 {
+		return nil
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	if _, err := retry.Do[interface{}](cc.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
+		if err := cc.db.Transaction(func(tx *database.DB) error {
+			for _, transaction := range expiredList {
+				if err := tx.Transactions.MarkTransactionFailed(transaction.GUID, errTransactionExpired, nil); err != nil {
+					log.Error("mark transaction failed fail", "err", err)
+					return err
+				}
+				journalList, err := releaseJournal(tx, transaction.GUID, transaction.FromAddress, transaction.TokenAddress, transaction.Amount)
+				if err != nil {
+					return err
+				}
+				// 归集发送时记的手续费随交易一起作废
+				feeList, err := reverseJournal(tx, transaction.GUID, database.JournalCollectionFee)
+				if err != nil {
+					return err
+				}
+				journalList = append(journalList, feeList...)
+				if err := tx.Balances.PostJournal(journalList); err != nil {
+					log.Error("post balance journal fail", "err", err)
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Error("unable to persist batch", "err", err)
+			return nil, err
+		}
+		return nil, nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/ethereum/go-ethereum/log"
 
 	"github.com/the-web3/sol-wallet/common/tasks"
@@ -19,14 +22,14 @@ import (
 type Withdraw struct {
 	db             *database.DB
 	chainConf      *config.ChainConfig
-	client         node.SolanaClient
-	signClient     *sign.Client
+	client         node.SolanaChain
+	signClient     sign.SolSignClient
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
 	tasks          tasks.Group
 }
 
-func NewWithdraw(cfg *config.Config, db *database.DB, client node.SolanaClient, signCli *sign.Client, shutdown context.CancelCauseFunc) (*Withdraw, error) {
+func NewWithdraw(cfg *config.Config, db *database.DB, client node.SolanaChain, signCli sign.SolSignClient, shutdown context.CancelCauseFunc) (*Withdraw, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &Withdraw{
 		db:             db,
@@ -45,7 +48,7 @@ func (w *Withdraw) Close() error {
 	var result error
 	w.resourceCancel()
 	if err := w.tasks.Wait(); err != nil {
-		result = errors.Join(result, fmt.Errorf("failed to await deposit %w"), err)
+		result = errors.Join(result, fmt.Errorf("failed to await deposit %w", err))
 	}
 	return nil
 }
@@ -55,98 +58,156 @@ func (w *Withdraw) Start() error {
 	tickerWithdrawsWorker := time.NewTicker(time.Second * 5)
 	w.tasks.Go(func() error {
 		for range tickerWithdrawsWorker.C {
+			if err := w.sendWithdraws(); err != nil {
+				return err
+			}
+			if err := w.trackWithdraws(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+// sendWithdraws 签名并广播未发送的提现，锁定热钱包对应余额并把提现标记为已发送
+func (w *Withdraw) sendWithdraws() error {
+	withdrawList, err := w.db.Withdraws.UnSendWithdrawsList()
+	if err != nil {
+		log.Error("get un send withdraw list fail", "err", err)
+		return err
+	}
+
+	var returnWithdrawsList []database.Withdraws
+	var journalList []database.BalanceJournal
+	var attemptList []database.WithdrawAttempts
+	// 同一批发送的提现依次占用热钱包余额，按币种记录本批已经要锁定的金额
+	lockedAmounts := make(map[string]*big.Int)
+	for _, withdraw := range withdrawList {
+		hotWallet, err := w.db.Addresses.QueryHotWalletInfo()
+		if err != nil {
+			log.Error("query hot wallet info err", "err", err)
+			return err
+		}
+
+		hotWalletTokenBalance, err := w.db.Balances.QueryWalletBalanceByTokenAndAddress(hotWallet.Address, withdraw.TokenAddress)
+		if err != nil {
+			log.Error("query hot wallet balance err", "err", err)
+			return err
+		}
+		lockedAmount, ok := lockedAmounts[withdraw.TokenAddress]
+		if !ok {
+			lockedAmount = big.NewInt(0)
+			lockedAmounts[withdraw.TokenAddress] = lockedAmount
+		}
+		if hotWalletTokenBalance == nil || new(big.Int).Sub(hotWalletTokenBalance.Balance, lockedAmount).Cmp(withdraw.Amount) < 0 {
+			log.Info("hot wallet balance is not enough", "tokenAddress", withdraw.TokenAddress)
+			continue
+		}
+
+		attempt, err := w.signAndSend(&withdraw, hotWallet)
+		if err != nil {
+			return err
+		}
+		if attempt == nil {
+			continue
+		}
+		returnWithdrawsList = append(returnWithdrawsList, database.Withdraws{
+			GUID:                 withdraw.GUID,
+			Hash:                 attempt.Hash,
+			TxSignHex:            attempt.TxSignHex,
+			LastValidBlockHeight: attempt.LastValidBlockHeight,
+		})
+		attemptList = append(attemptList, *attempt)
+		lockedAmount.Add(lockedAmount, withdraw.Amount)
+		journalList = append(journalList, lockJournal(withdraw.GUID, database.JournalWithdrawLock, hotWallet.Address, withdraw.TokenAddress, withdraw.Amount)...)
+	}
+	if len(returnWithdrawsList) == 0 {
+		return nil
+	}
 
-			withdrawList, err := w.db.Withdraws.UnSendWithdrawsList()
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	if _, err := retry.Do[interface{}](w.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
+		if err := w.db.Transaction(func(tx *database.DB) error {
+			// 将转出去的热钱包余额锁定
+			err := tx.Balances.PostJournal(journalList)
 			if err != nil {
-				log.Error("get un send withdraw list fail", "err", err)
+				log.Error("mark withdraw send fail", "err", err)
 				return err
 			}
 
-			returnWithdrawsList := make([]database.Withdraws, len(withdrawList))
-			index := 0
-			var balanceList []database.Balances
-			for _, withdraw := range withdrawList {
-				hotWallet, err := w.db.Addresses.QueryHotWalletInfo()
-				if err != nil {
-					log.Error("query hot wallet info err", "err", err)
-					return err
-				}
-
-				hotWalletTokenBalance, err := w.db.Balances.QueryWalletBalanceByTokenAndAddress(hotWallet.Address, withdraw.TokenAddress)
-				if hotWalletTokenBalance.Balance.Cmp(withdraw.Amount) < 0 {
-					log.Info("hot wallet balance is not enough", "tokenAddress", withdraw.TokenAddress)
-					continue
-				}
-
-				recentBlockhash, err := w.client.GetRecentBlockHash()
-				if err != nil {
-					log.Error("query nonce by address fail", "err", err)
-					return err
-				}
-
-				txReq := &sign.TransactionReq{
-					FromAddress:  hotWalletTokenBalance.Address,
-					ToAddress:    withdraw.ToAddress,
-					Amount:       withdraw.Amount.String(),
-					NonceAccount: hotWalletTokenBalance.Address,
-					Nonce:        recentBlockhash,
-					Decimal:      9,
-					PrivateKey:   hotWallet.PrivateKey,
-					MintAddress:  withdraw.TokenAddress,
-				}
-
-				txRep, err := w.signClient.SignTransaction(txReq)
-				if err != nil {
-					log.Error("sign transaction fail", "err", err)
-					return err
-				}
-				if txRep.Code == 2000 {
-					// 发送交易到区块链网络
-					txHash, err := w.client.SendRawTransaction(txRep.RawTx)
-					if err != nil {
-						log.Error("send raw transaction fail", "err", err)
-						return err
-					}
-					returnWithdrawsList[index].Hash = txHash
-					returnWithdrawsList[index].GUID = withdraw.GUID
-					balanceItem := database.Balances{
-						Address:      hotWallet.Address,
-						TokenAddress: withdraw.TokenAddress,
-						LockBalance:  withdraw.Amount,
-					}
-					balanceList = append(balanceList, balanceItem)
-					index++
-				} else {
-					log.Error("sign service occur unknown err")
-					continue
-				}
+			err = tx.Withdraws.MarkWithdrawsToSend(returnWithdrawsList)
+			if err != nil {
+				log.Error("mark withdraw send fail", "err", err)
+				return err
 			}
-			retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
-			if _, err := retry.Do[interface{}](w.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
-				if err := w.db.Transaction(func(tx *database.DB) error {
-					// 将转出去的热钱包余额锁定
-					err = w.db.Balances.UpdateBalances(balanceList, false)
-					if err != nil {
-						log.Error("mark withdraw send fail", "err", err)
-						return err
-					}
-
-					err = w.db.Withdraws.MarkWithdrawsToSend(returnWithdrawsList)
-					if err != nil {
-						log.Error("mark withdraw send fail", "err", err)
-						return err
-					}
-					return nil
-				}); err != nil {
-					log.Error("unable to persist batch", "err", err)
-					return nil, err
-				}
-				return nil, nil
-			}); err != nil {
+
+			err = tx.WithdrawAttempts.StoreWithdrawAttempts(attemptList, uint64(len(attemptList)))
+			if err != nil {
+				log.Error("store withdraw attempts fail", "err", err)
 				return err
 			}
+			return nil
+		}); err != nil {
+			log.Error("unable to persist batch", "err", err)
+			return nil, err
 		}
-		return nil
-	})
+		return nil, nil
+	}); err != nil {
+		return err
+	}
 	return nil
 }
+
+// signAndSend 使用热钱包最新的 nonce 签名提现并广播，nonce 账户被在途交易占用、签名机返回错误码或节点拒绝交易时返回 nil
+func (w *Withdraw) signAndSend(withdraw *database.Withdraws, hotWallet *database.Addresses) (*database.WithdrawAttempts, error) {
+	nonce, err := queryTxNonce(w.db, w.client, w.chainConf.DurableNonce, hotWallet.Address)
+	if err != nil {
+		log.Error("query nonce by address fail", "err", err)
+		return nil, err
+	}
+	if nonce == nil {
+		return nil, nil
+	}
+
+	txReq := &sign.TransactionReq{
+		FromAddress:  hotWallet.Address,
+		ToAddress:    withdraw.ToAddress,
+		Amount:       withdraw.Amount.String(),
+		NonceAccount: nonce.NonceAccount,
+		Nonce:        nonce.Nonce,
+		Decimal:      9,
+		PrivateKey:   hotWallet.PrivateKey,
+		MintAddress:  withdraw.TokenAddress,
+	}
+
+	txRep, err := w.signClient.SignTransaction(txReq)
+	if err != nil {
+		log.Error("sign transaction fail", "err", err)
+		return nil, err
+	}
+	if txRep.Code != 2000 {
+		log.Error("sign service occur unknown err", "code", txRep.Code, "msg", txRep.Msg)
+		return nil, releaseTxNonce(w.db, nonce)
+	}
+
+	// 发送交易到区块链网络，节点拒绝的交易不记录，提现保持原状态等下一轮重新签名
+	txHash, err := w.client.SendRawTransaction(txRep.RawTx)
+	if err != nil {
+		log.Error("send raw transaction fail", "guid", withdraw.GUID, "err", err)
+		if errors.Is(err, node.ErrTransactionRejected) {
+			return nil, releaseTxNonce(w.db, nonce)
+		}
+		return nil, err
+	}
+	return &database.WithdrawAttempts{
+		GUID:                 uuid.New(),
+		WithdrawGUID:         withdraw.GUID,
+		Hash:                 txHash,
+		Nonce:                nonce.Nonce,
+		LastValidBlockHeight: nonce.LastValidBlockHeight,
+		Status:               0,
+		TxSignHex:            txRep.RawTx,
+		Timestamp:            uint64(time.Now().Unix()),
+	}, nil
+}
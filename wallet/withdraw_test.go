@@ -0,0 +1,277 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+)
+
+func TestWithdraw_SendWithdraws(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	withdraw, err := NewWithdraw(newTestConfig(), db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, testExternalAddress, signer.signed[0].ToAddress)
+	require.Equal(t, "1000000", signer.signed[0].Amount)
+	require.Len(t, chain.SentTransactions(), 1)
+
+	sent, err := db.Withdraws.QueryWithdrawsByHash("fake-signature-1")
+	require.NoError(t, err)
+	require.NotNil(t, sent)
+	require.Equal(t, uint8(1), sent.Status)
+
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(4_000_000), hotBalance.Balance)
+	require.Equal(t, big.NewInt(1_000_000), hotBalance.LockBalance)
+
+	// 已发送的提现不会被重复发送
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, chain.SentTransactions(), 1)
+}
+
+func TestWithdraw_SendWithdrawsDurableNonce(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+	require.NoError(t, db.NonceAccounts.StoreNonceAccounts([]database.NonceAccounts{{
+		GUID:         uuid.New(),
+		Address:      testHotAddress,
+		NonceAddress: testNonceAddress,
+		Timestamp:    uint64(time.Now().Unix()),
+	}}, 1))
+
+	chain := node.NewFakeChain()
+	chain.SetNonce(testNonceAddress, "durable-nonce-1")
+	signer := &fakeSigner{}
+	cfg := newTestConfig()
+	cfg.Chain.DurableNonce = true
+	withdraw, err := NewWithdraw(cfg, db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, testNonceAddress, signer.signed[0].NonceAccount)
+	require.Equal(t, "durable-nonce-1", signer.signed[0].Nonce)
+}
+
+func TestWithdraw_TrackWithdrawsResendExpired(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+
+	chain := node.NewFakeChain()
+	signer := &fakeSigner{}
+	withdraw, err := NewWithdraw(newTestConfig(), db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+
+	// blockhash 还没有过期，不重发
+	chain.AddBlock(100)
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 1)
+
+	// 超过 lastValidBlockHeight 仍未上链，使用新的 blockhash 重发
+	chain.AddBlock(151)
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 2)
+	require.NotEqual(t, signer.signed[0].Nonce, signer.signed[1].Nonce)
+
+	resent, err := db.Withdraws.QueryWithdrawsByHash("fake-signature-2")
+	require.NoError(t, err)
+	require.NotNil(t, resent)
+	require.Equal(t, uint8(1), resent.Status)
+
+	attempts, err := db.WithdrawAttempts.QueryWithdrawAttempts(resent.GUID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.Equal(t, map[string]uint8{"fake-signature-1": 2, "fake-signature-2": 0}, attemptStatuses(attempts))
+
+	// 重发的交易上链后只记录状态，不会再次重发
+	chain.AddBlock(152, node.TransactionDetail{TxHash: "fake-signature-2", Source: testHotAddress, Destination: testExternalAddress, Lamports: big.NewInt(1_000_000)})
+	chain.AddBlock(400)
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 2)
+	attempts, err = db.WithdrawAttempts.QueryWithdrawAttempts(resent.GUID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint8{"fake-signature-1": 2, "fake-signature-2": 1}, attemptStatuses(attempts))
+}
+
+func TestWithdraw_TrackWithdrawsDurableNonce(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+	require.NoError(t, db.NonceAccounts.StoreNonceAccounts([]database.NonceAccounts{{
+		GUID:         uuid.New(),
+		Address:      testHotAddress,
+		NonceAddress: testNonceAddress,
+		Timestamp:    uint64(time.Now().Unix()),
+	}}, 1))
+
+	chain := node.NewFakeChain()
+	chain.SetNonce(testNonceAddress, "durable-nonce-1")
+	cfg := newTestConfig()
+	cfg.Chain.DurableNonce = true
+	signer := &fakeSigner{}
+	withdraw, err := NewWithdraw(cfg, db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+
+	// nonce 没有推进，重新广播原交易
+	chain.AddBlock(100)
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 2)
+	require.Equal(t, chain.SentTransactions()[0], chain.SentTransactions()[1])
+
+	// nonce 已经推进而签名没有上链，使用新的 nonce 重新签名广播，锁定余额不变
+	chain.SetNonce(testNonceAddress, "durable-nonce-2")
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 3)
+	require.Equal(t, "durable-nonce-2", signer.signed[1].Nonce)
+
+	resent, err := db.Withdraws.QueryWithdrawsByHash("fake-signature-3")
+	require.NoError(t, err)
+	require.NotNil(t, resent)
+	require.Equal(t, uint8(1), resent.Status)
+
+	attempts, err := db.WithdrawAttempts.QueryWithdrawAttempts(resent.GUID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint8{"fake-signature-1": 2, "fake-signature-3": 0}, attemptStatuses(attempts))
+
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(4_000_000), hotBalance.Balance)
+	require.Equal(t, big.NewInt(1_000_000), hotBalance.LockBalance)
+}
+
+func TestWithdraw_SendWithdrawsDurableNonceBatch(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(2_000_000)))
+	require.NoError(t, db.NonceAccounts.StoreNonceAccounts([]database.NonceAccounts{{
+		GUID:         uuid.New(),
+		Address:      testHotAddress,
+		NonceAddress: testNonceAddress,
+		Timestamp:    uint64(time.Now().Unix()),
+	}}, 1))
+
+	chain := node.NewFakeChain()
+	chain.SetNonce(testNonceAddress, "durable-nonce-1")
+	signer := &fakeSigner{}
+	cfg := newTestConfig()
+	cfg.Chain.DurableNonce = true
+	withdraw, err := NewWithdraw(cfg, db, chain, signer, testShutdown(t))
+	require.NoError(t, err)
+
+	// 同一批的第二笔提现不能使用同一个 nonce，等第一笔上链推进 nonce 后再发送
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, signer.signed, 1)
+	require.Equal(t, "durable-nonce-1", signer.signed[0].Nonce)
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, signer.signed, 1)
+
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(4_000_000), hotBalance.Balance)
+	require.Equal(t, big.NewInt(1_000_000), hotBalance.LockBalance)
+
+	chain.AddBlock(100, node.TransactionDetail{TxHash: "fake-signature-1", Source: testHotAddress, Destination: testExternalAddress, Lamports: big.NewInt(1_000_000)})
+	chain.SetNonce(testNonceAddress, "durable-nonce-2")
+	require.NoError(t, withdraw.sendWithdraws())
+	require.Len(t, signer.signed, 2)
+	require.Equal(t, "durable-nonce-2", signer.signed[1].Nonce)
+	require.NotEqual(t, signer.signed[0].Amount, signer.signed[1].Amount)
+
+	hotBalance, err = db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2_000_000), hotBalance.Balance)
+	require.Equal(t, big.NewInt(3_000_000), hotBalance.LockBalance)
+}
+
+func attemptStatuses(attempts []database.WithdrawAttempts) map[string]uint8 {
+	statuses := make(map[string]uint8, len(attempts))
+	for _, attempt := range attempts {
+		statuses[attempt.Hash] = attempt.Status
+	}
+	return statuses
+}
+
+func TestWithdraw_FailedWithdrawReleasesLock(t *testing.T) {
+	db := newTestDB(t)
+	storeTestWallets(t, db, 0, 5_000_000)
+	require.NoError(t, db.Withdraws.SubmitWithdrawFromBusiness(testHotAddress, testExternalAddress, "", big.NewInt(1_000_000)))
+
+	chain := node.NewFakeChain()
+	withdraw, err := NewWithdraw(newTestConfig(), db, chain, &fakeSigner{}, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, withdraw.sendWithdraws())
+
+	chain.AddBlock(10, node.TransactionDetail{
+		TxHash:      "fake-signature-1",
+		Source:      testHotAddress,
+		Destination: testExternalAddress,
+		Lamports:    big.NewInt(1_000_000),
+		Type:        "transfer",
+		Fee:         big.NewInt(5000),
+		Err:         `{"InstructionError":[0,{"Custom":1}]}`,
+	})
+	deposit, err := NewDeposit(newTestConfig(), db, chain, testShutdown(t))
+	require.NoError(t, err)
+	require.NoError(t, deposit.processBatch())
+
+	failed, err := db.Withdraws.QueryWithdrawsByHash("fake-signature-1")
+	require.NoError(t, err)
+	require.Equal(t, uint8(6), failed.Status)
+	require.Equal(t, `{"InstructionError":[0,{"Custom":1}]}`, failed.ErrCode)
+
+	// 锁定余额退回，执行失败的交易仍然扣手续费
+	hotBalance, err := db.Balances.QueryWalletBalanceByTokenAndAddress(testHotAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(4_995_000), hotBalance.Balance)
+	require.Equal(t, 0, hotBalance.LockBalance.Sign())
+
+	// 失败的交易不再被当作待确认的提现重发
+	require.NoError(t, withdraw.trackWithdraws())
+	require.Len(t, chain.SentTransactions(), 1)
+}
+
+func TestWithdraw_SubmitWithdrawIdempotent(t *testing.T) {
+	db := newTestDB(t)
+
+	first, duplicate, err := db.Withdraws.SubmitWithdraw("consumer-a", "req-1", testHotAddress, testExternalAddress, "", big.NewInt(1_000_000))
+	require.NoError(t, err)
+	require.False(t, duplicate)
+
+	// 同一个业务方重复提交返回原来的提现
+	second, duplicate, err := db.Withdraws.SubmitWithdraw("consumer-a", "req-1", testHotAddress, testExternalAddress, "", big.NewInt(1_000_000))
+	require.NoError(t, err)
+	require.True(t, duplicate)
+	require.Equal(t, first.GUID, second.GUID)
+
+	_, _, err = db.Withdraws.SubmitWithdraw("consumer-a", "req-1", testHotAddress, testExternalAddress, "", big.NewInt(2_000_000))
+	require.ErrorIs(t, err, database.ErrWithdrawRequestConflict)
+
+	// 不同业务方可以使用相同的请求号
+	other, duplicate, err := db.Withdraws.SubmitWithdraw("consumer-b", "req-1", testHotAddress, testExternalAddress, "", big.NewInt(1_000_000))
+	require.NoError(t, err)
+	require.False(t, duplicate)
+	require.NotEqual(t, first.GUID, other.GUID)
+
+	unSent, err := db.Withdraws.UnSendWithdrawsList()
+	require.NoError(t, err)
+	require.Len(t, unSent, 2)
+}
@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/the-web3/sol-wallet/database"
+	"github.com/the-web3/sol-wallet/wallet/node"
+	"github.com/the-web3/sol-wallet/wallet/retry"
+)
+
+// trackWithdraws 检查已广播提现的签名状态，签名在链上查不到并且 finalized 区块高度已经超过 blockhash 的
+// lastValidBlockHeight 时，原交易不可能再上链，使用新的 blockhash 重新签名广播。
+// 使用 durable nonce 签名的交易不会过期，nonce 账户还是签名时的 nonce 时重新广播原交易，nonce 已经推进而签名没有上链时
+// 原交易不可能再上链，和 blockhash 过期一样使用新的 nonce 重新签名广播。没有记录签名的提现(历史上广播被拒绝)不查询状态，直接重新签名广播
+func (w *Withdraw) trackWithdraws() error {
+	sentList, err := w.db.Withdraws.SentWithdrawsList()
+	if err != nil {
+		log.Error("get sent withdraw list fail", "err", err)
+		return err
+	}
+	if len(sentList) == 0 {
+		return nil
+	}
+
+	signatures := make([]string, 0, len(sentList))
+	for _, withdraw := range sentList {
+		if withdraw.Hash != "" {
+			signatures = append(signatures, withdraw.Hash)
+		}
+	}
+	statuses := make(map[string]*node.SignatureStatus, len(signatures))
+	if len(signatures) > 0 {
+		statusList, err := w.client.GetSignatureStatuses(signatures)
+		if err != nil {
+			log.Error("get signature statuses fail", "err", err)
+			return err
+		}
+		for i, signature := range signatures {
+			statuses[signature] = statusList[i]
+		}
+	}
+	blockHeight, err := w.client.GetLatestBlockHeight(rpc.CommitmentFinalized)
+	if err != nil {
+		log.Error("get latest block height fail", "err", err)
+		return err
+	}
+
+	hotWallet, err := w.db.Addresses.QueryHotWalletInfo()
+	if err != nil {
+		log.Error("query hot wallet info err", "err", err)
+		return err
+	}
+
+	var landedHashes []string
+	var failedHashes []string
+	var expiredHashes []string
+	var resendWithdrawsList []database.Withdraws
+	var attemptList []database.WithdrawAttempts
+	for _, withdraw := range sentList {
+		if withdraw.Hash == "" {
+			log.Warn("sent withdraw has no signature, resend", "guid", withdraw.GUID)
+		} else {
+			if status := statuses[withdraw.Hash]; status != nil {
+				// 上链失败的提现由扫链标记失败并释放锁定余额
+				if status.Err != nil {
+					failedHashes = append(failedHashes, withdraw.Hash)
+				} else {
+					landedHashes = append(landedHashes, withdraw.Hash)
+				}
+				continue
+			}
+			if withdraw.LastValidBlockHeight == 0 {
+				if hotWallet == nil {
+					continue
+				}
+				advanced, err := w.durableNonceAdvanced(&withdraw, hotWallet)
+				if err != nil {
+					return err
+				}
+				if !advanced {
+					continue
+				}
+				log.Warn("withdraw durable nonce advanced without landing, resend", "guid", withdraw.GUID, "hash", withdraw.Hash)
+			} else {
+				if blockHeight <= withdraw.LastValidBlockHeight {
+					continue
+				}
+				log.Warn("withdraw blockhash expired, resend", "guid", withdraw.GUID, "hash", withdraw.Hash, "lastValidBlockHeight", withdraw.LastValidBlockHeight, "blockHeight", blockHeight)
+			}
+			expiredHashes = append(expiredHashes, withdraw.Hash)
+		}
+		if hotWallet == nil {
+			continue
+		}
+		attempt, err := w.signAndSend(&withdraw, hotWallet)
+		if err != nil {
+			return err
+		}
+		if attempt == nil {
+			continue
+		}
+		resendWithdrawsList = append(resendWithdrawsList, database.Withdraws{
+			GUID:                 withdraw.GUID,
+			Hash:                 attempt.Hash,
+			TxSignHex:            attempt.TxSignHex,
+			LastValidBlockHeight: attempt.LastValidBlockHeight,
+		})
+		attemptList = append(attemptList, *attempt)
+	}
+	if len(landedHashes) == 0 && len(failedHashes) == 0 && len(expiredHashes) == 0 && len(resendWithdrawsList) == 0 {
+		return nil
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	if _, err := retry.Do[interface{}](w.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
+		if err := w.db.Transaction(func(tx *database.DB) error {
+			for _, hash := range landedHashes {
+				if err := tx.WithdrawAttempts.UpdateWithdrawAttemptStatus(hash, 1); err != nil {
+					log.Error("update withdraw attempt status fail", "err", err)
+					return err
+				}
+			}
+			for _, hash := range failedHashes {
+				if err := tx.WithdrawAttempts.UpdateWithdrawAttemptStatus(hash, 3); err != nil {
+					log.Error("update withdraw attempt status fail", "err", err)
+					return err
+				}
+			}
+			for _, hash := range expiredHashes {
+				if err := tx.WithdrawAttempts.UpdateWithdrawAttemptStatus(hash, 2); err != nil {
+					log.Error("update withdraw attempt status fail", "err", err)
+					return err
+				}
+			}
+			if len(resendWithdrawsList) > 0 {
+				if err := tx.Withdraws.MarkWithdrawsToSend(resendWithdrawsList); err != nil {
+					log.Error("mark withdraw send fail", "err", err)
+					return err
+				}
+				if err := tx.WithdrawAttempts.StoreWithdrawAttempts(attemptList, uint64(len(attemptList))); err != nil {
+					log.Error("store withdraw attempts fail", "err", err)
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Error("unable to persist batch", "err", err)
+			return nil, err
+		}
+		return nil, nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// durableNonceAdvanced 检查使用 durable nonce 签名且还没有上链的提现，nonce 已经推进并且签名仍然不在链上时返回 true
+func (w *Withdraw) durableNonceAdvanced(withdraw *database.Withdraws, hotWallet *database.Addresses) (bool, error) {
+	nonceAccount, err := w.db.NonceAccounts.QueryNonceAccountByAddress(hotWallet.Address)
+	if err != nil {
+		log.Error("query nonce account fail", "err", err)
+		return false, err
+	}
+	if nonceAccount == nil {
+		log.Warn("hot wallet nonce account not found, skip durable nonce withdraw", "guid", withdraw.GUID)
+		return false, nil
+	}
+	attempts, err := w.db.WithdrawAttempts.QueryWithdrawAttempts(withdraw.GUID)
+	if err != nil {
+		log.Error("query withdraw attempts fail", "err", err)
+		return false, err
+	}
+	var usedNonce string
+	for _, attempt := range attempts {
+		if attempt.Hash == withdraw.Hash {
+			usedNonce = attempt.Nonce
+		}
+	}
+	if usedNonce == "" {
+		log.Warn("withdraw attempt not found, skip durable nonce withdraw", "guid", withdraw.GUID, "hash", withdraw.Hash)
+		return false, nil
+	}
+	return durableNonceAdvanced(w.client, nonceAccount.NonceAddress, usedNonce, withdraw.Hash, withdraw.TxSignHex)
+}